@@ -1,19 +1,28 @@
 package main
 
 import (
+	"log"
 	"market/web/routes"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"market/internal/database"
 	"market/internal/database/repositories"
+	"market/internal/mailer"
+	"market/internal/oidc"
 	"market/internal/services"
 	"market/web/handlers"
+	"market/web/handlers/middlewares"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	_ "github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func main() {
@@ -36,6 +45,35 @@ func main() {
 		panic("Failed to initialize database")
 	}
 
+	keys, err := loadKeySet()
+	if err != nil {
+		panic("Failed to load signing keys: " + err.Error())
+	}
+	middlewares.SetKeySet(keys)
+	middlewares.SetTokenConfig(middlewares.TokenConfig{
+		Issuer:     stringEnv("JWT_ISSUER", "market-api"),
+		Audience:   listEnv("JWT_AUDIENCE", []string{"market-api"}),
+		Algorithms: listEnv("JWT_ALGORITHMS", nil),
+		Leeway:     durationEnv("JWT_LEEWAY", middlewares.DefaultLeeway),
+	})
+
+	if secret := os.Getenv("CURSOR_SECRET"); secret != "" {
+		database.SetCursorSecret([]byte(secret))
+	} else {
+		log.Printf("CURSOR_SECRET not set, pagination cursors will not survive a restart")
+	}
+
+	handlers.SetMaxPageSize(intEnv("MAX_PAGE_SIZE", handlers.DefaultMaxPageSize))
+
+	offerTTL := durationEnv("OFFER_TTL", services.DefaultOfferTTL)
+
+	auctionExtension := durationEnv("AUCTION_EXTENSION", services.DefaultAuctionExtension)
+	auctionCloseInterval := durationEnv("AUCTION_CLOSE_INTERVAL", 30*time.Second)
+	refreshTokenTTL := durationEnv("REFRESH_TOKEN_TTL", services.DefaultRefreshTokenTTL)
+	escrowReleaseAfter := durationEnv("ESCROW_RELEASE_AFTER", services.DefaultEscrowReleaseAfter)
+	escrowReleaseInterval := durationEnv("ESCROW_RELEASE_INTERVAL", time.Minute)
+	appURL := stringEnv("APP_URL", "http://localhost:8080")
+
 	e := echo.New()
 
 	e.Use(middleware.Logger())
@@ -48,17 +86,260 @@ func main() {
 	userRepo := &repositories.UserRepository{DB: db}
 	itemRepo := &repositories.ItemRepository{DB: db}
 	dealRepo := &repositories.DealRepository{DB: db}
+	walletRepo := &repositories.WalletRepository{DB: db}
+	offerRepo := &repositories.OfferRepository{DB: db}
+	orderRepo := &repositories.OrderRepository{DB: db}
+	auctionRepo := &repositories.AuctionRepository{DB: db}
+	escrowRepo := &repositories.EscrowRepository{DB: db}
+	sessionRepo := &repositories.SessionRepository{DB: db}
+	roleRepo := &repositories.RoleRepository{DB: db}
+	revocationRepo := &repositories.RevocationRepository{DB: db}
+	accountTokenRepo := &repositories.AccountTokenRepository{DB: db}
+	mfaRepo := &repositories.MFARepository{DB: db}
+	loginAttemptRepo := &repositories.LoginAttemptRepository{DB: db}
+	loginLockoutRepo := &repositories.LoginLockoutRepository{DB: db}
+	apiKeyRepo := &repositories.APIKeyRepository{DB: db}
+	identityRepo := &repositories.IdentityRepository{DB: db}
+	categoryRepo := &repositories.CategoryRepository{DB: db}
+	txManager := &database.TxManager{DB: db}
 
-	userService := &services.UserServiceImpl{Repo: userRepo}
-	itemService := &services.ItemServiceIml{Repo: itemRepo}
-	dealService := &services.DealServiceImpl{Repo: dealRepo}
+	revocations := revocationStore(revocationRepo)
+	middlewares.SetRevocationStore(revocations)
+
+	sessionService := &services.SessionServiceImpl{Repo: sessionRepo, UserRepo: userRepo, RoleRepo: roleRepo, Revocations: revocations, Tx: txManager, TTL: refreshTokenTTL}
+	passwordManager := &services.PasswordManagerImpl{
+		Algorithm: stringEnv("PASSWORD_HASH", services.PasswordAlgorithmArgon2id),
+		Argon2: services.Argon2Params{
+			Memory:  uint32(intEnv("ARGON2_MEMORY_KIB", int(services.DefaultArgon2Params.Memory))),
+			Time:    uint32(intEnv("ARGON2_TIME", int(services.DefaultArgon2Params.Time))),
+			Threads: uint8(intEnv("ARGON2_THREADS", int(services.DefaultArgon2Params.Threads))),
+		},
+		BcryptCost: intEnv("BCRYPT_COST", bcrypt.DefaultCost),
+	}
+	loginAttempts := loginAttemptStore(loginAttemptRepo)
+	loginGuard := &services.LoginGuardImpl{
+		Store:    loginAttempts,
+		Lockouts: loginLockoutRepo,
+		UserRepo: userRepo,
+		Policy: services.LoginPolicy{
+			MaxUserFailures: intEnv("LOGIN_MAX_USER_FAILURES", services.DefaultLoginPolicy.MaxUserFailures),
+			MaxIPFailures:   intEnv("LOGIN_MAX_IP_FAILURES", services.DefaultLoginPolicy.MaxIPFailures),
+			BaseDelay:       durationEnv("LOGIN_BASE_DELAY", services.DefaultLoginPolicy.BaseDelay),
+			MaxDelay:        durationEnv("LOGIN_MAX_DELAY", services.DefaultLoginPolicy.MaxDelay),
+			LockoutDuration: durationEnv("LOGIN_LOCKOUT_DURATION", services.DefaultLoginPolicy.LockoutDuration),
+			Window:          durationEnv("LOGIN_FAILURE_WINDOW", services.DefaultLoginPolicy.Window),
+		},
+	}
+	mfaService := &services.MFAServiceImpl{Repo: mfaRepo, UserRepo: userRepo, Revocations: revocations, Attempts: loginAttempts, Guard: loginGuard, Tx: txManager, Issuer: stringEnv("TOTP_ISSUER", services.DefaultTOTPIssuer)}
+	accountService := &services.AccountServiceImpl{
+		Repo:            accountTokenRepo,
+		UserRepo:        userRepo,
+		Pass:            passwordManager,
+		Sessions:        sessionService,
+		APIKeys:         apiKeyRepo,
+		Guard:           loginGuard,
+		Mailer:          newMailer(),
+		Tx:              txManager,
+		BaseURL:         appURL,
+		ResetTTL:        durationEnv("PASSWORD_RESET_TTL", services.DefaultPasswordResetTTL),
+		VerificationTTL: durationEnv("EMAIL_VERIFICATION_TTL", services.DefaultEmailVerificationTTL),
+	}
+	userService := &services.UserServiceImpl{Repo: userRepo, Pass: passwordManager, Sessions: sessionService, Accounts: accountService, APIKeys: apiKeyRepo}
+	apiKeyService := &services.APIKeyServiceImpl{Repo: apiKeyRepo, UserRepo: userRepo}
+	oidcService := &services.OIDCServiceImpl{Clients: oidcClients(appURL), Repo: identityRepo, UserRepo: userRepo, Tx: txManager}
+	roleService := &services.RoleServiceImpl{Repo: roleRepo, UserRepo: userRepo, Tx: txManager}
+	categoryService := &services.CategoryServiceImpl{Repo: categoryRepo, Tx: txManager}
+	itemService := &services.ItemServiceIml{Repo: itemRepo, Categories: categoryService, Tx: txManager}
+	escrowService := &services.EscrowServiceImpl{Repo: escrowRepo, DealRepo: dealRepo, WalletRepo: walletRepo, ReleaseAfter: escrowReleaseAfter}
+	dealService := &services.DealServiceImpl{Repo: dealRepo, ItemRepo: itemRepo, Escrow: escrowService, Tx: txManager}
+	walletService := &services.WalletServiceImpl{Repo: walletRepo, Tx: txManager}
+	offerService := &services.OfferServiceImpl{Repo: offerRepo, ItemRepo: itemRepo, OrderRepo: orderRepo, DealService: dealService, Tx: txManager, TTL: offerTTL}
+	orderService := &services.OrderServiceImpl{Repo: orderRepo, ItemRepo: itemRepo, DealRepo: dealRepo, WalletRepo: walletRepo, Tx: txManager}
+
+	auctionService := &services.AuctionServiceImpl{Repo: auctionRepo, ItemRepo: itemRepo, DealService: dealService, Tx: txManager, Extension: auctionExtension}
+
+	if err := orderService.Load(); err != nil {
+		panic(err)
+	}
 
 	userHandler := &handlers.UserHandler{Service: userService}
-	authHandler := &handlers.AuthHandler{Service: userService}
+	authHandler := &handlers.AuthHandler{Service: userService, Sessions: sessionService, MFA: mfaService, Guard: loginGuard}
 	itemHandler := &handlers.ItemHandler{Service: itemService}
 	dealHandler := &handlers.DealHandler{Service: dealService}
+	walletHandler := &handlers.WalletHandler{Service: walletService}
+	offerHandler := &handlers.OfferHandler{Service: offerService}
+	orderHandler := &handlers.OrderHandler{Service: orderService}
+	auctionHandler := &handlers.AuctionHandler{Service: auctionService}
+	escrowHandler := &handlers.EscrowHandler{Service: escrowService}
+	roleHandler := &handlers.RoleHandler{Service: roleService}
+	keysHandler := &handlers.KeysHandler{Keys: keys}
+	accountHandler := &handlers.AccountHandler{Service: accountService}
+	mfaHandler := &handlers.MFAHandler{Service: mfaService}
+	lockoutHandler := &handlers.LockoutHandler{Guard: loginGuard}
+	apiKeyHandler := &handlers.APIKeyHandler{Service: apiKeyService}
+	categoryHandler := &handlers.CategoryHandler{Service: categoryService}
+	oidcHandler := &handlers.OIDCHandler{Service: oidcService, Sessions: sessionService, MFA: mfaService}
 
-	routes.InitRoutes(e, userHandler, authHandler, itemHandler, dealHandler)
+	routes.InitRoutes(e, userHandler, authHandler, itemHandler, dealHandler, walletHandler, offerHandler, orderHandler, auctionHandler, escrowHandler, roleHandler, keysHandler, accountHandler, mfaHandler, lockoutHandler, apiKeyHandler, oidcHandler, categoryHandler)
+
+	go runPeriodically("auctions closed", auctionCloseInterval, auctionService.CloseExpired)
+	go runPeriodically("escrow holds released", escrowReleaseInterval, dealService.ReleaseDue)
+	go runPeriodically("expired token revocations deleted", time.Hour, revocationRepo.DeleteExpired)
+	go runPeriodically("expired OIDC logins deleted", time.Hour, identityRepo.DeleteExpiredStates)
 
 	e.Start(":8080")
 }
+
+// runPeriodically calls job every interval for background work such as
+// closing auctions, logging how many records it processed.
+func runPeriodically(name string, interval time.Duration, job func() (int, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		count, err := job()
+		if err != nil {
+			log.Printf("Error in background job (%s): %v", name, err)
+		}
+		if count > 0 {
+			log.Printf("Background job: %d %s", count, name)
+		}
+	}
+}
+
+// revocationStore picks where revoked access tokens are kept. The memory
+// store is only suitable for a single instance.
+func revocationStore(repo *repositories.RevocationRepository) middlewares.RevocationStore {
+	switch store := stringEnv("TOKEN_REVOCATION_STORE", "postgres"); store {
+	case "postgres":
+		return repo
+	case "memory":
+		return middlewares.NewMemoryRevocationStore()
+	default:
+		panic("Invalid TOKEN_REVOCATION_STORE: " + store)
+	}
+}
+
+// loginAttemptStore picks where failed logins are counted. The memory store
+// is only suitable for a single instance.
+func loginAttemptStore(repo *repositories.LoginAttemptRepository) services.LoginAttemptStore {
+	switch store := stringEnv("LOGIN_ATTEMPT_STORE", "postgres"); store {
+	case "postgres":
+		return repo
+	case "memory":
+		return services.NewMemoryLoginAttemptStore()
+	default:
+		panic("Invalid LOGIN_ATTEMPT_STORE: " + store)
+	}
+}
+
+// newMailer sends mail over SMTP, or with MAILER=log writes it to
+// MAIL_LOG_FILE (or the application log) for local development.
+func newMailer() mailer.Mailer {
+	switch kind := stringEnv("MAILER", "log"); kind {
+	case "smtp":
+		return &mailer.SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     stringEnv("SMTP_PORT", "587"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+	case "log":
+		return &mailer.LogMailer{Path: os.Getenv("MAIL_LOG_FILE")}
+	default:
+		panic("Invalid MAILER: " + kind)
+	}
+}
+
+// oidcClients configures the providers named in OIDC_PROVIDERS, each from
+// OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET and _SCOPES.
+func oidcClients(appURL string) map[string]*oidc.Client {
+	clients := map[string]*oidc.Client{}
+
+	for _, name := range listEnv("OIDC_PROVIDERS", nil) {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		config := oidc.Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  strings.TrimSuffix(appURL, "/") + "/oidc/" + name + "/callback",
+			Scopes:       listEnv(prefix+"SCOPES", nil),
+		}
+		if config.Issuer == "" || config.ClientID == "" {
+			panic("OIDC provider " + name + " needs " + prefix + "ISSUER and " + prefix + "CLIENT_ID")
+		}
+
+		clients[name] = oidc.NewClient(config)
+	}
+
+	return clients
+}
+
+func stringEnv(name string, fallback string) string {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	return value
+}
+
+// listEnv reads a comma separated list.
+func listEnv(name string, fallback []string) []string {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	var list []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+
+	return list
+}
+
+func intEnv(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		panic("Invalid " + name + ": " + value)
+	}
+
+	return number
+}
+
+func durationEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		panic("Invalid " + name + ": " + err.Error())
+	}
+
+	return duration
+}
+
+// loadKeySet reads the token signing keys from JWT_KEYS_DIR. Without it a
+// throwaway key is generated, which logs everyone out on restart.
+func loadKeySet() (*middlewares.KeySet, error) {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		log.Printf("JWT_KEYS_DIR not set, signing tokens with a temporary key")
+		return middlewares.GenerateKeySet()
+	}
+
+	return middlewares.LoadKeySet(dir, os.Getenv("JWT_ACTIVE_KID"))
+}
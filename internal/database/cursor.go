@@ -0,0 +1,161 @@
+package database
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursorSecret signs cursors. A random secret is used until SetCursorSecret
+// is called, so cursors stop working when the process restarts.
+var cursorSecret = func() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}()
+
+// SetCursorSecret sets the key cursors are signed with. Instances behind a
+// load balancer must share it.
+func SetCursorSecret(secret []byte) {
+	cursorSecret = secret
+}
+
+// Cursor marks a position in a list ordered by a unique, stable key, the
+// row id. Unlike an offset it stays correct when rows are added or removed
+// between requests. Clients get it as an opaque signed token.
+type Cursor struct {
+	// Resource stops a cursor of one list from being used on another.
+	Resource string `json:"r"`
+	// Key is the id of the last row seen, or of the first one when going
+	// Backward.
+	Key      int64 `json:"k"`
+	Backward bool  `json:"b,omitempty"`
+}
+
+// CursorInfo requests Size rows after (or, Backward, before) Cursor. A nil
+// Cursor starts at the beginning of the list.
+type CursorInfo struct {
+	Cursor *Cursor
+	Size   int
+}
+
+func (info CursorInfo) Backward() bool {
+	return info.Cursor != nil && info.Cursor.Backward
+}
+
+// Limit is how many rows to fetch: one more than requested, to tell whether
+// there is another page.
+func (info CursorInfo) Limit() int {
+	return info.Size + 1
+}
+
+// Keyset returns the condition and ORDER BY for a keyset query on the key
+// column, numbering its placeholder after args, and args with the key
+// appended.
+func (info CursorInfo) Keyset(column string, args []interface{}) (string, string, []interface{}) {
+	switch {
+	case info.Cursor == nil:
+		return "TRUE", column + " ASC", args
+	case info.Cursor.Backward:
+		args = append(args, info.Cursor.Key)
+		return column + " < $" + strconv.Itoa(len(args)), column + " DESC", args
+	default:
+		args = append(args, info.Cursor.Key)
+		return column + " > $" + strconv.Itoa(len(args)), column + " ASC", args
+	}
+}
+
+// Encode returns the signed, URL-safe token for the cursor.
+func (cursor Cursor) Encode() string {
+	payload, _ := json.Marshal(cursor)
+
+	mac := hmac.New(sha256.New, cursorSecret)
+	mac.Write(payload)
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// DecodeCursor verifies a token made by Encode for the resource.
+func DecodeCursor(token string, resource string) (Cursor, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	mac := hmac.New(sha256.New, cursorSecret)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(payload, &cursor); err != nil || cursor.Resource != resource {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// CursorPage is one page of a cursor-paginated list. The cursors are empty
+// when there is no further page in that direction.
+type CursorPage[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// NewCursorPage builds the page from rows fetched with info.Limit(), in
+// ascending key order when going forward and descending when going
+// backward. key returns a row's cursor key.
+func NewCursorPage[T any](resource string, rows []T, info CursorInfo, key func(T) int64) CursorPage[T] {
+	more := len(rows) > info.Size
+	if more {
+		rows = rows[:info.Size]
+	}
+
+	backward := info.Backward()
+	if backward {
+		slices.Reverse(rows)
+	}
+
+	page := CursorPage[T]{Data: rows}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+	if len(rows) == 0 {
+		return page
+	}
+
+	first, last := key(rows[0]), key(rows[len(rows)-1])
+
+	// Going forward there is a next page if more rows came back, and a
+	// previous one if we did not start at the beginning; backward the
+	// other way round.
+	if more || backward {
+		page.NextCursor = Cursor{Resource: resource, Key: last}.Encode()
+	}
+	if (more && backward) || (!backward && info.Cursor != nil) {
+		page.PrevCursor = Cursor{Resource: resource, Key: first, Backward: true}.Encode()
+	}
+
+	return page
+}
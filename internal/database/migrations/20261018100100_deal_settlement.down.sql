@@ -0,0 +1,3 @@
+ALTER TABLE deals DROP COLUMN IF EXISTS seller_id;
+
+ALTER TABLE users DROP COLUMN IF EXISTS balance;
@@ -0,0 +1,9 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS balance DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (balance >= 0);
+
+UPDATE users u SET balance = w.balance FROM wallets w WHERE w.user_id = u.id;
+
+DROP TABLE IF EXISTS ledger_entries;
+DROP FUNCTION IF EXISTS ledger_forbid_mutation();
+DROP FUNCTION IF EXISTS ledger_check_balanced();
+DROP SEQUENCE IF EXISTS ledger_transaction_seq;
+DROP TABLE IF EXISTS wallets;
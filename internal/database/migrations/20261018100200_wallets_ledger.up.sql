@@ -0,0 +1,72 @@
+CREATE TABLE IF NOT EXISTS wallets (
+    id SERIAL PRIMARY KEY,
+    user_id INT UNIQUE REFERENCES users(id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL DEFAULT 'user',
+    balance DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (kind <> 'user' OR balance >= 0)
+);
+
+CREATE SEQUENCE IF NOT EXISTS ledger_transaction_seq;
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id SERIAL PRIMARY KEY,
+    transaction_id BIGINT NOT NULL,
+    wallet_id INT NOT NULL REFERENCES wallets(id),
+    amount DOUBLE PRECISION NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    deal_id INT,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS ledger_entries_wallet_id_idx ON ledger_entries (wallet_id, id);
+CREATE INDEX IF NOT EXISTS ledger_entries_transaction_id_idx ON ledger_entries (transaction_id);
+
+-- Every ledger transaction must sum to zero; checked at commit so all legs
+-- of a posting can be inserted first.
+CREATE OR REPLACE FUNCTION ledger_check_balanced() RETURNS TRIGGER AS $$
+BEGIN
+    IF (SELECT SUM(amount) FROM ledger_entries WHERE transaction_id = NEW.transaction_id) <> 0 THEN
+        RAISE EXCEPTION 'ledger transaction % is not balanced', NEW.transaction_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ledger_entries_balanced
+    AFTER INSERT ON ledger_entries
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE PROCEDURE ledger_check_balanced();
+
+CREATE OR REPLACE FUNCTION ledger_forbid_mutation() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'ledger entries are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ledger_entries_append_only
+    BEFORE UPDATE OR DELETE ON ledger_entries
+    FOR EACH ROW EXECUTE PROCEDURE ledger_forbid_mutation();
+
+-- The external wallet is the counterparty for money entering or leaving
+-- the platform.
+INSERT INTO wallets (kind) VALUES ('external');
+
+INSERT INTO wallets (user_id, balance) SELECT id, balance FROM users;
+
+WITH opening AS (
+    SELECT id AS wallet_id, balance, nextval('ledger_transaction_seq') AS transaction_id
+    FROM wallets
+    WHERE kind = 'user' AND balance > 0
+)
+INSERT INTO ledger_entries (transaction_id, wallet_id, amount, kind, description)
+SELECT transaction_id, wallet_id, balance, 'deposit', 'opening balance' FROM opening
+UNION ALL
+SELECT transaction_id, (SELECT id FROM wallets WHERE kind = 'external'), -balance, 'deposit', 'opening balance' FROM opening;
+
+UPDATE wallets
+SET balance = -(SELECT COALESCE(SUM(balance), 0) FROM wallets WHERE kind = 'user')
+WHERE kind = 'external';
+
+ALTER TABLE users DROP COLUMN IF EXISTS balance;
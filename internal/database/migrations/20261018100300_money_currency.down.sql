@@ -0,0 +1,23 @@
+CREATE OR REPLACE FUNCTION ledger_check_balanced() RETURNS TRIGGER AS $$
+BEGIN
+    IF (SELECT SUM(amount) FROM ledger_entries WHERE transaction_id = NEW.transaction_id) <> 0 THEN
+        RAISE EXCEPTION 'ledger transaction % is not balanced', NEW.transaction_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE ledger_entries DROP COLUMN IF EXISTS currency;
+ALTER TABLE ledger_entries ALTER COLUMN amount TYPE DOUBLE PRECISION USING amount / 100.0;
+
+DROP INDEX IF EXISTS wallets_system_kind_currency_idx;
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_user_id_currency_key;
+ALTER TABLE wallets DROP COLUMN IF EXISTS currency;
+ALTER TABLE wallets ADD CONSTRAINT wallets_user_id_key UNIQUE (user_id);
+ALTER TABLE wallets ALTER COLUMN balance TYPE DOUBLE PRECISION USING balance / 100.0;
+
+ALTER TABLE deals DROP COLUMN IF EXISTS currency;
+ALTER TABLE deals ALTER COLUMN price TYPE DOUBLE PRECISION USING price / 100.0;
+
+ALTER TABLE items DROP COLUMN IF EXISTS currency;
+ALTER TABLE items ALTER COLUMN price TYPE DOUBLE PRECISION USING price / 100.0;
@@ -0,0 +1,30 @@
+-- Money is stored as BIGINT minor units plus an ISO 4217 currency code.
+-- Existing amounts were implicitly USD.
+ALTER TABLE items ALTER COLUMN price TYPE BIGINT USING ROUND(price * 100)::BIGINT;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE deals ALTER COLUMN price TYPE BIGINT USING ROUND(price * 100)::BIGINT;
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE wallets ALTER COLUMN balance TYPE BIGINT USING ROUND(balance * 100)::BIGINT;
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_user_id_key;
+ALTER TABLE wallets ADD CONSTRAINT wallets_user_id_currency_key UNIQUE (user_id, currency);
+CREATE UNIQUE INDEX IF NOT EXISTS wallets_system_kind_currency_idx ON wallets (kind, currency) WHERE user_id IS NULL;
+
+ALTER TABLE ledger_entries ALTER COLUMN amount TYPE BIGINT USING ROUND(amount * 100)::BIGINT;
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+CREATE OR REPLACE FUNCTION ledger_check_balanced() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM ledger_entries
+        WHERE transaction_id = NEW.transaction_id
+        GROUP BY currency
+        HAVING SUM(amount) <> 0
+    ) THEN
+        RAISE EXCEPTION 'ledger transaction % is not balanced', NEW.transaction_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
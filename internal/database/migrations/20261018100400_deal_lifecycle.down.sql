@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS deal_events;
+
+ALTER TABLE deals DROP COLUMN IF EXISTS status;
@@ -0,0 +1,15 @@
+-- Deals created before the lifecycle existed were settled immediately.
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'completed'
+    CHECK (status IN ('offered', 'accepted', 'paid', 'completed', 'cancelled', 'disputed'));
+ALTER TABLE deals ALTER COLUMN status SET DEFAULT 'offered';
+
+CREATE TABLE IF NOT EXISTS deal_events (
+    id SERIAL PRIMARY KEY,
+    deal_id INT NOT NULL REFERENCES deals(id) ON DELETE CASCADE,
+    from_status VARCHAR(20) NOT NULL DEFAULT '',
+    to_status VARCHAR(20) NOT NULL,
+    actor_id INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS deal_events_deal_id_idx ON deal_events (deal_id, id);
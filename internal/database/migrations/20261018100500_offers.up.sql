@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS offers (
+    id SERIAL PRIMARY KEY,
+    item_id INT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+    buyer_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    seller_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    proposer_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    parent_id INT REFERENCES offers(id) ON DELETE SET NULL,
+    deal_id INT REFERENCES deals(id) ON DELETE SET NULL,
+    price BIGINT NOT NULL CHECK (price > 0),
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'accepted', 'rejected', 'countered', 'expired')),
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS offers_item_id_idx ON offers (item_id, id);
+CREATE INDEX IF NOT EXISTS offers_buyer_id_idx ON offers (buyer_id, id);
+CREATE INDEX IF NOT EXISTS offers_seller_id_idx ON offers (seller_id, id);
+CREATE INDEX IF NOT EXISTS offers_pending_expires_at_idx ON offers (expires_at) WHERE status = 'pending';
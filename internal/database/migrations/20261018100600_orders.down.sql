@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS items_owner_id_name_idx;
+DROP TABLE IF EXISTS orders;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS orders (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    item_name VARCHAR(50) NOT NULL,
+    side VARCHAR(3) NOT NULL CHECK (side IN ('bid', 'ask')),
+    price BIGINT NOT NULL CHECK (price > 0),
+    currency CHAR(3) NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    filled INT NOT NULL DEFAULT 0 CHECK (filled >= 0 AND filled <= quantity),
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'filled', 'cancelled')),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS orders_user_id_idx ON orders (user_id, id);
+CREATE INDEX IF NOT EXISTS orders_open_idx ON orders (id) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS items_owner_id_name_idx ON items (owner_id, name);
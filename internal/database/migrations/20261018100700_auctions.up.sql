@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS auctions (
+    id SERIAL PRIMARY KEY,
+    item_id INT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+    seller_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    start_price BIGINT NOT NULL CHECK (start_price > 0),
+    reserve_price BIGINT NOT NULL DEFAULT 0 CHECK (reserve_price >= 0),
+    increment BIGINT NOT NULL CHECK (increment > 0),
+    currency CHAR(3) NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'sold', 'unsold')),
+    deal_id INT REFERENCES deals(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS auctions_open_item_id_idx ON auctions (item_id) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS auctions_open_ends_at_idx ON auctions (ends_at) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS bids (
+    id SERIAL PRIMARY KEY,
+    auction_id INT NOT NULL REFERENCES auctions(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    currency CHAR(3) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS bids_auction_id_amount_idx ON bids (auction_id, amount DESC, id);
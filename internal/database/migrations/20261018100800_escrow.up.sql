@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS escrow_holds (
+    id SERIAL PRIMARY KEY,
+    deal_id INT NOT NULL UNIQUE REFERENCES deals(id),
+    buyer_id INT NOT NULL,
+    seller_id INT NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'held' CHECK (status IN ('held', 'released', 'refunded')),
+    release_at TIMESTAMPTZ NOT NULL,
+    hold_transaction_id BIGINT NOT NULL,
+    settle_transaction_id BIGINT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    settled_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS escrow_holds_due_idx ON escrow_holds (release_at) WHERE status = 'held';
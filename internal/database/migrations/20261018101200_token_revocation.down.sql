@@ -0,0 +1,6 @@
+DELETE FROM permissions WHERE name = 'users:ban';
+
+ALTER TABLE users DROP COLUMN IF EXISTS banned_at;
+
+DROP TABLE IF EXISTS user_token_cutoffs;
+DROP TABLE IF EXISTS revoked_tokens;
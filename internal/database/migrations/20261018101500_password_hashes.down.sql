@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS salt VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Only legacy hashes can be restored; users whose password was rehashed
+-- have to reset it.
+UPDATE users SET
+    salt = split_part(substr(password, 16), '$', 1),
+    password = substr(password, 17 + length(split_part(substr(password, 16), '$', 1)))
+WHERE password LIKE '$bcrypt-salted$%';
+
+ALTER TABLE users ALTER COLUMN salt DROP DEFAULT;
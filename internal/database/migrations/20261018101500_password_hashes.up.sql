@@ -0,0 +1,8 @@
+ALTER TABLE users ALTER COLUMN password TYPE VARCHAR(255);
+
+-- Keep old bcrypt(password + salt) hashes checkable after the salt column
+-- is gone. They are replaced with the current algorithm on next login.
+UPDATE users SET password = '$bcrypt-salted$' || salt || '$' || password
+WHERE password LIKE '$2%';
+
+ALTER TABLE users DROP COLUMN IF EXISTS salt;
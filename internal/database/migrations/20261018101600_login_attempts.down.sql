@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE name = 'users:unlock';
+
+DROP TABLE IF EXISTS login_lockouts;
+DROP TABLE IF EXISTS login_attempts;
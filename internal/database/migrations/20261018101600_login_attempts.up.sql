@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key VARCHAR(150) PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS login_lockouts (
+    id SERIAL PRIMARY KEY,
+    key VARCHAR(150) NOT NULL,
+    failures INT NOT NULL,
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    locked_until TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    unlocked_at TIMESTAMPTZ,
+    unlocked_by INT REFERENCES users(id) ON DELETE SET NULL,
+    unlock_reason VARCHAR(50)
+);
+
+CREATE INDEX IF NOT EXISTS login_lockouts_key_idx ON login_lockouts (key);
+
+INSERT INTO permissions (name) VALUES ('users:unlock')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name = 'users:unlock'
+WHERE r.name IN ('admin', 'moderator')
+ON CONFLICT DO NOTHING;
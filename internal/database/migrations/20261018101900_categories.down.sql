@@ -0,0 +1,10 @@
+DELETE FROM permissions WHERE name = 'categories:manage';
+
+DROP INDEX IF EXISTS items_category_id_idx;
+
+ALTER TABLE items
+    DROP COLUMN IF EXISTS attributes,
+    DROP COLUMN IF EXISTS category_id;
+
+DROP TABLE IF EXISTS category_attributes;
+DROP TABLE IF EXISTS categories;
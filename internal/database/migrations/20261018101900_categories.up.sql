@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    slug VARCHAR(50) NOT NULL UNIQUE,
+    parent_id INT REFERENCES categories(id) ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS categories_parent_id_idx ON categories (parent_id);
+
+CREATE TABLE IF NOT EXISTS category_attributes (
+    id SERIAL PRIMARY KEY,
+    category_id INT NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    type VARCHAR(10) NOT NULL CHECK (type IN ('string', 'number', 'enum', 'bool')),
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    options TEXT[] NOT NULL DEFAULT '{}',
+    UNIQUE (category_id, name)
+);
+
+ALTER TABLE items
+    ADD COLUMN IF NOT EXISTS category_id INT REFERENCES categories(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS items_category_id_idx ON items (category_id);
+
+INSERT INTO permissions (name) VALUES ('categories:manage')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name = 'categories:manage'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
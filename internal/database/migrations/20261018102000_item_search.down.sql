@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS items_name_trgm_idx;
+DROP INDEX IF EXISTS items_search_vector_idx;
+
+ALTER TABLE items DROP COLUMN IF EXISTS search_vector;
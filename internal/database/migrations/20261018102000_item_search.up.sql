@@ -0,0 +1,10 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE items
+    ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+        setweight(jsonb_to_tsvector('english', attributes, '["string"]'), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS items_search_vector_idx ON items USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS items_name_trgm_idx ON items USING GIN (name gin_trgm_ops);
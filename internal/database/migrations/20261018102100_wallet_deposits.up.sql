@@ -0,0 +1,9 @@
+-- Deposits credit money from outside the platform, so only admins may make
+-- them until a payment provider confirms them.
+INSERT INTO permissions (name) VALUES ('wallets:deposit')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name = 'wallets:deposit'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS wallets_system_kind_currency_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS wallets_system_kind_currency_idx ON wallets (kind, currency) WHERE user_id IS NULL;
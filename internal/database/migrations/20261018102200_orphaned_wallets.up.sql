@@ -0,0 +1,5 @@
+-- Deleting a user keeps their wallet for the ledger with a NULL owner, so
+-- only system wallets are unique per kind and currency.
+DROP INDEX IF EXISTS wallets_system_kind_currency_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS wallets_system_kind_currency_idx ON wallets (kind, currency)
+    WHERE user_id IS NULL AND kind <> 'user';
@@ -0,0 +1,4 @@
+-- The access token issued with each refresh token, so a family's live
+-- access tokens can be revoked when a rotated refresh token is reused.
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS access_jti VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS access_expires_at TIMESTAMPTZ;
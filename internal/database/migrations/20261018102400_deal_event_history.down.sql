@@ -0,0 +1,3 @@
+DELETE FROM deal_events WHERE deal_id NOT IN (SELECT id FROM deals);
+ALTER TABLE deal_events ADD CONSTRAINT deal_events_deal_id_fkey
+    FOREIGN KEY (deal_id) REFERENCES deals(id) ON DELETE CASCADE;
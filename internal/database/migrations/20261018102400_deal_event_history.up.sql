@@ -0,0 +1,3 @@
+-- Deal events are the history of a deal and outlive it, like the ledger
+-- entries that refer to it, so deleting a deal or its item keeps them.
+ALTER TABLE deal_events DROP CONSTRAINT IF EXISTS deal_events_deal_id_fkey;
@@ -0,0 +1,5 @@
+-- Logins in progress are counted apart from failures, so only a wrong
+-- password adds to the failures and their backoff. A reservation older than
+-- a minute belongs to a request that never finished and is ignored.
+ALTER TABLE login_attempts ADD COLUMN IF NOT EXISTS pending INT NOT NULL DEFAULT 0;
+ALTER TABLE login_attempts ADD COLUMN IF NOT EXISTS reserved_at TIMESTAMPTZ;
@@ -0,0 +1,8 @@
+-- Disputes the parties cannot settle themselves are decided by admins.
+INSERT INTO permissions (name) VALUES ('deals:resolve')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name = 'deals:resolve'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
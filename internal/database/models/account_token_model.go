@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+const (
+	AccountTokenPasswordReset     = "password_reset"
+	AccountTokenEmailVerification = "email_verification"
+)
+
+// AccountToken is a single-use token mailed to a user. Only its hash is
+// stored.
+type AccountToken struct {
+	Id        int        `db:"id"`
+	UserId    int        `db:"user_id"`
+	Purpose   string     `db:"purpose"`
+	TokenHash string     `db:"token_hash"`
+	CreatedAt time.Time  `db:"created_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+}
+
+type ForgotPassword struct {
+	Email string `json:"email"`
+}
+
+type ResetPassword struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type VerifyEmail struct {
+	Token string `json:"token"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIKeyScopes are the scopes an API key can be granted: read or write
+// access per resource. Account management such as changing the user,
+// sessions, MFA or API keys themselves is never available to API keys.
+var APIKeyScopes = []string{
+	"users:read",
+	"items:read", "items:write",
+	"categories:read",
+	"deals:read", "deals:write",
+	"wallet:read", "wallet:write",
+	"offers:read", "offers:write",
+	"orders:read", "orders:write",
+	"auctions:read", "auctions:write",
+}
+
+type APIKey struct {
+	Id         int            `json:"id" db:"id"`
+	UserId     int            `json:"-" db:"user_id"`
+	Name       string         `json:"name" db:"name"`
+	Prefix     string         `json:"prefix" db:"prefix"`
+	KeyHash    string         `json:"-" db:"key_hash"`
+	Scopes     pq.StringArray `json:"scopes" db:"scopes"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+	ExpiresAt  *time.Time     `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time     `json:"last_used_at" db:"last_used_at"`
+	RevokedAt  *time.Time     `json:"revoked_at" db:"revoked_at"`
+}
+
+type NewAPIKey struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreatedAPIKey carries the plain key. It is only returned on creation.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
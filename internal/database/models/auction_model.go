@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+const (
+	AuctionStatusOpen   = "open"
+	AuctionStatusSold   = "sold"
+	AuctionStatusUnsold = "unsold"
+)
+
+type Auction struct {
+	Id           int       `json:"id" db:"id"`
+	ItemId       int       `json:"item_id" db:"item_id"`
+	SellerId     int       `json:"seller_id" db:"seller_id"`
+	StartPrice   Money     `json:"start_price" db:"start_price"`
+	ReservePrice Money     `json:"reserve_price" db:"reserve_price"`
+	Increment    Money     `json:"increment" db:"increment"`
+	EndsAt       time.Time `json:"ends_at" db:"ends_at"`
+	Status       string    `json:"status" db:"status"`
+	DealId       *int      `json:"deal_id" db:"deal_id"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+type NewAuction struct {
+	ItemId       int       `json:"item_id"`
+	StartPrice   Money     `json:"start_price"`
+	ReservePrice Money     `json:"reserve_price"`
+	Increment    Money     `json:"increment"`
+	EndsAt       time.Time `json:"ends_at"`
+	SellerId     int       `json:"-"`
+}
+
+type Bid struct {
+	Id        int       `json:"id" db:"id"`
+	AuctionId int       `json:"auction_id" db:"auction_id"`
+	UserId    int       `json:"user_id" db:"user_id"`
+	Amount    Money     `json:"amount" db:"amount"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type BidRequest struct {
+	Amount Money `json:"amount"`
+}
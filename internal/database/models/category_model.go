@@ -0,0 +1,88 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+const (
+	AttributeString = "string"
+	AttributeNumber = "number"
+	AttributeEnum   = "enum"
+	AttributeBool   = "bool"
+)
+
+// Category groups items. Categories form a tree; an item in a category has
+// the attributes defined on it and on all of its parents.
+type Category struct {
+	Id       int    `json:"id" db:"id"`
+	Name     string `json:"name" db:"name"`
+	Slug     string `json:"slug" db:"slug"`
+	ParentId *int   `json:"parent_id" db:"parent_id"`
+	// Attributes are only filled in when a single category is requested.
+	Attributes []CategoryAttribute `json:"attributes,omitempty" db:"-"`
+}
+
+type CategoryAttribute struct {
+	Id         int            `json:"id" db:"id"`
+	CategoryId int            `json:"category_id" db:"category_id"`
+	Name       string         `json:"name" db:"name"`
+	Type       string         `json:"type" db:"type"`
+	Required   bool           `json:"required" db:"required"`
+	Options    pq.StringArray `json:"options" db:"options"`
+}
+
+// NewCategory creates or, with Id set, replaces a category together with
+// its own attribute definitions.
+type NewCategory struct {
+	Id         int                    `json:"-"`
+	Name       string                 `json:"name"`
+	Slug       string                 `json:"slug"`
+	ParentId   *int                   `json:"parent_id"`
+	Attributes []NewCategoryAttribute `json:"attributes"`
+}
+
+type NewCategoryAttribute struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Options  []string `json:"options"`
+}
+
+// Attributes are an item's attribute values by name, stored as JSONB.
+// Values are strings, numbers (float64) or booleans.
+type Attributes map[string]interface{}
+
+func (attributes Attributes) Value() (driver.Value, error) {
+	if attributes == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(attributes)
+}
+
+func (attributes *Attributes) Scan(src interface{}) error {
+	var data []byte
+	switch typed := src.(type) {
+	case []byte:
+		data = typed
+	case string:
+		data = []byte(typed)
+	case nil:
+		*attributes = Attributes{}
+		return nil
+	default:
+		return errors.New("attributes must be JSON")
+	}
+
+	return json.Unmarshal(data, attributes)
+}
+
+// ItemFilter narrows item lists. CategoryId includes subcategories;
+// Attributes match values by their text form.
+type ItemFilter struct {
+	CategoryId *int
+	Attributes map[string]string
+}
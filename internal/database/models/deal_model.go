@@ -1,14 +1,44 @@
 package models
 
+import "time"
+
+const (
+	DealStatusOffered   = "offered"
+	DealStatusAccepted  = "accepted"
+	DealStatusPaid      = "paid"
+	DealStatusCompleted = "completed"
+	DealStatusCancelled = "cancelled"
+	DealStatusDisputed  = "disputed"
+)
+
 type Deal struct {
-	Id    int     `db:"id"`
-	Item  Item    `db:"item"`
-	User  User    `db:"user"`
-	Price float64 `db:"price"`
+	Id     int    `db:"id"`
+	Item   Item   `db:"item"`
+	User   User   `db:"user"`
+	Seller User   `db:"seller"`
+	Price  Money  `db:"price"`
+	Status string `db:"status"`
 }
 
 type NewDeal struct {
-	Item  Item    `db:"item"`
-	User  User    `db:"user"`
-	Price float64 `db:"price"`
+	Item   Item   `db:"item"`
+	User   User   `db:"user"`
+	Seller User   `db:"seller"`
+	Price  Money  `db:"price"`
+	Status string `db:"status"`
+}
+
+// DealResolution is the outcome chosen for a disputed deal: completed or
+// cancelled.
+type DealResolution struct {
+	Status string `json:"status"`
+}
+
+type DealEvent struct {
+	Id         int       `json:"id" db:"id"`
+	DealId     int       `json:"deal_id" db:"deal_id"`
+	FromStatus string    `json:"from_status" db:"from_status"`
+	ToStatus   string    `json:"to_status" db:"to_status"`
+	ActorId    *int      `json:"actor_id" db:"actor_id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 }
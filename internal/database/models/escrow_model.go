@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+const (
+	EscrowStatusHeld     = "held"
+	EscrowStatusReleased = "released"
+	EscrowStatusRefunded = "refunded"
+)
+
+// EscrowHold is money a buyer has paid for a deal that the platform keeps
+// until delivery is confirmed. The ledger transactions that moved the money
+// in and out are referenced for auditing.
+type EscrowHold struct {
+	Id                  int        `json:"id" db:"id"`
+	DealId              int        `json:"deal_id" db:"deal_id"`
+	BuyerId             int        `json:"buyer_id" db:"buyer_id"`
+	SellerId            int        `json:"seller_id" db:"seller_id"`
+	Amount              Money      `json:"amount" db:"amount"`
+	Status              string     `json:"status" db:"status"`
+	ReleaseAt           time.Time  `json:"release_at" db:"release_at"`
+	HoldTransactionId   int64      `json:"hold_transaction_id" db:"hold_transaction_id"`
+	SettleTransactionId *int64     `json:"settle_transaction_id" db:"settle_transaction_id"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	SettledAt           *time.Time `json:"settled_at" db:"settled_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to an account at an external OpenID Connect
+// provider, identified by the provider's subject.
+type UserIdentity struct {
+	Id          int       `json:"id" db:"id"`
+	UserId      int       `json:"-" db:"user_id"`
+	Provider    string    `json:"provider" db:"provider"`
+	Subject     string    `json:"-" db:"subject"`
+	Email       string    `json:"email" db:"email"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at" db:"last_login_at"`
+}
+
+// OIDCLoginState is a login started at a provider and not yet called back.
+// It keeps the nonce and PKCE verifier the callback is checked against.
+type OIDCLoginState struct {
+	StateHash    string    `db:"state_hash"`
+	Provider     string    `db:"provider"`
+	Nonce        string    `db:"nonce"`
+	CodeVerifier string    `db:"code_verifier"`
+	ExpiresAt    time.Time `db:"expires_at"`
+}
+
+type OIDCProviders struct {
+	Providers []string `json:"providers"`
+}
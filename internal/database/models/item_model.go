@@ -1,14 +1,26 @@
 package models
 
 type Item struct {
-	Id      int     `db:"id"`
-	Name    string  `db:"name"`
-	Price   float64 `db:"price"`
-	OwnerId int     `db:"owner_id"`
+	Id         int        `db:"id"`
+	Name       string     `db:"name"`
+	Price      Money      `db:"price"`
+	OwnerId    int        `db:"owner_id"`
+	CategoryId *int       `db:"category_id"`
+	Attributes Attributes `db:"attributes"`
 }
 
 type NewItem struct {
-	Name    string  `db:"name"`
-	Price   float64 `db:"price"`
-	OwnerId int     `db:"owner_id"`
+	Name       string     `db:"name"`
+	Price      Money      `db:"price"`
+	OwnerId    int        `db:"owner_id"`
+	CategoryId *int       `db:"category_id"`
+	Attributes Attributes `db:"attributes"`
+}
+
+// ItemSearchResult is an item matching a search, with its name highlighted
+// and how well it matched.
+type ItemSearchResult struct {
+	Item
+	Snippet string  `db:"snippet"`
+	Rank    float64 `db:"rank"`
 }
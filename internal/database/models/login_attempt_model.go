@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+const (
+	UnlockReasonAdmin         = "admin"
+	UnlockReasonPasswordReset = "password_reset"
+)
+
+// LoginReservationTTL is how long a reserved login attempt holds its slot.
+// A password check never takes that long, so older reservations belong to
+// requests that died before releasing them.
+const LoginReservationTTL = time.Minute
+
+// LoginAttempts counts recent failed logins for one key, a username or an
+// IP address, and the logins in progress.
+type LoginAttempts struct {
+	Key           string     `db:"key"`
+	Failures      int        `db:"failures"`
+	LastFailureAt time.Time  `db:"last_failure_at"`
+	LockedUntil   *time.Time `db:"locked_until"`
+	Pending       int        `db:"pending"`
+	ReservedAt    *time.Time `db:"reserved_at"`
+}
+
+// ActiveFailures is the number of failures still within window at time at.
+func (attempts LoginAttempts) ActiveFailures(at time.Time, window time.Duration) int {
+	if attempts.LastFailureAt.Before(at.Add(-window)) {
+		return 0
+	}
+	return attempts.Failures
+}
+
+// ActivePending is the number of reservations that have not timed out at
+// time at.
+func (attempts LoginAttempts) ActivePending(at time.Time) int {
+	if attempts.ReservedAt == nil || attempts.ReservedAt.Before(at.Add(-LoginReservationTTL)) {
+		return 0
+	}
+	return attempts.Pending
+}
+
+// LoginLockout is the audit record written whenever a key gets locked.
+type LoginLockout struct {
+	Id           int        `json:"id" db:"id"`
+	Key          string     `json:"key" db:"key"`
+	Failures     int        `json:"failures" db:"failures"`
+	IpAddress    string     `json:"ip_address" db:"ip_address"`
+	LockedUntil  time.Time  `json:"locked_until" db:"locked_until"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UnlockedAt   *time.Time `json:"unlocked_at" db:"unlocked_at"`
+	UnlockedBy   *int       `json:"unlocked_by" db:"unlocked_by"`
+	UnlockReason *string    `json:"unlock_reason" db:"unlock_reason"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type UserMFA struct {
+	UserId       int        `db:"user_id"`
+	Secret       string     `db:"secret"`
+	EnabledAt    *time.Time `db:"enabled_at"`
+	LastUsedStep int64      `db:"last_used_step"`
+	CreatedAt    time.Time  `db:"created_at"`
+}
+
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type MFACode struct {
+	Code string `json:"code"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// MFAChallenge is returned by login instead of tokens when the user has
+// two-factor authentication enabled.
+type MFAChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+type MFALogin struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const DefaultCurrency = "USD"
+
+var (
+	ErrCurrencyMismatch    = errors.New("currency mismatch")
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+	ErrInvalidAmount       = errors.New("invalid amount")
+)
+
+// currencyExponents maps supported ISO 4217 codes to their number of minor
+// unit digits.
+var currencyExponents = map[string]int{
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"CHF": 2,
+	"CNY": 2,
+	"RUB": 2,
+	"JPY": 0,
+	"KWD": 3,
+}
+
+// Money is an exact amount in minor units (cents for USD) of an ISO 4217
+// currency. In the database it maps to a BIGINT amount column and a CHAR(3)
+// currency column, selected as "<prefix>.amount" and "<prefix>.currency".
+type Money struct {
+	Amount   int64  `db:"amount"`
+	Currency string `db:"currency"`
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney parses a decimal string such as "12.30" into minor units of the
+// given currency, rejecting more fractional digits than the currency has.
+func ParseMoney(value, currency string) (Money, error) {
+	exponent, ok := currencyExponents[currency]
+	if !ok {
+		return Money{}, ErrUnsupportedCurrency
+	}
+
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(value, "-")
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	if whole == "" || len(fraction) > exponent || strings.ContainsAny(whole+fraction, "+-") {
+		return Money{}, ErrInvalidAmount
+	}
+	fraction += strings.Repeat("0", exponent-len(fraction))
+
+	amount, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return Money{}, ErrInvalidAmount
+	}
+
+	if negative {
+		amount = -amount
+	}
+
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// CurrencyExponent returns the number of minor unit digits of a supported
+// currency.
+func CurrencyExponent(currency string) (int, bool) {
+	exponent, ok := currencyExponents[currency]
+	return exponent, ok
+}
+
+// Currencies lists the supported currency codes in alphabetical order.
+func Currencies() []string {
+	currencies := make([]string, 0, len(currencyExponents))
+	for currency := range currencyExponents {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	return currencies
+}
+
+func ValidateCurrency(currency string) error {
+	if _, ok := currencyExponents[currency]; !ok {
+		return ErrUnsupportedCurrency
+	}
+	return nil
+}
+
+func (m Money) Validate() error {
+	return ValidateCurrency(m.Currency)
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+// Cmp returns -1, 0 or 1 comparing m with other, which must share its currency.
+func (m Money) Cmp(other Money) (int, error) {
+	if m.Currency != other.Currency {
+		return 0, ErrCurrencyMismatch
+	}
+
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Decimal formats the amount in major units, e.g. "12.30".
+func (m Money) Decimal() string {
+	exponent := currencyExponents[m.Currency]
+
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.FormatInt(amount, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// MarshalJSON encodes money as {"amount": "12.30", "currency": "USD"}; the
+// amount is a string so clients never round it through a float.
+func (m Money) MarshalJSON() ([]byte, error) {
+	amount, err := json.Marshal(m.Decimal())
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(moneyJSON{Amount: amount, Currency: m.Currency})
+}
+
+// UnmarshalJSON accepts the amount as a decimal string or a JSON number and
+// defaults the currency to DefaultCurrency.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw moneyJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if raw.Currency == "" {
+		raw.Currency = DefaultCurrency
+	}
+	raw.Currency = strings.ToUpper(raw.Currency)
+
+	amount := string(bytes.TrimSpace(raw.Amount))
+	if strings.HasPrefix(amount, `"`) {
+		if err := json.Unmarshal(raw.Amount, &amount); err != nil {
+			return err
+		}
+	}
+
+	if amount == "" || amount == "null" {
+		return fmt.Errorf("%w: amount is required", ErrInvalidAmount)
+	}
+
+	parsed, err := ParseMoney(amount, raw.Currency)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+const (
+	OfferStatusPending   = "pending"
+	OfferStatusAccepted  = "accepted"
+	OfferStatusRejected  = "rejected"
+	OfferStatusCountered = "countered"
+	OfferStatusExpired   = "expired"
+)
+
+type Offer struct {
+	Id         int       `json:"id" db:"id"`
+	ItemId     int       `json:"item_id" db:"item_id"`
+	BuyerId    int       `json:"buyer_id" db:"buyer_id"`
+	SellerId   int       `json:"seller_id" db:"seller_id"`
+	ProposerId int       `json:"proposer_id" db:"proposer_id"`
+	ParentId   *int      `json:"parent_id" db:"parent_id"`
+	DealId     *int      `json:"deal_id" db:"deal_id"`
+	Price      Money     `json:"price" db:"price"`
+	Status     string    `json:"status" db:"status"`
+	ExpiresAt  time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+type NewOffer struct {
+	ItemId     int
+	BuyerId    int
+	SellerId   int
+	ProposerId int
+	ParentId   *int
+	Price      Money
+	ExpiresAt  time.Time
+}
+
+type OfferRequest struct {
+	Price Money `json:"price"`
+}
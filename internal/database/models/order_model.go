@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+const (
+	OrderSideBid = "bid"
+	OrderSideAsk = "ask"
+)
+
+const (
+	OrderStatusOpen      = "open"
+	OrderStatusFilled    = "filled"
+	OrderStatusCancelled = "cancelled"
+)
+
+type Order struct {
+	Id        int       `json:"id" db:"id"`
+	UserId    int       `json:"user_id" db:"user_id"`
+	ItemName  string    `json:"item_name" db:"item_name"`
+	Side      string    `json:"side" db:"side"`
+	Price     Money     `json:"price" db:"price"`
+	Quantity  int       `json:"quantity" db:"quantity"`
+	Filled    int       `json:"filled" db:"filled"`
+	Status    string    `json:"status" db:"status"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+func (order *Order) Remaining() int {
+	return order.Quantity - order.Filled
+}
+
+type NewOrder struct {
+	ItemName string `json:"item_name"`
+	Side     string `json:"side"`
+	Price    Money  `json:"price"`
+	Quantity int    `json:"quantity"`
+}
+
+type BookLevel struct {
+	Price    Money `json:"price"`
+	Quantity int   `json:"quantity"`
+}
+
+type OrderBookSnapshot struct {
+	ItemName string      `json:"item_name"`
+	Currency string      `json:"currency"`
+	Bids     []BookLevel `json:"bids"`
+	Asks     []BookLevel `json:"asks"`
+}
@@ -0,0 +1,30 @@
+package models
+
+const (
+	PermUsersUpdateAny   = "users:update:any"
+	PermUsersDeleteAny   = "users:delete:any"
+	PermUsersBan         = "users:ban"
+	PermUsersUnlock      = "users:unlock"
+	PermItemsUpdateAny   = "items:update:any"
+	PermItemsDeleteAny   = "items:delete:any"
+	PermDealsDeleteAny   = "deals:delete:any"
+	PermDealsResolve     = "deals:resolve"
+	PermRolesManage      = "roles:manage"
+	PermCategoriesManage = "categories:manage"
+	PermWalletsDeposit   = "wallets:deposit"
+)
+
+type Role struct {
+	Id          int      `json:"id" db:"id"`
+	Name        string   `json:"name" db:"name"`
+	Permissions []string `json:"permissions" db:"-"`
+}
+
+type UserRoles struct {
+	Roles []string `json:"roles"`
+}
+
+type RolePermission struct {
+	Role       string `db:"role"`
+	Permission string `db:"permission"`
+}
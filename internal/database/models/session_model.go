@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Session is one refresh token in a token family. Each refresh rotates the
+// token, so a family holds the history of a single sign-in and only its
+// newest token is usable.
+type Session struct {
+	Id        int        `json:"id" db:"id"`
+	UserId    int        `json:"user_id" db:"user_id"`
+	FamilyId  string     `json:"family_id" db:"family_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	UserAgent string     `json:"user_agent" db:"user_agent"`
+	IpAddress string     `json:"ip_address" db:"ip_address"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	RotatedAt *time.Time `json:"-" db:"rotated_at"`
+	RevokedAt *time.Time `json:"-" db:"revoked_at"`
+	// AccessTokenId is the jti of the access token issued with this refresh
+	// token.
+	AccessTokenId   string     `json:"-" db:"access_jti"`
+	AccessExpiresAt *time.Time `json:"-" db:"access_expires_at"`
+}
+
+// NewSession is a refresh token together with the access token issued
+// alongside it.
+type NewSession struct {
+	UserId          int
+	FamilyId        string
+	TokenHash       string
+	Meta            SessionMeta
+	ExpiresAt       time.Time
+	AccessTokenId   string
+	AccessExpiresAt time.Time
+}
+
+type SessionMeta struct {
+	UserAgent string
+	IpAddress string
+}
+
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
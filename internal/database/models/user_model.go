@@ -1,18 +1,21 @@
 package models
 
+import "time"
+
 type User struct {
 	Id       int    `json:"id" db:"id"`
 	Username string `json:"username" db:"username"`
 	Email    string `json:"email" db:"email"`
 	Password string `json:"password" db:"password"`
-	Salt     string `db:"salt"`
+	// BannedAt is set while an admin or moderator has banned the user.
+	BannedAt        *time.Time `json:"-" db:"banned_at"`
+	EmailVerifiedAt *time.Time `json:"-" db:"email_verified_at"`
 }
 
 type NewUser struct {
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
-	Salt     string `db:"salt"`
 }
 
 type LoginUser struct {
@@ -23,7 +26,6 @@ type LoginUser struct {
 type UpdateUser struct {
 	Username string `json:"username" db:"username"`
 	Password string `json:"password" db:"password"`
-	Salt     string `db:"salt"`
 }
 
 type UserResponse struct {
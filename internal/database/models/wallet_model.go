@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+const (
+	WalletKindUser     = "user"
+	WalletKindExternal = "external"
+	WalletKindEscrow   = "escrow"
+)
+
+const (
+	LedgerKindDeposit    = "deposit"
+	LedgerKindWithdrawal = "withdrawal"
+	LedgerKindTransfer   = "transfer"
+	LedgerKindDeal       = "deal"
+	LedgerKindHold       = "escrow_hold"
+	LedgerKindRelease    = "escrow_release"
+	LedgerKindRefund     = "escrow_refund"
+)
+
+type Wallet struct {
+	Id        int       `json:"id" db:"id"`
+	UserId    *int      `json:"user_id" db:"user_id"`
+	Kind      string    `json:"kind" db:"kind"`
+	Balance   Money     `json:"balance" db:"balance"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type LedgerEntry struct {
+	Id            int       `json:"id" db:"id"`
+	TransactionId int64     `json:"transaction_id" db:"transaction_id"`
+	WalletId      int       `json:"wallet_id" db:"wallet_id"`
+	Amount        Money     `json:"amount" db:"amount"`
+	Kind          string    `json:"kind" db:"kind"`
+	DealId        *int      `json:"deal_id" db:"deal_id"`
+	Description   string    `json:"description" db:"description"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+type NewLedgerEntry struct {
+	WalletId    int
+	Amount      Money
+	Kind        string
+	DealId      *int
+	Description string
+}
+
+type WalletOperation struct {
+	Amount Money `json:"amount"`
+}
+
+type WalletTransfer struct {
+	ToUserId int   `json:"to_user_id"`
+	Amount   Money `json:"amount"`
+}
@@ -0,0 +1,26 @@
+package database
+
+// Page is one page of a page/size paginated list together with the total
+// number of rows, so clients know how many pages there are.
+type Page[T any] struct {
+	Data    []T  `json:"data"`
+	Total   int  `json:"total"`
+	Page    int  `json:"page"`
+	Size    int  `json:"size"`
+	HasNext bool `json:"has_next"`
+}
+
+// NewPage wraps the rows of the requested page. Data is never null.
+func NewPage[T any](rows []T, total int, info PageInfo) Page[T] {
+	if rows == nil {
+		rows = []T{}
+	}
+
+	return Page[T]{
+		Data:    rows,
+		Total:   total,
+		Page:    info.PageNumber,
+		Size:    info.PageSize,
+		HasNext: info.Offset()+len(rows) < total,
+	}
+}
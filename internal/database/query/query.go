@@ -0,0 +1,295 @@
+// Package query parses the filter and sort parameters of list endpoints,
+// such as ?filter=price>=10,owner_id=3&sort=-price,name, into parameterized
+// SQL. Only fields whitelisted in a Schema can be used, and values are
+// checked against the field's type before they reach the database.
+package query
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Error describes what is wrong with a filter or sort expression. It
+// matches ErrInvalidQuery with errors.Is.
+type Error struct {
+	Message string
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+func (err *Error) Is(target error) bool {
+	return target == ErrInvalidQuery
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &Error{Message: fmt.Sprintf(format, args...)}
+}
+
+type FieldType int
+
+const (
+	String FieldType = iota
+	Int
+	Number
+	Bool
+	Time
+)
+
+func (fieldType FieldType) String() string {
+	switch fieldType {
+	case Int:
+		return "an integer"
+	case Number:
+		return "a number"
+	case Bool:
+		return "true or false"
+	case Time:
+		return "an RFC 3339 time"
+	default:
+		return "a text"
+	}
+}
+
+// Field maps a public field name to a SQL column or expression.
+type Field struct {
+	Column   string
+	Type     FieldType
+	Filter   bool
+	Sortable bool
+}
+
+// Schema is the whitelist of a resource's fields by public name.
+type Schema map[string]Field
+
+const (
+	OpEq       = "="
+	OpNe       = "!="
+	OpGt       = ">"
+	OpGe       = ">="
+	OpLt       = "<"
+	OpLe       = "<="
+	OpContains = "~"
+)
+
+// operators is ordered so that two-character operators are tried first.
+var operators = []string{OpNe, OpGe, OpLe, OpEq, OpGt, OpLt, OpContains}
+
+const (
+	maxConditions  = 10
+	maxSortFields  = 3
+	maxValueLength = 100
+)
+
+type Condition struct {
+	Field string
+	Op    string
+	Value interface{}
+}
+
+type Order struct {
+	Field string
+	Desc  bool
+}
+
+// Query is a parsed, validated filter and sort. The zero value matches
+// everything in the default order.
+type Query struct {
+	schema     Schema
+	Conditions []Condition
+	Orders     []Order
+}
+
+// Params are the raw query string values of a list request.
+type Params struct {
+	Filter string
+	Sort   string
+}
+
+// Parse validates params against the schema. Conditions are separated by
+// commas and combined with AND, e.g. "price>=10,name~lamp"; sort fields are
+// separated by commas and prefixed with "-" for descending order.
+func Parse(schema Schema, params Params) (Query, error) {
+	query := Query{schema: schema}
+
+	if filter := strings.TrimSpace(params.Filter); filter != "" {
+		expressions := strings.Split(filter, ",")
+		if len(expressions) > maxConditions {
+			return Query{}, invalid("too many filter conditions, at most %d are allowed", maxConditions)
+		}
+
+		for _, expression := range expressions {
+			condition, err := parseCondition(schema, strings.TrimSpace(expression))
+			if err != nil {
+				return Query{}, err
+			}
+			query.Conditions = append(query.Conditions, condition)
+		}
+	}
+
+	if sortParam := strings.TrimSpace(params.Sort); sortParam != "" {
+		names := strings.Split(sortParam, ",")
+		if len(names) > maxSortFields {
+			return Query{}, invalid("too many sort fields, at most %d are allowed", maxSortFields)
+		}
+
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			desc := strings.HasPrefix(name, "-")
+			name = strings.TrimPrefix(strings.TrimPrefix(name, "-"), "+")
+
+			field, ok := schema[name]
+			if !ok || !field.Sortable {
+				return Query{}, invalid("cannot sort by %q; sortable fields: %s", name, schema.names(func(field Field) bool { return field.Sortable }))
+			}
+			for _, order := range query.Orders {
+				if order.Field == name {
+					return Query{}, invalid("sort field %q is given twice", name)
+				}
+			}
+
+			query.Orders = append(query.Orders, Order{Field: name, Desc: desc})
+		}
+	}
+
+	return query, nil
+}
+
+func parseCondition(schema Schema, expression string) (Condition, error) {
+	if expression == "" {
+		return Condition{}, invalid("empty filter condition")
+	}
+
+	index := strings.IndexAny(expression, "=!<>~")
+	if index <= 0 {
+		return Condition{}, invalid("filter condition %q needs a field, an operator (=, !=, >, >=, <, <=, ~) and a value", expression)
+	}
+
+	name := strings.TrimSpace(expression[:index])
+	rest := expression[index:]
+
+	op := ""
+	for _, candidate := range operators {
+		if strings.HasPrefix(rest, candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return Condition{}, invalid("filter condition %q has an unknown operator", expression)
+	}
+	raw := strings.TrimSpace(rest[len(op):])
+
+	field, ok := schema[name]
+	if !ok || !field.Filter {
+		return Condition{}, invalid("cannot filter by %q; filterable fields: %s", name, schema.names(func(field Field) bool { return field.Filter }))
+	}
+
+	if raw == "" {
+		return Condition{}, invalid("filter condition %q has no value", expression)
+	}
+	if len(raw) > maxValueLength {
+		return Condition{}, invalid("value for %q is longer than %d characters", name, maxValueLength)
+	}
+
+	switch op {
+	case OpContains:
+		if field.Type != String {
+			return Condition{}, invalid("operator ~ only works on text fields, %q is not one", name)
+		}
+	case OpGt, OpGe, OpLt, OpLe:
+		if field.Type == String || field.Type == Bool {
+			return Condition{}, invalid("operator %s does not work on field %q", op, name)
+		}
+	}
+
+	value, err := parseValue(field.Type, raw)
+	if err != nil {
+		return Condition{}, invalid("value %q for %q must be %s", raw, name, field.Type)
+	}
+
+	return Condition{Field: name, Op: op, Value: value}, nil
+}
+
+func parseValue(fieldType FieldType, raw string) (interface{}, error) {
+	switch fieldType {
+	case Int:
+		return strconv.ParseInt(raw, 10, 64)
+	case Number:
+		return strconv.ParseFloat(raw, 64)
+	case Bool:
+		return strconv.ParseBool(raw)
+	case Time:
+		return time.Parse(time.RFC3339, raw)
+	default:
+		return raw, nil
+	}
+}
+
+func (schema Schema) names(include func(Field) bool) string {
+	var names []string
+	for name, field := range schema {
+		if include(field) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// Where returns the conditions joined with AND, numbering its placeholders
+// after args, and args with the values appended. Without conditions it
+// returns "TRUE".
+func (query Query) Where(args []interface{}) (string, []interface{}) {
+	if len(query.Conditions) == 0 {
+		return "TRUE", args
+	}
+
+	clauses := make([]string, 0, len(query.Conditions))
+	for _, condition := range query.Conditions {
+		column := query.schema[condition.Field].Column
+		value := condition.Value
+
+		sqlOp := condition.Op
+		switch condition.Op {
+		case OpNe:
+			sqlOp = "<>"
+		case OpContains:
+			sqlOp = "ILIKE"
+			value = "%" + escapeLike(value.(string)) + "%"
+		}
+
+		args = append(args, value)
+		clauses = append(clauses, column+" "+sqlOp+" $"+strconv.Itoa(len(args)))
+	}
+
+	return strings.Join(clauses, " AND "), args
+}
+
+// OrderBy returns the ORDER BY list, ending with the unique key so pages
+// stay stable between requests.
+func (query Query) OrderBy(key string) string {
+	parts := make([]string, 0, len(query.Orders)+1)
+	for _, order := range query.Orders {
+		column := query.schema[order.Field].Column
+		if order.Desc {
+			parts = append(parts, column+" DESC")
+		} else {
+			parts = append(parts, column+" ASC")
+		}
+	}
+
+	return strings.Join(append(parts, key), ", ")
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
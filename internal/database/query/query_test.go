@@ -0,0 +1,145 @@
+package query
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testSchema = Schema{
+	"id":      {Column: "t.id", Type: Int, Filter: true, Sortable: true},
+	"name":    {Column: "t.name", Type: String, Filter: true, Sortable: true},
+	"price":   {Column: "t.price / 100.0", Type: Number, Filter: true, Sortable: true},
+	"active":  {Column: "t.active", Type: Bool, Filter: true},
+	"created": {Column: "t.created_at", Type: Time, Sortable: true},
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		params Params
+	}{
+		{"unknown filter field", Params{Filter: "owner=3"}},
+		{"field not filterable", Params{Filter: "created=2026-10-18T10:00:00Z"}},
+		{"unknown sort field", Params{Sort: "owner"}},
+		{"field not sortable", Params{Sort: "active"}},
+		{"sort field twice", Params{Sort: "name,-name"}},
+		{"unknown operator", Params{Filter: "id!3"}},
+		{"missing field", Params{Filter: "=3"}},
+		{"missing operator", Params{Filter: "id"}},
+		{"missing value", Params{Filter: "id="}},
+		{"empty condition", Params{Filter: "id=1,,name=x"}},
+		{"contains on a number", Params{Filter: "price~10"}},
+		{"order on text", Params{Filter: "name>a"}},
+		{"order on bool", Params{Filter: "active<true"}},
+		{"int value", Params{Filter: "id=1.5"}},
+		{"number value", Params{Filter: "price>=ten"}},
+		{"bool value", Params{Filter: "active=yes"}},
+		{"value too long", Params{Filter: "name=" + strings.Repeat("a", maxValueLength+1)}},
+		{"too many conditions", Params{Filter: strings.Repeat("id=1,", maxConditions) + "id=1"}},
+		{"too many sort fields", Params{Sort: "id,name,price,created"}},
+
+		{"statement in filter key", Params{Filter: "name;DROP TABLE items--=x"}},
+		{"expression in filter key", Params{Filter: "1=1 OR id=1"}},
+		{"column in filter key", Params{Filter: "t.id=1"}},
+		{"quote in filter key", Params{Filter: "name'=x"}},
+		{"statement in sort key", Params{Sort: "name;DROP TABLE items"}},
+		{"direction in sort key", Params{Sort: "name DESC"}},
+		{"subquery in sort key", Params{Sort: "(SELECT 1)"}},
+		{"comment in sort key", Params{Sort: "-id--"}},
+		{"column in sort key", Params{Sort: "t.name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(testSchema, tt.params)
+			if !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("got %v, want ErrInvalidQuery", err)
+			}
+		})
+	}
+}
+
+func TestWhere(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   string
+		args     []interface{}
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "no conditions",
+			args:     []interface{}{7},
+			wantSQL:  "TRUE",
+			wantArgs: []interface{}{7},
+		},
+		{
+			name:     "numbered from one",
+			filter:   "id=3,active=true",
+			wantSQL:  "t.id = $1 AND t.active = $2",
+			wantArgs: []interface{}{int64(3), true},
+		},
+		{
+			name:     "numbered after existing args",
+			filter:   "price>=10,id!=4",
+			args:     []interface{}{5, "color", "red"},
+			wantSQL:  "t.price / 100.0 >= $4 AND t.id <> $5",
+			wantArgs: []interface{}{5, "color", "red", 10.0, int64(4)},
+		},
+		{
+			name:     "contains escapes wildcards",
+			filter:   `name~50%_off\`,
+			wantSQL:  "t.name ILIKE $1",
+			wantArgs: []interface{}{`%50\%\_off\\%`},
+		},
+		{
+			name:     "value stays a parameter",
+			filter:   "name=x' OR '1'='1",
+			wantSQL:  "t.name = $1",
+			wantArgs: []interface{}{"x' OR '1'='1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Parse(testSchema, Params{Filter: tt.filter})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sql, args := q.Where(tt.args)
+			if sql != tt.wantSQL {
+				t.Errorf("sql = %q, want %q", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	tests := []struct {
+		sort string
+		want string
+	}{
+		{"", "t.id"},
+		{"name", "t.name ASC, t.id"},
+		{"-price,+created", "t.price / 100.0 DESC, t.created_at ASC, t.id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			q, err := Parse(testSchema, Params{Sort: tt.sort})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := q.OrderBy("t.id"); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
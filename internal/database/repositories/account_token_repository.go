@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"time"
+
+	"market/internal/database"
+	"market/internal/database/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type AccountTokenRepo interface {
+	Create(userId int, purpose string, tokenHash string, expiresAt time.Time) error
+	GetByHashForUpdate(purpose string, tokenHash string) (models.AccountToken, error)
+	MarkUsed(id int) error
+	InvalidateForUser(userId int, purpose string) error
+	WithTx(tx *sqlx.Tx) AccountTokenRepo
+}
+
+type AccountTokenRepository struct {
+	DB database.Executor
+}
+
+func (repo *AccountTokenRepository) WithTx(tx *sqlx.Tx) AccountTokenRepo {
+	return &AccountTokenRepository{DB: tx}
+}
+
+func (repo *AccountTokenRepository) Create(userId int, purpose string, tokenHash string, expiresAt time.Time) error {
+	query := "INSERT INTO account_tokens (user_id, purpose, token_hash, expires_at) VALUES ($1, $2, $3, $4)"
+
+	_, err := repo.DB.Exec(query, userId, purpose, tokenHash, expiresAt)
+
+	return err
+}
+
+func (repo *AccountTokenRepository) GetByHashForUpdate(purpose string, tokenHash string) (models.AccountToken, error) {
+	query := "SELECT * FROM account_tokens WHERE purpose = $1 AND token_hash = $2 FOR UPDATE"
+
+	var token models.AccountToken
+	err := repo.DB.Get(&token, query, purpose, tokenHash)
+
+	return token, err
+}
+
+func (repo *AccountTokenRepository) MarkUsed(id int) error {
+	query := "UPDATE account_tokens SET used_at = NOW() WHERE id = $1"
+
+	_, err := repo.DB.Exec(query, id)
+
+	return err
+}
+
+// InvalidateForUser uses up every outstanding token of the purpose, so only
+// the most recently mailed link works.
+func (repo *AccountTokenRepository) InvalidateForUser(userId int, purpose string) error {
+	query := "UPDATE account_tokens SET used_at = NOW() WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL"
+
+	_, err := repo.DB.Exec(query, userId, purpose)
+
+	return err
+}
@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"market/internal/database"
+	"market/internal/database/models"
+
+	"github.com/lib/pq"
+)
+
+type APIKeyRepo interface {
+	Create(userId int, prefix string, keyHash string, newKey models.NewAPIKey) (models.APIKey, error)
+	GetByHash(keyHash string) (models.APIKey, error)
+	GetByUser(userId int) ([]models.APIKey, error)
+	Revoke(id int, userId int) (bool, error)
+	RevokeAllForUser(userId int) error
+	Touch(id int) error
+}
+
+type APIKeyRepository struct {
+	DB database.Executor
+}
+
+func (repo *APIKeyRepository) Create(userId int, prefix string, keyHash string, newKey models.NewAPIKey) (models.APIKey, error) {
+	query := `INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING *`
+
+	var key models.APIKey
+	err := repo.DB.Get(&key, query, userId, newKey.Name, prefix, keyHash, pq.Array(newKey.Scopes), newKey.ExpiresAt)
+
+	return key, err
+}
+
+func (repo *APIKeyRepository) GetByHash(keyHash string) (models.APIKey, error) {
+	query := "SELECT * FROM api_keys WHERE key_hash = $1"
+
+	var key models.APIKey
+	err := repo.DB.Get(&key, query, keyHash)
+
+	return key, err
+}
+
+func (repo *APIKeyRepository) GetByUser(userId int) ([]models.APIKey, error) {
+	query := "SELECT * FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC, id DESC"
+
+	var keys []models.APIKey
+	err := repo.DB.Select(&keys, query, userId)
+
+	return keys, err
+}
+
+// Revoke revokes the user's key and reports whether it existed.
+func (repo *APIKeyRepository) Revoke(id int, userId int) (bool, error) {
+	query := "UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL"
+
+	result, err := repo.DB.Exec(query, id, userId)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := result.RowsAffected()
+
+	return count > 0, err
+}
+
+// RevokeAllForUser revokes every key the user still has, e.g. after their
+// password changed.
+func (repo *APIKeyRepository) RevokeAllForUser(userId int) error {
+	query := "UPDATE api_keys SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL"
+
+	_, err := repo.DB.Exec(query, userId)
+
+	return err
+}
+
+// Touch records that the key was used, at most once a minute.
+func (repo *APIKeyRepository) Touch(id int) error {
+	query := `UPDATE api_keys SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+
+	_, err := repo.DB.Exec(query, id)
+
+	return err
+}
@@ -0,0 +1,161 @@
+package repositories
+
+import (
+	"time"
+
+	"market/internal/database"
+	"market/internal/database/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type AuctionRepo interface {
+	Create(auction models.NewAuction) (models.Auction, error)
+	Get(id int) (models.Auction, error)
+	GetForUpdate(id int) (models.Auction, error)
+	GetOpen(page database.PageInfo) ([]models.Auction, error)
+	CountOpen() (int, error)
+	GetExpiredForUpdate() (models.Auction, error)
+	ExtendEnd(id int, endsAt time.Time) error
+	Close(id int, status string, dealId *int) error
+	CreateBid(auctionId int, userId int, amount models.Money) (models.Bid, error)
+	GetBids(auctionId int, page database.PageInfo) ([]models.Bid, error)
+	CountBids(auctionId int) (int, error)
+	GetHighestBid(auctionId int) (models.Bid, error)
+	WithTx(tx *sqlx.Tx) AuctionRepo
+}
+
+const auctionColumns = `id, item_id, seller_id,
+	start_price AS "start_price.amount", currency AS "start_price.currency",
+	reserve_price AS "reserve_price.amount", currency AS "reserve_price.currency",
+	increment AS "increment.amount", currency AS "increment.currency",
+	ends_at, status, deal_id, created_at`
+
+const bidColumns = `id, auction_id, user_id, amount AS "amount.amount", currency AS "amount.currency", created_at`
+
+type AuctionRepository struct {
+	DB database.Executor
+}
+
+func (repo *AuctionRepository) WithTx(tx *sqlx.Tx) AuctionRepo {
+	return &AuctionRepository{DB: tx}
+}
+
+func (repo *AuctionRepository) Create(newAuction models.NewAuction) (models.Auction, error) {
+	query := `INSERT INTO auctions (item_id, seller_id, start_price, reserve_price, increment, currency, ends_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING ` + auctionColumns
+
+	var auction models.Auction
+	err := repo.DB.Get(&auction, query, newAuction.ItemId, newAuction.SellerId, newAuction.StartPrice.Amount,
+		newAuction.ReservePrice.Amount, newAuction.Increment.Amount, newAuction.StartPrice.Currency, newAuction.EndsAt)
+
+	return auction, err
+}
+
+func (repo *AuctionRepository) Get(id int) (models.Auction, error) {
+	query := "SELECT " + auctionColumns + " FROM auctions WHERE id = $1"
+
+	var auction models.Auction
+	err := repo.DB.Get(&auction, query, id)
+
+	return auction, err
+}
+
+// GetForUpdate locks the auction row until the surrounding transaction ends.
+func (repo *AuctionRepository) GetForUpdate(id int) (models.Auction, error) {
+	query := "SELECT " + auctionColumns + " FROM auctions WHERE id = $1 FOR UPDATE"
+
+	var auction models.Auction
+	err := repo.DB.Get(&auction, query, id)
+
+	return auction, err
+}
+
+func (repo *AuctionRepository) GetOpen(page database.PageInfo) ([]models.Auction, error) {
+	query := "SELECT " + auctionColumns + " FROM auctions WHERE status = 'open' ORDER BY ends_at, id LIMIT $1 OFFSET $2"
+
+	offset := page.Offset()
+
+	var auctions []models.Auction
+	err := repo.DB.Select(&auctions, query, page.PageSize, offset)
+
+	return auctions, err
+}
+
+func (repo *AuctionRepository) CountOpen() (int, error) {
+	query := "SELECT COUNT(*) FROM auctions WHERE status = 'open'"
+
+	var count int
+	err := repo.DB.Get(&count, query)
+
+	return count, err
+}
+
+// GetExpiredForUpdate locks one open auction past its end time, skipping
+// auctions another closer is already handling.
+func (repo *AuctionRepository) GetExpiredForUpdate() (models.Auction, error) {
+	query := "SELECT " + auctionColumns + ` FROM auctions
+		WHERE status = 'open' AND ends_at <= NOW()
+		ORDER BY ends_at LIMIT 1 FOR UPDATE SKIP LOCKED`
+
+	var auction models.Auction
+	err := repo.DB.Get(&auction, query)
+
+	return auction, err
+}
+
+func (repo *AuctionRepository) ExtendEnd(id int, endsAt time.Time) error {
+	query := "UPDATE auctions SET ends_at = $1 WHERE id = $2"
+
+	_, err := repo.DB.Exec(query, endsAt, id)
+
+	return err
+}
+
+func (repo *AuctionRepository) Close(id int, status string, dealId *int) error {
+	query := "UPDATE auctions SET status = $1, deal_id = $2 WHERE id = $3"
+
+	_, err := repo.DB.Exec(query, status, dealId, id)
+
+	return err
+}
+
+func (repo *AuctionRepository) CreateBid(auctionId int, userId int, amount models.Money) (models.Bid, error) {
+	query := "INSERT INTO bids (auction_id, user_id, amount, currency) VALUES ($1, $2, $3, $4) RETURNING " + bidColumns
+
+	var bid models.Bid
+	err := repo.DB.Get(&bid, query, auctionId, userId, amount.Amount, amount.Currency)
+
+	return bid, err
+}
+
+func (repo *AuctionRepository) GetBids(auctionId int, page database.PageInfo) ([]models.Bid, error) {
+	query := "SELECT " + bidColumns + " FROM bids WHERE auction_id = $1 ORDER BY amount DESC, id LIMIT $2 OFFSET $3"
+
+	offset := page.Offset()
+
+	var bids []models.Bid
+	err := repo.DB.Select(&bids, query, auctionId, page.PageSize, offset)
+
+	return bids, err
+}
+
+func (repo *AuctionRepository) CountBids(auctionId int) (int, error) {
+	query := "SELECT COUNT(*) FROM bids WHERE auction_id = $1"
+
+	var count int
+	err := repo.DB.Get(&count, query, auctionId)
+
+	return count, err
+}
+
+// GetHighestBid returns the leading bid; among equal amounts the earliest
+// wins.
+func (repo *AuctionRepository) GetHighestBid(auctionId int) (models.Bid, error) {
+	query := "SELECT " + bidColumns + " FROM bids WHERE auction_id = $1 ORDER BY amount DESC, id LIMIT 1"
+
+	var bid models.Bid
+	err := repo.DB.Get(&bid, query, auctionId)
+
+	return bid, err
+}
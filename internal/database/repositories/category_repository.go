@@ -0,0 +1,124 @@
+package repositories
+
+import (
+	"market/internal/database"
+	"market/internal/database/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type CategoryRepo interface {
+	Create(category models.NewCategory) (models.Category, error)
+	Get(id int) (models.Category, error)
+	GetAll() ([]models.Category, error)
+	Update(category models.NewCategory) error
+	Delete(id int) error
+	CountChildren(id int) (int, error)
+	GetAncestors(id int) ([]models.Category, error)
+	GetAttributes(categoryIds []int) ([]models.CategoryAttribute, error)
+	ReplaceAttributes(categoryId int, attributes []models.NewCategoryAttribute) error
+	WithTx(tx *sqlx.Tx) CategoryRepo
+}
+
+type CategoryRepository struct {
+	DB database.Executor
+}
+
+func (repo *CategoryRepository) WithTx(tx *sqlx.Tx) CategoryRepo {
+	return &CategoryRepository{DB: tx}
+}
+
+func (repo *CategoryRepository) Create(category models.NewCategory) (models.Category, error) {
+	query := "INSERT INTO categories (name, slug, parent_id) VALUES ($1, $2, $3) RETURNING *"
+
+	var created models.Category
+	err := repo.DB.Get(&created, query, category.Name, category.Slug, category.ParentId)
+
+	return created, err
+}
+
+func (repo *CategoryRepository) Get(id int) (models.Category, error) {
+	query := "SELECT * FROM categories WHERE id = $1"
+
+	var category models.Category
+	err := repo.DB.Get(&category, query, id)
+
+	return category, err
+}
+
+func (repo *CategoryRepository) GetAll() ([]models.Category, error) {
+	query := "SELECT * FROM categories ORDER BY parent_id NULLS FIRST, name"
+
+	var categories []models.Category
+	err := repo.DB.Select(&categories, query)
+
+	return categories, err
+}
+
+func (repo *CategoryRepository) Update(category models.NewCategory) error {
+	query := "UPDATE categories SET name = $1, slug = $2, parent_id = $3 WHERE id = $4"
+
+	_, err := repo.DB.Exec(query, category.Name, category.Slug, category.ParentId, category.Id)
+
+	return err
+}
+
+func (repo *CategoryRepository) Delete(id int) error {
+	query := "DELETE FROM categories WHERE id = $1"
+
+	_, err := repo.DB.Exec(query, id)
+
+	return err
+}
+
+func (repo *CategoryRepository) CountChildren(id int) (int, error) {
+	query := "SELECT COUNT(*) FROM categories WHERE parent_id = $1"
+
+	var count int
+	err := repo.DB.Get(&count, query, id)
+
+	return count, err
+}
+
+// GetAncestors returns the category and its parents, root first.
+func (repo *CategoryRepository) GetAncestors(id int) ([]models.Category, error) {
+	query := `WITH RECURSIVE chain AS (
+			SELECT id, name, slug, parent_id, 0 AS depth FROM categories WHERE id = $1
+			UNION ALL
+			SELECT c.id, c.name, c.slug, c.parent_id, chain.depth + 1
+			FROM categories c JOIN chain ON c.id = chain.parent_id
+			WHERE chain.depth < 32
+		)
+		SELECT id, name, slug, parent_id FROM chain ORDER BY depth DESC`
+
+	var categories []models.Category
+	err := repo.DB.Select(&categories, query, id)
+
+	return categories, err
+}
+
+func (repo *CategoryRepository) GetAttributes(categoryIds []int) ([]models.CategoryAttribute, error) {
+	query := "SELECT * FROM category_attributes WHERE category_id = ANY($1) ORDER BY id"
+
+	var attributes []models.CategoryAttribute
+	err := repo.DB.Select(&attributes, query, pq.Array(categoryIds))
+
+	return attributes, err
+}
+
+func (repo *CategoryRepository) ReplaceAttributes(categoryId int, attributes []models.NewCategoryAttribute) error {
+	if _, err := repo.DB.Exec("DELETE FROM category_attributes WHERE category_id = $1", categoryId); err != nil {
+		return err
+	}
+
+	query := "INSERT INTO category_attributes (category_id, name, type, required, options) VALUES ($1, $2, $3, $4, $5)"
+	for _, attribute := range attributes {
+		_, err := repo.DB.Exec(query, categoryId, attribute.Name, attribute.Type, attribute.Required, pq.Array(attribute.Options))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,8 +1,12 @@
 package repositories
 
 import (
+	"errors"
+	"strconv"
+
 	"market/internal/database"
 	"market/internal/database/models"
+	"market/internal/database/query"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -10,26 +14,50 @@ import (
 type DealRepo interface {
 	Create(deal models.NewDeal) (models.Deal, error)
 	Get(id int) (models.Deal, error)
-	GetAll(page database.PageInfo) ([]models.Deal, error)
+	GetAll(page database.PageInfo, q query.Query) ([]models.Deal, error)
+	GetAllByCursor(cursor database.CursorInfo, q query.Query) ([]models.Deal, error)
+	Count(q query.Query) (int, error)
 	Update(deal models.Deal) error
 	Delete(id int) error
+	GetForUpdate(id int) (models.Deal, error)
+	GetOpenByItemForUpdate(itemId int, exceptId int) ([]models.Deal, error)
+	UpdateStatus(id int, from string, to string) error
+	AddEvent(event models.DealEvent) error
+	GetEvents(dealId int) ([]models.DealEvent, error)
+	WithTx(tx *sqlx.Tx) DealRepo
 }
 
+var ErrStaleDealStatus = errors.New("deal status changed concurrently")
+
 type DealRepository struct {
-	DB *sqlx.DB
+	DB database.Executor
+}
+
+const dealSelect = `SELECT d.id, d.status, d.price AS "price.amount", d.currency AS "price.currency",
+	i.id AS "item.id", i.name AS "item.name", i.owner_id AS "item.owner_id",
+	i.price AS "item.price.amount", i.currency AS "item.price.currency",
+	u.id AS "user.id", u.username AS "user.username",
+	COALESCE(s.id, 0) AS "seller.id", COALESCE(s.username, '') AS "seller.username"
+	FROM deals d
+	JOIN items i ON i.id = d.item_id
+	JOIN users u ON u.id = d.user_id
+	LEFT JOIN users s ON s.id = d.seller_id`
+
+func (repo *DealRepository) WithTx(tx *sqlx.Tx) DealRepo {
+	return &DealRepository{DB: tx}
 }
 
 func (repo *DealRepository) Create(newDeal models.NewDeal) (models.Deal, error) {
-	query := "INSERT INTO deals (item_id, user_id, price) VALUES ($1, $2, $3) returning id"
+	query := "INSERT INTO deals (item_id, user_id, seller_id, price, currency, status) VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6) returning id"
 
 	var dealId int
-	err := repo.DB.QueryRow(query, newDeal.Item.Id, newDeal.User.Id, newDeal.Price).Scan(&dealId)
+	err := repo.DB.QueryRow(query, newDeal.Item.Id, newDeal.User.Id, newDeal.Seller.Id, newDeal.Price.Amount, newDeal.Price.Currency, newDeal.Status).Scan(&dealId)
 
-	return models.Deal{Id: dealId, Item: newDeal.Item, User: newDeal.User, Price: newDeal.Price}, err
+	return models.Deal{Id: dealId, Item: newDeal.Item, User: newDeal.User, Seller: newDeal.Seller, Price: newDeal.Price, Status: newDeal.Status}, err
 }
 
 func (repo *DealRepository) Get(id int) (models.Deal, error) {
-	query := "SELECT * FROM deals WHERE id = $1"
+	query := dealSelect + " WHERE d.id = $1"
 
 	var deal models.Deal
 	err := repo.DB.Get(&deal, query, id)
@@ -37,21 +65,52 @@ func (repo *DealRepository) Get(id int) (models.Deal, error) {
 	return deal, err
 }
 
-func (repo *DealRepository) GetAll(page database.PageInfo) ([]models.Deal, error) {
-	query := "SELECT * FROM deals LIMIT $1 OFFSET $2"
+func (repo *DealRepository) GetAll(page database.PageInfo, q query.Query) ([]models.Deal, error) {
+	where, args := q.Where(nil)
+	query := dealSelect + " WHERE " + where + " ORDER BY " + q.OrderBy("d.id") +
+		" LIMIT $" + strconv.Itoa(len(args)+1) + " OFFSET $" + strconv.Itoa(len(args)+2)
 
 	offset := page.Offset()
 
 	var deals []models.Deal
-	err := repo.DB.Select(&deals, query, page.PageSize, offset)
+	err := repo.DB.Select(&deals, query, append(args, page.PageSize, offset)...)
+
+	return deals, err
+}
+
+// Count returns the number of deals matching the query's filter, joined
+// like dealSelect so it agrees with GetAll.
+func (repo *DealRepository) Count(q query.Query) (int, error) {
+	where, args := q.Where(nil)
+	query := `SELECT COUNT(*) FROM deals d
+	JOIN items i ON i.id = d.item_id
+	JOIN users u ON u.id = d.user_id
+	WHERE ` + where
+
+	var count int
+	err := repo.DB.Get(&count, query, args...)
+
+	return count, err
+}
+
+// GetAllByCursor returns up to cursor.Limit() deals after or before the
+// cursor, in the key order database.NewCursorPage expects.
+func (repo *DealRepository) GetAllByCursor(cursor database.CursorInfo, q query.Query) ([]models.Deal, error) {
+	where, args := q.Where(nil)
+	keyset, order, args := cursor.Keyset("d.id", args)
+	query := dealSelect + " WHERE " + where + " AND " + keyset + " ORDER BY " + order +
+		" LIMIT $" + strconv.Itoa(len(args)+1)
+
+	var deals []models.Deal
+	err := repo.DB.Select(&deals, query, append(args, cursor.Limit())...)
 
 	return deals, err
 }
 
 func (repo *DealRepository) Update(deal models.Deal) error {
-	query := "UPDATE deals SET item_id = $1, user_id = $2, price = $3 WHERE id = $4"
+	query := "UPDATE deals SET item_id = $1, user_id = $2, price = $3, currency = $4 WHERE id = $5"
 
-	_, err := repo.DB.Exec(query, deal.Item.Id, deal.User.Id, deal.Price, deal.Id)
+	_, err := repo.DB.Exec(query, deal.Item.Id, deal.User.Id, deal.Price.Amount, deal.Price.Currency, deal.Id)
 
 	return err
 }
@@ -63,3 +122,66 @@ func (repo *DealRepository) Delete(id int) error {
 
 	return err
 }
+
+// GetForUpdate locks the deal row until the surrounding transaction ends.
+func (repo *DealRepository) GetForUpdate(id int) (models.Deal, error) {
+	query := dealSelect + " WHERE d.id = $1 FOR UPDATE OF d"
+
+	var deal models.Deal
+	err := repo.DB.Get(&deal, query, id)
+
+	return deal, err
+}
+
+// GetOpenByItemForUpdate locks the item's other deals that are not yet
+// completed or cancelled. Deals another transaction holds are skipped; that
+// transaction sees the item's new owner once it gets the item lock.
+func (repo *DealRepository) GetOpenByItemForUpdate(itemId int, exceptId int) ([]models.Deal, error) {
+	query := dealSelect + ` WHERE d.item_id = $1 AND d.id <> $2
+		AND d.status IN ('offered', 'accepted', 'paid', 'disputed')
+		ORDER BY d.id FOR UPDATE OF d SKIP LOCKED`
+
+	var deals []models.Deal
+	err := repo.DB.Select(&deals, query, itemId, exceptId)
+
+	return deals, err
+}
+
+// UpdateStatus moves the deal from one status to another and fails with
+// ErrStaleDealStatus if the deal is no longer in the expected status.
+func (repo *DealRepository) UpdateStatus(id int, from string, to string) error {
+	query := "UPDATE deals SET status = $1 WHERE id = $2 AND status = $3"
+
+	result, err := repo.DB.Exec(query, to, id, from)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrStaleDealStatus
+	}
+
+	return nil
+}
+
+func (repo *DealRepository) AddEvent(event models.DealEvent) error {
+	query := "INSERT INTO deal_events (deal_id, from_status, to_status, actor_id) VALUES ($1, $2, $3, $4)"
+
+	_, err := repo.DB.Exec(query, event.DealId, event.FromStatus, event.ToStatus, event.ActorId)
+
+	return err
+}
+
+func (repo *DealRepository) GetEvents(dealId int) ([]models.DealEvent, error) {
+	query := "SELECT * FROM deal_events WHERE deal_id = $1 ORDER BY id"
+
+	var events []models.DealEvent
+	err := repo.DB.Select(&events, query, dealId)
+
+	return events, err
+}
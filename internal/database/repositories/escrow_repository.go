@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"errors"
+
+	"market/internal/database"
+	"market/internal/database/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type EscrowRepo interface {
+	Create(hold models.EscrowHold) (models.EscrowHold, error)
+	GetByDeal(dealId int) (models.EscrowHold, error)
+	GetByDealForUpdate(dealId int) (models.EscrowHold, error)
+	GetDueDealIds() ([]int, error)
+	Settle(id int, status string, transactionId int64) error
+	WithTx(tx *sqlx.Tx) EscrowRepo
+}
+
+var ErrStaleEscrowHold = errors.New("escrow hold is no longer held")
+
+const escrowColumns = `id, deal_id, buyer_id, seller_id, amount AS "amount.amount", currency AS "amount.currency",
+	status, release_at, hold_transaction_id, settle_transaction_id, created_at, settled_at`
+
+type EscrowRepository struct {
+	DB database.Executor
+}
+
+func (repo *EscrowRepository) WithTx(tx *sqlx.Tx) EscrowRepo {
+	return &EscrowRepository{DB: tx}
+}
+
+func (repo *EscrowRepository) Create(hold models.EscrowHold) (models.EscrowHold, error) {
+	query := `INSERT INTO escrow_holds (deal_id, buyer_id, seller_id, amount, currency, release_at, hold_transaction_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING ` + escrowColumns
+
+	var created models.EscrowHold
+	err := repo.DB.Get(&created, query, hold.DealId, hold.BuyerId, hold.SellerId, hold.Amount.Amount, hold.Amount.Currency,
+		hold.ReleaseAt, hold.HoldTransactionId)
+
+	return created, err
+}
+
+func (repo *EscrowRepository) GetByDeal(dealId int) (models.EscrowHold, error) {
+	query := "SELECT " + escrowColumns + " FROM escrow_holds WHERE deal_id = $1"
+
+	var hold models.EscrowHold
+	err := repo.DB.Get(&hold, query, dealId)
+
+	return hold, err
+}
+
+// GetByDealForUpdate locks the deal's hold until the surrounding transaction
+// ends.
+func (repo *EscrowRepository) GetByDealForUpdate(dealId int) (models.EscrowHold, error) {
+	query := "SELECT " + escrowColumns + " FROM escrow_holds WHERE deal_id = $1 FOR UPDATE"
+
+	var hold models.EscrowHold
+	err := repo.DB.Get(&hold, query, dealId)
+
+	return hold, err
+}
+
+// GetDueDealIds returns the paid deals whose held money is due for automatic
+// release. Disputed deals are left for the parties or an admin to resolve.
+func (repo *EscrowRepository) GetDueDealIds() ([]int, error) {
+	query := `SELECT h.deal_id FROM escrow_holds h
+		JOIN deals d ON d.id = h.deal_id
+		WHERE h.status = 'held' AND h.release_at <= NOW() AND d.status = 'paid'
+		ORDER BY h.release_at`
+
+	var dealIds []int
+	err := repo.DB.Select(&dealIds, query)
+
+	return dealIds, err
+}
+
+func (repo *EscrowRepository) Settle(id int, status string, transactionId int64) error {
+	query := `UPDATE escrow_holds SET status = $1, settle_transaction_id = $2, settled_at = NOW()
+		WHERE id = $3 AND status = 'held'`
+
+	result, err := repo.DB.Exec(query, status, transactionId, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrStaleEscrowHold
+	}
+
+	return nil
+}
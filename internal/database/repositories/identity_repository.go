@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"market/internal/database"
+	"market/internal/database/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IdentityRepo interface {
+	Get(provider string, subject string) (models.UserIdentity, error)
+	Create(identity models.UserIdentity) error
+	TouchLogin(id int, email string) error
+	CreateState(state models.OIDCLoginState) error
+	TakeState(stateHash string) (models.OIDCLoginState, error)
+	DeleteExpiredStates() (int, error)
+	WithTx(tx *sqlx.Tx) IdentityRepo
+}
+
+type IdentityRepository struct {
+	DB database.Executor
+}
+
+func (repo *IdentityRepository) WithTx(tx *sqlx.Tx) IdentityRepo {
+	return &IdentityRepository{DB: tx}
+}
+
+func (repo *IdentityRepository) Get(provider string, subject string) (models.UserIdentity, error) {
+	query := "SELECT * FROM user_identities WHERE provider = $1 AND subject = $2"
+
+	var identity models.UserIdentity
+	err := repo.DB.Get(&identity, query, provider, subject)
+
+	return identity, err
+}
+
+func (repo *IdentityRepository) Create(identity models.UserIdentity) error {
+	query := "INSERT INTO user_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)"
+
+	_, err := repo.DB.Exec(query, identity.UserId, identity.Provider, identity.Subject, identity.Email)
+
+	return err
+}
+
+func (repo *IdentityRepository) TouchLogin(id int, email string) error {
+	query := "UPDATE user_identities SET email = $1, last_login_at = NOW() WHERE id = $2"
+
+	_, err := repo.DB.Exec(query, email, id)
+
+	return err
+}
+
+func (repo *IdentityRepository) CreateState(state models.OIDCLoginState) error {
+	query := `INSERT INTO oidc_login_states (state_hash, provider, nonce, code_verifier, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := repo.DB.Exec(query, state.StateHash, state.Provider, state.Nonce, state.CodeVerifier, state.ExpiresAt)
+
+	return err
+}
+
+// TakeState deletes and returns a login state, so each can be used once.
+func (repo *IdentityRepository) TakeState(stateHash string) (models.OIDCLoginState, error) {
+	query := "DELETE FROM oidc_login_states WHERE state_hash = $1 RETURNING *"
+
+	var state models.OIDCLoginState
+	err := repo.DB.Get(&state, query, stateHash)
+
+	return state, err
+}
+
+// DeleteExpiredStates drops logins that were started but never finished.
+func (repo *IdentityRepository) DeleteExpiredStates() (int, error) {
+	query := "DELETE FROM oidc_login_states WHERE expires_at < NOW()"
+
+	result, err := repo.DB.Exec(query)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := result.RowsAffected()
+
+	return int(count), err
+}
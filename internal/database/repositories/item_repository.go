@@ -1,8 +1,13 @@
 package repositories
 
 import (
+	"html"
 	"market/internal/database"
 	"market/internal/database/models"
+	"market/internal/database/query"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -10,34 +15,60 @@ import (
 type ItemRepo interface {
 	Create(item models.NewItem) (models.Item, error)
 	Get(id int) (models.Item, error)
-	GetAll(page database.PageInfo) ([]models.Item, error)
+	GetAll(page database.PageInfo, filter models.ItemFilter, q query.Query) ([]models.Item, error)
+	GetAllByCursor(cursor database.CursorInfo, filter models.ItemFilter, q query.Query) ([]models.Item, error)
+	Count(filter models.ItemFilter, q query.Query) (int, error)
+	Search(query string, terms string, page database.PageInfo, filter models.ItemFilter) ([]models.ItemSearchResult, error)
+	CountSearch(query string, terms string, filter models.ItemFilter) (int, error)
 	Update(item models.Item) error
 	Delete(id int) error
+	GetForUpdate(id int) (models.Item, error)
+	UpdateOwner(id int, ownerId int) error
+	GetOwnedByNameForUpdate(ownerId int, name string) (models.Item, error)
+	CountOwnedByName(ownerId int, name string) (int, error)
+	HasActiveDeal(id int) (bool, error)
+	HasOpenAuction(id int) (bool, error)
+	WithTx(tx *sqlx.Tx) ItemRepo
 }
 
+const itemColumns = `id, name, owner_id, price AS "price.amount", currency AS "price.currency", category_id, attributes`
+
 type ItemRepository struct {
-	DB *sqlx.DB
+	DB database.Executor
+}
+
+func (repo *ItemRepository) WithTx(tx *sqlx.Tx) ItemRepo {
+	return &ItemRepository{DB: tx}
 }
 
 func (repo *ItemRepository) Create(newItem models.NewItem) (models.Item, error) {
-	query := "INSERT INTO items (name, price, owner_id) VALUES ($1, $2, $3) returning id"
+	query := `INSERT INTO items (name, price, currency, owner_id, category_id, attributes)
+		VALUES ($1, $2, $3, $4, $5, $6) returning id`
 
 	var itemId int
-	err := repo.DB.QueryRow(query, newItem.Name, newItem.Price, newItem.OwnerId).Scan(&itemId)
+	err := repo.DB.QueryRow(query, newItem.Name, newItem.Price.Amount, newItem.Price.Currency, newItem.OwnerId,
+		newItem.CategoryId, newItem.Attributes).Scan(&itemId)
 
-	return models.Item{Id: itemId, Name: newItem.Name, Price: newItem.Price, OwnerId: newItem.OwnerId}, err
+	return models.Item{
+		Id:         itemId,
+		Name:       newItem.Name,
+		Price:      newItem.Price,
+		OwnerId:    newItem.OwnerId,
+		CategoryId: newItem.CategoryId,
+		Attributes: newItem.Attributes,
+	}, err
 }
 
 func (repo *ItemRepository) Update(item models.Item) error {
-	query := "UPDATE items SET name = $1, price = $2 WHERE id = $3"
+	query := "UPDATE items SET name = $1, price = $2, currency = $3, category_id = $4, attributes = $5 WHERE id = $6"
 
-	_, err := repo.DB.Exec(query, item.Name, item.Price, item.Id)
+	_, err := repo.DB.Exec(query, item.Name, item.Price.Amount, item.Price.Currency, item.CategoryId, item.Attributes, item.Id)
 
 	return err
 }
 
 func (repo *ItemRepository) Get(id int) (models.Item, error) {
-	query := "SELECT * FROM items WHERE id = $1"
+	query := "SELECT " + itemColumns + " FROM items WHERE id = $1"
 
 	var item models.Item
 	err := repo.DB.Get(&item, query, id)
@@ -45,17 +76,197 @@ func (repo *ItemRepository) Get(id int) (models.Item, error) {
 	return item, err
 }
 
-func (repo *ItemRepository) GetAll(page database.PageInfo) ([]models.Item, error) {
-	query := "SELECT * FROM items LIMIT $1 OFFSET $2"
+// GetForUpdate locks the item row until the surrounding transaction ends.
+func (repo *ItemRepository) GetForUpdate(id int) (models.Item, error) {
+	query := "SELECT " + itemColumns + " FROM items WHERE id = $1 FOR UPDATE"
+
+	var item models.Item
+	err := repo.DB.Get(&item, query, id)
+
+	return item, err
+}
+
+func (repo *ItemRepository) UpdateOwner(id int, ownerId int) error {
+	query := "UPDATE items SET owner_id = $1 WHERE id = $2"
+
+	_, err := repo.DB.Exec(query, ownerId, id)
+
+	return err
+}
+
+// uncommittedItem matches items that are free to sell: not in an open
+// auction and not escrowed for a paid or disputed deal.
+const uncommittedItem = `NOT EXISTS (SELECT 1 FROM auctions a WHERE a.item_id = items.id AND a.status = 'open')
+	AND NOT EXISTS (SELECT 1 FROM deals d WHERE d.item_id = items.id AND d.status IN ('paid', 'disputed'))`
+
+// GetOwnedByNameForUpdate locks one of the owner's uncommitted items with the
+// given name, skipping items already locked by other transactions.
+func (repo *ItemRepository) GetOwnedByNameForUpdate(ownerId int, name string) (models.Item, error) {
+	query := "SELECT " + itemColumns + " FROM items WHERE owner_id = $1 AND name = $2 AND " + uncommittedItem +
+		" ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED"
+
+	var item models.Item
+	err := repo.DB.Get(&item, query, ownerId, name)
+
+	return item, err
+}
+
+// CountOwnedByName counts the owner's uncommitted items with the given name.
+func (repo *ItemRepository) CountOwnedByName(ownerId int, name string) (int, error) {
+	query := "SELECT COUNT(*) FROM items WHERE owner_id = $1 AND name = $2 AND " + uncommittedItem
+
+	var count int
+	err := repo.DB.Get(&count, query, ownerId, name)
+
+	return count, err
+}
+
+// HasActiveDeal reports whether the seller has agreed to a deal on the item
+// that is not yet completed or cancelled. Offered deals only ask the seller
+// and do not count.
+func (repo *ItemRepository) HasActiveDeal(id int) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM deals WHERE item_id = $1 AND status IN ('accepted', 'paid', 'disputed'))"
+
+	var exists bool
+	err := repo.DB.Get(&exists, query, id)
+
+	return exists, err
+}
+
+func (repo *ItemRepository) HasOpenAuction(id int) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM auctions WHERE item_id = $1 AND status = 'open')"
+
+	var exists bool
+	err := repo.DB.Get(&exists, query, id)
+
+	return exists, err
+}
+
+func (repo *ItemRepository) GetAll(page database.PageInfo, filter models.ItemFilter, q query.Query) ([]models.Item, error) {
+	where, args := itemFilterSQL(filter)
+	conditions, args := q.Where(args)
+	query := "SELECT " + itemColumns + " FROM items WHERE " + where + " AND " + conditions +
+		" ORDER BY " + q.OrderBy("id") + " LIMIT $" + strconv.Itoa(len(args)+1) + " OFFSET $" + strconv.Itoa(len(args)+2)
 
 	offset := page.Offset()
 
 	var items []models.Item
-	err := repo.DB.Select(&items, query, page.PageSize, offset)
+	err := repo.DB.Select(&items, query, append(args, page.PageSize, offset)...)
+
+	return items, err
+}
+
+// Count returns the number of items matching the filter and query.
+func (repo *ItemRepository) Count(filter models.ItemFilter, q query.Query) (int, error) {
+	where, args := itemFilterSQL(filter)
+	conditions, args := q.Where(args)
+	query := "SELECT COUNT(*) FROM items WHERE " + where + " AND " + conditions
+
+	var count int
+	err := repo.DB.Get(&count, query, args...)
+
+	return count, err
+}
+
+// GetAllByCursor returns up to cursor.Limit() items after or before the
+// cursor, in the key order database.NewCursorPage expects.
+func (repo *ItemRepository) GetAllByCursor(cursor database.CursorInfo, filter models.ItemFilter, q query.Query) ([]models.Item, error) {
+	where, args := itemFilterSQL(filter)
+	conditions, args := q.Where(args)
+	keyset, order, args := cursor.Keyset("id", args)
+	query := "SELECT " + itemColumns + " FROM items WHERE " + where + " AND " + conditions + " AND " + keyset +
+		" ORDER BY " + order + " LIMIT $" + strconv.Itoa(len(args)+1)
+
+	var items []models.Item
+	err := repo.DB.Select(&items, query, append(args, cursor.Limit())...)
 
 	return items, err
 }
 
+// Control characters mark the highlighted words in snippets until the name
+// is HTML-escaped, so markup in item names is never passed on.
+const (
+	snippetStart = "\x02"
+	snippetStop  = "\x03"
+)
+
+// Search finds items whose name or text attributes match the tsquery terms,
+// or whose name is similar to query to tolerate typos, best matches first.
+// Snippets are HTML with matches wrapped in <mark>.
+func (repo *ItemRepository) Search(query string, terms string, page database.PageInfo, filter models.ItemFilter) ([]models.ItemSearchResult, error) {
+	where, args := itemFilterSQL(filter)
+	args = append(args, terms, query, page.PageSize, page.Offset())
+	n := len(args)
+
+	sqlQuery := "SELECT " + itemColumns + `,
+			ts_headline('english', name, tsq, 'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', HighlightAll=true') AS snippet,
+			ts_rank(search_vector, tsq) + similarity(name, $` + strconv.Itoa(n-2) + `) AS rank
+		FROM items, to_tsquery('english', $` + strconv.Itoa(n-3) + `) tsq
+		WHERE (search_vector @@ tsq OR name % $` + strconv.Itoa(n-2) + `) AND ` + where + `
+		ORDER BY rank DESC, id
+		LIMIT $` + strconv.Itoa(n-1) + " OFFSET $" + strconv.Itoa(n)
+
+	var results []models.ItemSearchResult
+	err := repo.DB.Select(&results, sqlQuery, args...)
+
+	for i := range results {
+		results[i].Snippet = highlightSnippet(results[i].Snippet)
+	}
+
+	return results, err
+}
+
+// CountSearch returns the number of items Search matches in total.
+func (repo *ItemRepository) CountSearch(query string, terms string, filter models.ItemFilter) (int, error) {
+	where, args := itemFilterSQL(filter)
+	args = append(args, terms, query)
+	n := len(args)
+
+	sqlQuery := `SELECT COUNT(*) FROM items, to_tsquery('english', $` + strconv.Itoa(n-1) + `) tsq
+		WHERE (search_vector @@ tsq OR name % $` + strconv.Itoa(n) + `) AND ` + where
+
+	var count int
+	err := repo.DB.Get(&count, sqlQuery, args...)
+
+	return count, err
+}
+
+func highlightSnippet(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, snippetStart, "<mark>")
+	return strings.ReplaceAll(snippet, snippetStop, "</mark>")
+}
+
+// itemFilterSQL builds the WHERE clause for a filter. Attribute names and
+// values are passed as parameters.
+func itemFilterSQL(filter models.ItemFilter) (string, []interface{}) {
+	conditions := []string{"TRUE"}
+	var args []interface{}
+
+	if filter.CategoryId != nil {
+		args = append(args, *filter.CategoryId)
+		conditions = append(conditions, `category_id IN (
+			WITH RECURSIVE tree AS (
+				SELECT id FROM categories WHERE id = $`+strconv.Itoa(len(args))+`
+				UNION ALL
+				SELECT c.id FROM categories c JOIN tree ON c.parent_id = tree.id
+			) SELECT id FROM tree)`)
+	}
+
+	names := make([]string, 0, len(filter.Attributes))
+	for name := range filter.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		args = append(args, name, filter.Attributes[name])
+		conditions = append(conditions, "attributes ->> $"+strconv.Itoa(len(args)-1)+" = $"+strconv.Itoa(len(args)))
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
 func (repo *ItemRepository) Delete(id int) error {
 	query := "DELETE FROM items WHERE id = $1"
 
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+
+	"market/internal/database/models"
+	"market/internal/database/query"
+)
+
+var placeholder = regexp.MustCompile(`\$(\d+)`)
+
+// The category and attribute filters come first in the argument list, so
+// the query's conditions must be numbered after them.
+func TestItemFilterWithQueryNumbering(t *testing.T) {
+	categoryId := 4
+
+	tests := []struct {
+		name   string
+		filter models.ItemFilter
+		params query.Params
+	}{
+		{"no filters", models.ItemFilter{}, query.Params{}},
+		{"query only", models.ItemFilter{}, query.Params{Filter: "price>=10,name~lamp"}},
+		{"category only", models.ItemFilter{CategoryId: &categoryId}, query.Params{}},
+		{
+			"category, attributes and query",
+			models.ItemFilter{CategoryId: &categoryId, Attributes: map[string]string{"color": "red", "size": "L"}},
+			query.Params{Filter: "price>=10,owner_id!=3,name~lamp"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := query.Parse(ItemQueryFields, tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			where, args := itemFilterSQL(tt.filter)
+			conditions, args := q.Where(args)
+			sql := where + " AND " + conditions
+
+			used := map[int]bool{}
+			for _, match := range placeholder.FindAllStringSubmatch(sql, -1) {
+				n, _ := strconv.Atoi(match[1])
+				if n < 1 || n > len(args) {
+					t.Errorf("placeholder $%d with %d args in %q", n, len(args), sql)
+				}
+				used[n] = true
+			}
+			for n := 1; n <= len(args); n++ {
+				if !used[n] {
+					t.Errorf("argument $%d (%v) is never used in %q", n, args[n-1], sql)
+				}
+			}
+		})
+	}
+}
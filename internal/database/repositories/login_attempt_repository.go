@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"market/internal/database"
+	"market/internal/database/models"
+)
+
+// LoginAttemptRepository is the Postgres implementation of
+// services.LoginAttemptStore, shared by every API instance.
+type LoginAttemptRepository struct {
+	DB database.Executor
+}
+
+func (repo *LoginAttemptRepository) Get(key string) (models.LoginAttempts, error) {
+	query := "SELECT * FROM login_attempts WHERE key = $1"
+
+	var attempts models.LoginAttempts
+	err := repo.DB.Get(&attempts, query, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.LoginAttempts{Key: key}, nil
+	}
+
+	return attempts, err
+}
+
+// RecordFailure counts a failure at the given time. Failures older than
+// window are forgotten and counting starts over.
+func (repo *LoginAttemptRepository) RecordFailure(key string, at time.Time, window time.Duration) (models.LoginAttempts, error) {
+	query := `INSERT INTO login_attempts (key, failures, last_failure_at) VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure_at < $2 - $3 * INTERVAL '1 second'
+				THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = $2
+		RETURNING *`
+
+	var attempts models.LoginAttempts
+	err := repo.DB.Get(&attempts, query, key, at, window.Seconds())
+
+	return attempts, err
+}
+
+// Reserve takes a slot for a login in progress in a single statement,
+// leaving the failures alone. It refuses while the key is locked, while its
+// delay since the last failure, doubling from baseDelay up to maxDelay, has
+// not passed, or while the recent failures and the logins in progress
+// already add up to maxFailures. Concurrent reservations wait on the row
+// lock of the upsert and see each other's slots.
+func (repo *LoginAttemptRepository) Reserve(key string, at time.Time, maxFailures int, window, baseDelay, maxDelay time.Duration) (models.LoginAttempts, bool, error) {
+	query := `INSERT INTO login_attempts (key, failures, last_failure_at, pending, reserved_at)
+		VALUES ($1, 0, to_timestamp(0), 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			pending = CASE WHEN login_attempts.reserved_at IS NULL OR login_attempts.reserved_at < $2 - $7 * INTERVAL '1 second'
+				THEN 1 ELSE login_attempts.pending + 1 END,
+			reserved_at = $2
+		WHERE (login_attempts.locked_until IS NULL OR login_attempts.locked_until <= $2)
+			AND (login_attempts.failures = 0
+				OR login_attempts.last_failure_at < $2 - $3 * INTERVAL '1 second'
+				OR login_attempts.last_failure_at
+					+ LEAST($4 * POWER(2, LEAST(login_attempts.failures - 1, 30)), $5) * INTERVAL '1 second' <= $2)
+			AND CASE WHEN login_attempts.last_failure_at < $2 - $3 * INTERVAL '1 second'
+					THEN 0 ELSE login_attempts.failures END
+				+ CASE WHEN login_attempts.reserved_at IS NULL OR login_attempts.reserved_at < $2 - $7 * INTERVAL '1 second'
+					THEN 0 ELSE login_attempts.pending END < $6
+		RETURNING *`
+
+	var attempts models.LoginAttempts
+	err := repo.DB.Get(&attempts, query, key, at, window.Seconds(), baseDelay.Seconds(), maxDelay.Seconds(),
+		maxFailures, models.LoginReservationTTL.Seconds())
+	if errors.Is(err, sql.ErrNoRows) {
+		attempts, err = repo.Get(key)
+		return attempts, false, err
+	}
+
+	return attempts, err == nil, err
+}
+
+// Release gives back the slot of a finished login.
+func (repo *LoginAttemptRepository) Release(key string) error {
+	query := "UPDATE login_attempts SET pending = GREATEST(pending - 1, 0) WHERE key = $1"
+
+	_, err := repo.DB.Exec(query, key)
+
+	return err
+}
+
+func (repo *LoginAttemptRepository) Lock(key string, until time.Time) error {
+	query := "UPDATE login_attempts SET locked_until = $1 WHERE key = $2"
+
+	_, err := repo.DB.Exec(query, until, key)
+
+	return err
+}
+
+func (repo *LoginAttemptRepository) Reset(key string) error {
+	query := "DELETE FROM login_attempts WHERE key = $1"
+
+	_, err := repo.DB.Exec(query, key)
+
+	return err
+}
@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"market/internal/database"
+	"market/internal/database/models"
+)
+
+type LoginLockoutRepo interface {
+	Create(lockout models.LoginLockout) error
+	GetAll(page database.PageInfo) ([]models.LoginLockout, error)
+	Count() (int, error)
+	MarkUnlocked(key string, unlockedBy *int, reason string) error
+}
+
+type LoginLockoutRepository struct {
+	DB database.Executor
+}
+
+func (repo *LoginLockoutRepository) Create(lockout models.LoginLockout) error {
+	query := `INSERT INTO login_lockouts (key, failures, ip_address, locked_until)
+		VALUES ($1, $2, $3, $4)`
+
+	_, err := repo.DB.Exec(query, lockout.Key, lockout.Failures, lockout.IpAddress, lockout.LockedUntil)
+
+	return err
+}
+
+func (repo *LoginLockoutRepository) GetAll(page database.PageInfo) ([]models.LoginLockout, error) {
+	query := "SELECT * FROM login_lockouts ORDER BY created_at DESC, id DESC LIMIT $1 OFFSET $2"
+
+	var lockouts []models.LoginLockout
+	err := repo.DB.Select(&lockouts, query, page.PageSize, page.Offset())
+
+	return lockouts, err
+}
+
+func (repo *LoginLockoutRepository) Count() (int, error) {
+	query := "SELECT COUNT(*) FROM login_lockouts"
+
+	var count int
+	err := repo.DB.Get(&count, query)
+
+	return count, err
+}
+
+// MarkUnlocked records how the still active lockouts of key were lifted.
+func (repo *LoginLockoutRepository) MarkUnlocked(key string, unlockedBy *int, reason string) error {
+	query := `UPDATE login_lockouts SET unlocked_at = NOW(), unlocked_by = $1, unlock_reason = $2
+		WHERE key = $3 AND unlocked_at IS NULL AND locked_until > NOW()`
+
+	_, err := repo.DB.Exec(query, unlockedBy, reason, key)
+
+	return err
+}
@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"market/internal/database"
+	"market/internal/database/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type MFARepo interface {
+	Get(userId int) (models.UserMFA, error)
+	GetForUpdate(userId int) (models.UserMFA, error)
+	SetSecret(userId int, secret string) error
+	Enable(userId int, step int64) error
+	SetLastUsedStep(userId int, step int64) error
+	Delete(userId int) error
+	ReplaceRecoveryCodes(userId int, codeHashes []string) error
+	UseRecoveryCode(userId int, codeHash string) (bool, error)
+	WithTx(tx *sqlx.Tx) MFARepo
+}
+
+type MFARepository struct {
+	DB database.Executor
+}
+
+func (repo *MFARepository) WithTx(tx *sqlx.Tx) MFARepo {
+	return &MFARepository{DB: tx}
+}
+
+func (repo *MFARepository) Get(userId int) (models.UserMFA, error) {
+	query := "SELECT * FROM user_mfa WHERE user_id = $1"
+
+	var mfa models.UserMFA
+	err := repo.DB.Get(&mfa, query, userId)
+
+	return mfa, err
+}
+
+func (repo *MFARepository) GetForUpdate(userId int) (models.UserMFA, error) {
+	query := "SELECT * FROM user_mfa WHERE user_id = $1 FOR UPDATE"
+
+	var mfa models.UserMFA
+	err := repo.DB.Get(&mfa, query, userId)
+
+	return mfa, err
+}
+
+// SetSecret starts a new, not yet enabled enrollment.
+func (repo *MFARepository) SetSecret(userId int, secret string) error {
+	query := `INSERT INTO user_mfa (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, enabled_at = NULL, last_used_step = 0, created_at = NOW()`
+
+	_, err := repo.DB.Exec(query, userId, secret)
+
+	return err
+}
+
+func (repo *MFARepository) Enable(userId int, step int64) error {
+	query := "UPDATE user_mfa SET enabled_at = NOW(), last_used_step = $1 WHERE user_id = $2"
+
+	_, err := repo.DB.Exec(query, step, userId)
+
+	return err
+}
+
+func (repo *MFARepository) SetLastUsedStep(userId int, step int64) error {
+	query := "UPDATE user_mfa SET last_used_step = $1 WHERE user_id = $2"
+
+	_, err := repo.DB.Exec(query, step, userId)
+
+	return err
+}
+
+func (repo *MFARepository) Delete(userId int) error {
+	if _, err := repo.DB.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = $1", userId); err != nil {
+		return err
+	}
+
+	_, err := repo.DB.Exec("DELETE FROM user_mfa WHERE user_id = $1", userId)
+
+	return err
+}
+
+func (repo *MFARepository) ReplaceRecoveryCodes(userId int, codeHashes []string) error {
+	if _, err := repo.DB.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = $1", userId); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO mfa_recovery_codes (user_id, code_hash)
+		SELECT $1, unnest($2::text[])`
+
+	_, err := repo.DB.Exec(query, userId, pq.Array(codeHashes))
+
+	return err
+}
+
+// UseRecoveryCode marks an unused code as used and reports whether it
+// existed.
+func (repo *MFARepository) UseRecoveryCode(userId int, codeHash string) (bool, error) {
+	query := "UPDATE mfa_recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL"
+
+	result, err := repo.DB.Exec(query, userId, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := result.RowsAffected()
+
+	return count > 0, err
+}
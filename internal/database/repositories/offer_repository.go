@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"errors"
+
+	"market/internal/database"
+	"market/internal/database/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type OfferRepo interface {
+	Create(offer models.NewOffer) (models.Offer, error)
+	Get(id int) (models.Offer, error)
+	GetForUpdate(id int) (models.Offer, error)
+	GetByItem(itemId int, buyerId int, page database.PageInfo) ([]models.Offer, error)
+	GetByUser(userId int, page database.PageInfo) ([]models.Offer, error)
+	CountByItem(itemId int, buyerId int) (int, error)
+	CountByUser(userId int) (int, error)
+	UpdateStatus(id int, from string, to string) error
+	SetDeal(id int, dealId int) error
+	ExpireStale() error
+	WithTx(tx *sqlx.Tx) OfferRepo
+}
+
+var ErrStaleOfferStatus = errors.New("offer status changed concurrently")
+
+const offerColumns = `id, item_id, buyer_id, seller_id, proposer_id, parent_id, deal_id,
+	price AS "price.amount", currency AS "price.currency", status, expires_at, created_at`
+
+type OfferRepository struct {
+	DB database.Executor
+}
+
+func (repo *OfferRepository) WithTx(tx *sqlx.Tx) OfferRepo {
+	return &OfferRepository{DB: tx}
+}
+
+func (repo *OfferRepository) Create(newOffer models.NewOffer) (models.Offer, error) {
+	query := `INSERT INTO offers (item_id, buyer_id, seller_id, proposer_id, parent_id, price, currency, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING ` + offerColumns
+
+	var offer models.Offer
+	err := repo.DB.Get(&offer, query, newOffer.ItemId, newOffer.BuyerId, newOffer.SellerId, newOffer.ProposerId,
+		newOffer.ParentId, newOffer.Price.Amount, newOffer.Price.Currency, newOffer.ExpiresAt)
+
+	return offer, err
+}
+
+func (repo *OfferRepository) Get(id int) (models.Offer, error) {
+	query := "SELECT " + offerColumns + " FROM offers WHERE id = $1"
+
+	var offer models.Offer
+	err := repo.DB.Get(&offer, query, id)
+
+	return offer, err
+}
+
+// GetForUpdate locks the offer row until the surrounding transaction ends.
+func (repo *OfferRepository) GetForUpdate(id int) (models.Offer, error) {
+	query := "SELECT " + offerColumns + " FROM offers WHERE id = $1 FOR UPDATE"
+
+	var offer models.Offer
+	err := repo.DB.Get(&offer, query, id)
+
+	return offer, err
+}
+
+// GetByItem lists offers on an item, limited to one buyer's offers unless
+// buyerId is 0.
+func (repo *OfferRepository) GetByItem(itemId int, buyerId int, page database.PageInfo) ([]models.Offer, error) {
+	query := "SELECT " + offerColumns + ` FROM offers
+		WHERE item_id = $1 AND ($2 = 0 OR buyer_id = $2)
+		ORDER BY id DESC LIMIT $3 OFFSET $4`
+
+	offset := page.Offset()
+
+	var offers []models.Offer
+	err := repo.DB.Select(&offers, query, itemId, buyerId, page.PageSize, offset)
+
+	return offers, err
+}
+
+func (repo *OfferRepository) GetByUser(userId int, page database.PageInfo) ([]models.Offer, error) {
+	query := "SELECT " + offerColumns + ` FROM offers
+		WHERE buyer_id = $1 OR seller_id = $1
+		ORDER BY id DESC LIMIT $2 OFFSET $3`
+
+	offset := page.Offset()
+
+	var offers []models.Offer
+	err := repo.DB.Select(&offers, query, userId, page.PageSize, offset)
+
+	return offers, err
+}
+
+func (repo *OfferRepository) CountByItem(itemId int, buyerId int) (int, error) {
+	query := "SELECT COUNT(*) FROM offers WHERE item_id = $1 AND ($2 = 0 OR buyer_id = $2)"
+
+	var count int
+	err := repo.DB.Get(&count, query, itemId, buyerId)
+
+	return count, err
+}
+
+func (repo *OfferRepository) CountByUser(userId int) (int, error) {
+	query := "SELECT COUNT(*) FROM offers WHERE buyer_id = $1 OR seller_id = $1"
+
+	var count int
+	err := repo.DB.Get(&count, query, userId)
+
+	return count, err
+}
+
+// UpdateStatus moves the offer from one status to another and fails with
+// ErrStaleOfferStatus if the offer is no longer in the expected status.
+func (repo *OfferRepository) UpdateStatus(id int, from string, to string) error {
+	query := "UPDATE offers SET status = $1 WHERE id = $2 AND status = $3"
+
+	result, err := repo.DB.Exec(query, to, id, from)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrStaleOfferStatus
+	}
+
+	return nil
+}
+
+func (repo *OfferRepository) SetDeal(id int, dealId int) error {
+	query := "UPDATE offers SET deal_id = $1 WHERE id = $2"
+
+	_, err := repo.DB.Exec(query, dealId, id)
+
+	return err
+}
+
+// ExpireStale marks pending offers past their expiry time as expired.
+func (repo *OfferRepository) ExpireStale() error {
+	query := "UPDATE offers SET status = 'expired' WHERE status = 'pending' AND expires_at <= NOW()"
+
+	_, err := repo.DB.Exec(query)
+
+	return err
+}
@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"errors"
+
+	"market/internal/database"
+	"market/internal/database/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type OrderRepo interface {
+	Create(userId int, order models.NewOrder) (models.Order, error)
+	Get(id int) (models.Order, error)
+	GetByUser(userId int, page database.PageInfo) ([]models.Order, error)
+	CountByUser(userId int) (int, error)
+	GetOpen() ([]models.Order, error)
+	OpenAskQuantity(userId int, itemName string) (int, error)
+	AddFill(id int, quantity int) error
+	UpdateStatus(id int, from string, to string) error
+	WithTx(tx *sqlx.Tx) OrderRepo
+}
+
+var ErrStaleOrder = errors.New("order changed concurrently")
+
+const orderColumns = `id, user_id, item_name, side, price AS "price.amount", currency AS "price.currency",
+	quantity, filled, status, created_at`
+
+type OrderRepository struct {
+	DB database.Executor
+}
+
+func (repo *OrderRepository) WithTx(tx *sqlx.Tx) OrderRepo {
+	return &OrderRepository{DB: tx}
+}
+
+func (repo *OrderRepository) Create(userId int, newOrder models.NewOrder) (models.Order, error) {
+	query := `INSERT INTO orders (user_id, item_name, side, price, currency, quantity)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING ` + orderColumns
+
+	var order models.Order
+	err := repo.DB.Get(&order, query, userId, newOrder.ItemName, newOrder.Side, newOrder.Price.Amount, newOrder.Price.Currency, newOrder.Quantity)
+
+	return order, err
+}
+
+func (repo *OrderRepository) Get(id int) (models.Order, error) {
+	query := "SELECT " + orderColumns + " FROM orders WHERE id = $1"
+
+	var order models.Order
+	err := repo.DB.Get(&order, query, id)
+
+	return order, err
+}
+
+func (repo *OrderRepository) GetByUser(userId int, page database.PageInfo) ([]models.Order, error) {
+	query := "SELECT " + orderColumns + " FROM orders WHERE user_id = $1 ORDER BY id DESC LIMIT $2 OFFSET $3"
+
+	offset := page.Offset()
+
+	var orders []models.Order
+	err := repo.DB.Select(&orders, query, userId, page.PageSize, offset)
+
+	return orders, err
+}
+
+func (repo *OrderRepository) CountByUser(userId int) (int, error) {
+	query := "SELECT COUNT(*) FROM orders WHERE user_id = $1"
+
+	var count int
+	err := repo.DB.Get(&count, query, userId)
+
+	return count, err
+}
+
+// GetOpen returns every open order in submission order, used to rebuild the
+// in-memory books on startup.
+func (repo *OrderRepository) GetOpen() ([]models.Order, error) {
+	query := "SELECT " + orderColumns + " FROM orders WHERE status = 'open' ORDER BY id"
+
+	var orders []models.Order
+	err := repo.DB.Select(&orders, query)
+
+	return orders, err
+}
+
+// OpenAskQuantity returns how many units of itemName the user already has on
+// offer in open asks.
+func (repo *OrderRepository) OpenAskQuantity(userId int, itemName string) (int, error) {
+	query := "SELECT COALESCE(SUM(quantity - filled), 0) FROM orders WHERE user_id = $1 AND item_name = $2 AND side = 'ask' AND status = 'open'"
+
+	var quantity int
+	err := repo.DB.Get(&quantity, query, userId, itemName)
+
+	return quantity, err
+}
+
+// AddFill records quantity more units filled on an open order, closing it
+// once fully filled.
+func (repo *OrderRepository) AddFill(id int, quantity int) error {
+	query := `UPDATE orders SET filled = filled + $1,
+		status = CASE WHEN filled + $1 = quantity THEN 'filled' ELSE status END
+		WHERE id = $2 AND status = 'open' AND filled + $1 <= quantity`
+
+	result, err := repo.DB.Exec(query, quantity, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrStaleOrder
+	}
+
+	return nil
+}
+
+func (repo *OrderRepository) UpdateStatus(id int, from string, to string) error {
+	query := "UPDATE orders SET status = $1 WHERE id = $2 AND status = $3"
+
+	result, err := repo.DB.Exec(query, to, id, from)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrStaleOrder
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"market/internal/database/models"
+	"market/internal/database/query"
+	"strings"
+)
+
+// UserQueryFields are the fields GET /auth/users can filter and sort by.
+var UserQueryFields = query.Schema{
+	"id":       {Column: "id", Type: query.Int, Filter: true, Sortable: true},
+	"username": {Column: "username", Type: query.String, Filter: true, Sortable: true},
+}
+
+// ItemQueryFields are the fields GET /auth/items can filter and sort by.
+// Prices are compared in major units, so price>=10 means 10 USD or 10 EUR.
+var ItemQueryFields = query.Schema{
+	"id":          {Column: "id", Type: query.Int, Filter: true, Sortable: true},
+	"name":        {Column: "name", Type: query.String, Filter: true, Sortable: true},
+	"price":       {Column: majorUnits("price", "currency"), Type: query.Number, Filter: true, Sortable: true},
+	"currency":    {Column: "currency", Type: query.String, Filter: true, Sortable: true},
+	"owner_id":    {Column: "owner_id", Type: query.Int, Filter: true, Sortable: true},
+	"category_id": {Column: "category_id", Type: query.Int, Filter: true, Sortable: true},
+}
+
+// DealQueryFields are the fields GET /auth/deals can filter and sort by.
+var DealQueryFields = query.Schema{
+	"id":        {Column: "d.id", Type: query.Int, Filter: true, Sortable: true},
+	"status":    {Column: "d.status", Type: query.String, Filter: true, Sortable: true},
+	"price":     {Column: majorUnits("d.price", "d.currency"), Type: query.Number, Filter: true, Sortable: true},
+	"currency":  {Column: "d.currency", Type: query.String, Filter: true, Sortable: true},
+	"item_id":   {Column: "d.item_id", Type: query.Int, Filter: true, Sortable: true},
+	"user_id":   {Column: "d.user_id", Type: query.Int, Filter: true, Sortable: true},
+	"seller_id": {Column: "d.seller_id", Type: query.Int, Filter: true, Sortable: true},
+}
+
+// majorUnits converts an amount column in minor units to major units of
+// its row's currency.
+func majorUnits(amount string, currency string) string {
+	var cases strings.Builder
+	for _, code := range models.Currencies() {
+		exponent, _ := models.CurrencyExponent(code)
+		divisor := "1" + strings.Repeat("0", exponent)
+		cases.WriteString(" WHEN '" + code + "' THEN " + divisor)
+	}
+
+	return "(" + amount + "::numeric / CASE " + currency + cases.String() + " END)"
+}
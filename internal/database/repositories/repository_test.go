@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// testTx returns a transaction on the database at TEST_DB_URL, which must be
+// migrated up, and rolls it back when the test ends. Tests are skipped when
+// TEST_DB_URL is not set.
+func testTx(t *testing.T) *sqlx.Tx {
+	t.Helper()
+
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL not set")
+	}
+
+	db, err := sqlx.Connect("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	tx, err := db.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+
+	return tx
+}
@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"time"
+
+	"market/internal/database"
+)
+
+// RevocationRepository is the Postgres implementation of
+// middlewares.RevocationStore, shared by every API instance.
+type RevocationRepository struct {
+	DB database.Executor
+}
+
+func (repo *RevocationRepository) Revoke(jti string, expiresAt time.Time) error {
+	query := `INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING`
+
+	_, err := repo.DB.Exec(query, jti, expiresAt)
+
+	return err
+}
+
+func (repo *RevocationRepository) RevokeUser(userId int, before time.Time) error {
+	query := `INSERT INTO user_token_cutoffs (user_id, revoked_before) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET revoked_before = EXCLUDED.revoked_before`
+
+	_, err := repo.DB.Exec(query, userId, before)
+
+	return err
+}
+
+// IsRevoked compares whole seconds like the iat claim, so a token issued in
+// the same second as a user-wide revocation stays valid.
+func (repo *RevocationRepository) IsRevoked(jti string, userId int, issuedAt time.Time) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+		OR EXISTS (SELECT 1 FROM user_token_cutoffs WHERE user_id = $2 AND $3 < date_trunc('second', revoked_before))`
+
+	var revoked bool
+	err := repo.DB.QueryRow(query, jti, userId, issuedAt).Scan(&revoked)
+
+	return revoked, err
+}
+
+// DeleteExpired drops revoked tokens that have expired on their own.
+func (repo *RevocationRepository) DeleteExpired() (int, error) {
+	query := "DELETE FROM revoked_tokens WHERE expires_at < NOW()"
+
+	result, err := repo.DB.Exec(query)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := result.RowsAffected()
+
+	return int(count), err
+}
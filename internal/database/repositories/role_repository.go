@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"market/internal/database"
+	"market/internal/database/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type RoleRepo interface {
+	GetAll() ([]models.Role, error)
+	GetRolePermissions() ([]models.RolePermission, error)
+	GetByNames(names []string) ([]models.Role, error)
+	GetUserRoles(userId int) ([]string, error)
+	GetUserPermissions(userId int) ([]string, error)
+	SetUserRoles(userId int, roleIds []int) error
+	WithTx(tx *sqlx.Tx) RoleRepo
+}
+
+type RoleRepository struct {
+	DB database.Executor
+}
+
+func (repo *RoleRepository) WithTx(tx *sqlx.Tx) RoleRepo {
+	return &RoleRepository{DB: tx}
+}
+
+func (repo *RoleRepository) GetAll() ([]models.Role, error) {
+	query := "SELECT id, name FROM roles ORDER BY id"
+
+	var roles []models.Role
+	err := repo.DB.Select(&roles, query)
+
+	return roles, err
+}
+
+func (repo *RoleRepository) GetRolePermissions() ([]models.RolePermission, error) {
+	query := `SELECT r.name AS role, p.name AS permission
+		FROM role_permissions rp
+		JOIN roles r ON r.id = rp.role_id
+		JOIN permissions p ON p.id = rp.permission_id
+		ORDER BY p.name`
+
+	var rolePermissions []models.RolePermission
+	err := repo.DB.Select(&rolePermissions, query)
+
+	return rolePermissions, err
+}
+
+func (repo *RoleRepository) GetByNames(names []string) ([]models.Role, error) {
+	query := "SELECT id, name FROM roles WHERE name = ANY($1) ORDER BY id"
+
+	var roles []models.Role
+	err := repo.DB.Select(&roles, query, pq.Array(names))
+
+	return roles, err
+}
+
+func (repo *RoleRepository) GetUserRoles(userId int) ([]string, error) {
+	query := `SELECT r.name FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY r.name`
+
+	var roles []string
+	err := repo.DB.Select(&roles, query, userId)
+
+	return roles, err
+}
+
+func (repo *RoleRepository) GetUserPermissions(userId int) ([]string, error) {
+	query := `SELECT DISTINCT p.name FROM user_roles ur
+		JOIN role_permissions rp ON rp.role_id = ur.role_id
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE ur.user_id = $1
+		ORDER BY p.name`
+
+	var permissions []string
+	err := repo.DB.Select(&permissions, query, userId)
+
+	return permissions, err
+}
+
+// SetUserRoles replaces the user's roles. Run it in a transaction so the
+// delete and insert are applied together.
+func (repo *RoleRepository) SetUserRoles(userId int, roleIds []int) error {
+	_, err := repo.DB.Exec("DELETE FROM user_roles WHERE user_id = $1", userId)
+	if err != nil {
+		return err
+	}
+
+	if len(roleIds) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO user_roles (user_id, role_id)
+		SELECT $1, unnest($2::int[])`
+
+	_, err = repo.DB.Exec(query, userId, pq.Array(roleIds))
+
+	return err
+}
@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"market/internal/database"
+	"market/internal/database/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SessionRepo interface {
+	Create(newSession models.NewSession) (models.Session, error)
+	GetByTokenHashForUpdate(tokenHash string) (models.Session, error)
+	GetLiveAccessTokens(familyId string) ([]models.Session, error)
+	GetActiveByUser(userId int) ([]models.Session, error)
+	MarkRotated(id int) error
+	RevokeFamily(familyId string) error
+	RevokeAllForUser(userId int) error
+	WithTx(tx *sqlx.Tx) SessionRepo
+}
+
+type SessionRepository struct {
+	DB database.Executor
+}
+
+func (repo *SessionRepository) WithTx(tx *sqlx.Tx) SessionRepo {
+	return &SessionRepository{DB: tx}
+}
+
+func (repo *SessionRepository) Create(newSession models.NewSession) (models.Session, error) {
+	query := `INSERT INTO sessions (user_id, family_id, token_hash, user_agent, ip_address, expires_at, access_jti, access_expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING *`
+
+	var session models.Session
+	err := repo.DB.Get(&session, query, newSession.UserId, newSession.FamilyId, newSession.TokenHash,
+		newSession.Meta.UserAgent, newSession.Meta.IpAddress, newSession.ExpiresAt,
+		newSession.AccessTokenId, newSession.AccessExpiresAt)
+
+	return session, err
+}
+
+// GetByTokenHashForUpdate locks the session row until the surrounding
+// transaction ends, so a refresh token can only be rotated once.
+func (repo *SessionRepository) GetByTokenHashForUpdate(tokenHash string) (models.Session, error) {
+	query := "SELECT * FROM sessions WHERE token_hash = $1 FOR UPDATE"
+
+	var session models.Session
+	err := repo.DB.Get(&session, query, tokenHash)
+
+	return session, err
+}
+
+// GetLiveAccessTokens returns the sessions of the family whose access token
+// has not expired yet.
+func (repo *SessionRepository) GetLiveAccessTokens(familyId string) ([]models.Session, error) {
+	query := "SELECT * FROM sessions WHERE family_id = $1 AND access_jti <> '' AND access_expires_at > NOW()"
+
+	var sessions []models.Session
+	err := repo.DB.Select(&sessions, query, familyId)
+
+	return sessions, err
+}
+
+// GetActiveByUser returns the usable token of each of the user's signed-in
+// sessions.
+func (repo *SessionRepository) GetActiveByUser(userId int) ([]models.Session, error) {
+	query := `SELECT * FROM sessions
+		WHERE user_id = $1 AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC`
+
+	var sessions []models.Session
+	err := repo.DB.Select(&sessions, query, userId)
+
+	return sessions, err
+}
+
+func (repo *SessionRepository) MarkRotated(id int) error {
+	query := "UPDATE sessions SET rotated_at = NOW() WHERE id = $1"
+
+	_, err := repo.DB.Exec(query, id)
+
+	return err
+}
+
+func (repo *SessionRepository) RevokeFamily(familyId string) error {
+	query := "UPDATE sessions SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL"
+
+	_, err := repo.DB.Exec(query, familyId)
+
+	return err
+}
+
+func (repo *SessionRepository) RevokeAllForUser(userId int) error {
+	query := "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL"
+
+	_, err := repo.DB.Exec(query, userId)
+
+	return err
+}
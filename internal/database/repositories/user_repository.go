@@ -3,6 +3,8 @@ package repositories
 import (
 	"market/internal/database"
 	"market/internal/database/models"
+	"market/internal/database/query"
+	"strconv"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -10,23 +12,34 @@ import (
 type UserRepo interface {
 	Create(user models.NewUser) (models.User, error)
 	Get(id int) (models.User, error)
-	GetAll(page database.PageInfo) ([]models.User, error)
+	GetAll(page database.PageInfo, q query.Query) ([]models.User, error)
+	GetAllByCursor(cursor database.CursorInfo, q query.Query) ([]models.User, error)
+	Count(q query.Query) (int, error)
 	GetByUsername(username string) (models.User, error)
+	GetByEmail(email string) (models.User, error)
 	Update(user models.User) error
 	Delete(id int) error
+	SetBanned(id int, banned bool) error
+	UpdatePassword(id int, password string) error
+	MarkEmailVerified(id int) error
+	WithTx(tx *sqlx.Tx) UserRepo
 }
 
 type UserRepository struct {
-	DB *sqlx.DB
+	DB database.Executor
+}
+
+func (repo *UserRepository) WithTx(tx *sqlx.Tx) UserRepo {
+	return &UserRepository{DB: tx}
 }
 
 func (repo *UserRepository) Create(newUser models.NewUser) (models.User, error) {
-	query := "INSERT INTO users (username, email, password, salt) VALUES ($1, $2, $3, $4) returning id"
+	query := "INSERT INTO users (username, email, password) VALUES ($1, $2, $3) returning id"
 
 	var userId int
-	err := repo.DB.QueryRow(query, newUser.Username, newUser.Email, newUser.Password, newUser.Salt).Scan(&userId)
+	err := repo.DB.QueryRow(query, newUser.Username, newUser.Email, newUser.Password).Scan(&userId)
 
-	return models.User{Id: userId, Username: newUser.Username, Email: newUser.Email, Password: newUser.Password, Salt: newUser.Salt}, err
+	return models.User{Id: userId, Username: newUser.Username, Email: newUser.Email, Password: newUser.Password}, err
 }
 
 func (repo *UserRepository) Get(id int) (models.User, error) {
@@ -38,21 +51,50 @@ func (repo *UserRepository) Get(id int) (models.User, error) {
 	return user, err
 }
 
-func (repo *UserRepository) GetAll(page database.PageInfo) ([]models.User, error) {
-	query := "SELECT * FROM users LIMIT $1 OFFSET $2"
+func (repo *UserRepository) GetAll(page database.PageInfo, q query.Query) ([]models.User, error) {
+	where, args := q.Where(nil)
+	query := "SELECT * FROM users WHERE " + where + " ORDER BY " + q.OrderBy("id") +
+		" LIMIT $" + strconv.Itoa(len(args)+1) + " OFFSET $" + strconv.Itoa(len(args)+2)
 
 	offset := page.Offset()
 
 	var users []models.User
-	err := repo.DB.Select(&users, query, page.PageSize, offset)
+	err := repo.DB.Select(&users, query, append(args, page.PageSize, offset)...)
+
+	return users, err
+}
+
+// Count returns the number of users matching the query's filter.
+func (repo *UserRepository) Count(q query.Query) (int, error) {
+	where, args := q.Where(nil)
+	query := "SELECT COUNT(*) FROM users WHERE " + where
+
+	var count int
+	err := repo.DB.Get(&count, query, args...)
+
+	return count, err
+}
+
+// GetAllByCursor returns up to cursor.Limit() users after or before the
+// cursor, in the key order database.NewCursorPage expects.
+func (repo *UserRepository) GetAllByCursor(cursor database.CursorInfo, q query.Query) ([]models.User, error) {
+	where, args := q.Where(nil)
+	keyset, order, args := cursor.Keyset("id", args)
+	query := "SELECT * FROM users WHERE " + where + " AND " + keyset + " ORDER BY " + order +
+		" LIMIT $" + strconv.Itoa(len(args)+1)
+
+	var users []models.User
+	err := repo.DB.Select(&users, query, append(args, cursor.Limit())...)
 
 	return users, err
 }
 
 func (repo *UserRepository) Update(user models.User) error {
-	query := "UPDATE users SET username = $1, email = $2, password = $3, salt = $4 WHERE id = $5"
+	query := `UPDATE users SET username = $1, email = $2, password = $3,
+		email_verified_at = CASE WHEN email = $2 THEN email_verified_at END
+		WHERE id = $4`
 
-	_, err := repo.DB.Exec(query, user.Username, user.Email, user.Password, user.Salt, user.Id)
+	_, err := repo.DB.Exec(query, user.Username, user.Email, user.Password, user.Id)
 
 	return err
 }
@@ -65,6 +107,14 @@ func (repo *UserRepository) Delete(id int) error {
 	return err
 }
 
+func (repo *UserRepository) SetBanned(id int, banned bool) error {
+	query := "UPDATE users SET banned_at = CASE WHEN $1 THEN COALESCE(banned_at, NOW()) END WHERE id = $2"
+
+	_, err := repo.DB.Exec(query, banned, id)
+
+	return err
+}
+
 func (repo *UserRepository) GetByUsername(username string) (models.User, error) {
 	query := "SELECT * FROM users WHERE username = $1"
 
@@ -73,3 +123,28 @@ func (repo *UserRepository) GetByUsername(username string) (models.User, error)
 
 	return user, err
 }
+
+func (repo *UserRepository) GetByEmail(email string) (models.User, error) {
+	query := "SELECT * FROM users WHERE LOWER(email) = LOWER($1)"
+
+	var user models.User
+	err := repo.DB.Get(&user, query, email)
+
+	return user, err
+}
+
+func (repo *UserRepository) UpdatePassword(id int, password string) error {
+	query := "UPDATE users SET password = $1 WHERE id = $2"
+
+	_, err := repo.DB.Exec(query, password, id)
+
+	return err
+}
+
+func (repo *UserRepository) MarkEmailVerified(id int) error {
+	query := "UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()) WHERE id = $1"
+
+	_, err := repo.DB.Exec(query, id)
+
+	return err
+}
@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	"errors"
+	"sort"
+
+	"market/internal/database"
+	"market/internal/database/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type WalletRepo interface {
+	GetByUser(userId int, currency string) (models.Wallet, error)
+	GetSystem(kind string, currency string) (models.Wallet, error)
+	GetEntries(walletId int, page database.PageInfo) ([]models.LedgerEntry, error)
+	CountEntries(walletId int) (int, error)
+	Post(entries []models.NewLedgerEntry) (int64, error)
+	WithTx(tx *sqlx.Tx) WalletRepo
+}
+
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrUnbalancedEntries = errors.New("ledger entries do not balance")
+)
+
+const walletColumns = `id, user_id, kind, balance AS "balance.amount", currency AS "balance.currency", created_at`
+
+const ledgerEntryColumns = `id, transaction_id, wallet_id, amount AS "amount.amount", currency AS "amount.currency",
+	kind, deal_id, description, created_at`
+
+type WalletRepository struct {
+	DB database.Executor
+}
+
+func (repo *WalletRepository) WithTx(tx *sqlx.Tx) WalletRepo {
+	return &WalletRepository{DB: tx}
+}
+
+// GetByUser returns the user's wallet in the given currency, opening an
+// empty one on first use.
+func (repo *WalletRepository) GetByUser(userId int, currency string) (models.Wallet, error) {
+	query := `INSERT INTO wallets (user_id, currency) VALUES ($1, $2)
+		ON CONFLICT (user_id, currency) DO UPDATE SET user_id = EXCLUDED.user_id
+		RETURNING ` + walletColumns
+
+	var wallet models.Wallet
+	err := repo.DB.Get(&wallet, query, userId, currency)
+
+	return wallet, err
+}
+
+// GetSystem returns the platform wallet of the given kind and currency,
+// opening it on first use. The conflict target must repeat the predicate of
+// wallets_system_kind_currency_idx for Postgres to pick the index.
+func (repo *WalletRepository) GetSystem(kind string, currency string) (models.Wallet, error) {
+	query := `INSERT INTO wallets (kind, currency) VALUES ($1, $2)
+		ON CONFLICT (kind, currency) WHERE user_id IS NULL AND kind <> 'user' DO UPDATE SET kind = EXCLUDED.kind
+		RETURNING ` + walletColumns
+
+	var wallet models.Wallet
+	err := repo.DB.Get(&wallet, query, kind, currency)
+
+	return wallet, err
+}
+
+func (repo *WalletRepository) GetEntries(walletId int, page database.PageInfo) ([]models.LedgerEntry, error) {
+	query := "SELECT " + ledgerEntryColumns + " FROM ledger_entries WHERE wallet_id = $1 ORDER BY id DESC LIMIT $2 OFFSET $3"
+
+	offset := page.Offset()
+
+	var entries []models.LedgerEntry
+	err := repo.DB.Select(&entries, query, walletId, page.PageSize, offset)
+
+	return entries, err
+}
+
+func (repo *WalletRepository) CountEntries(walletId int) (int, error) {
+	query := "SELECT COUNT(*) FROM ledger_entries WHERE wallet_id = $1"
+
+	var count int
+	err := repo.DB.Get(&count, query, walletId)
+
+	return count, err
+}
+
+// Post records a balanced set of single-currency entries under one ledger
+// transaction and applies them to wallet balances. It must run inside a
+// transaction: the database verifies the balance of each ledger transaction
+// at commit.
+func (repo *WalletRepository) Post(entries []models.NewLedgerEntry) (int64, error) {
+	if len(entries) < 2 {
+		return 0, ErrUnbalancedEntries
+	}
+
+	sum := models.NewMoney(0, entries[0].Amount.Currency)
+	for _, entry := range entries {
+		var err error
+		if sum, err = sum.Add(entry.Amount); err != nil {
+			return 0, err
+		}
+	}
+	if sum.Amount != 0 {
+		return 0, ErrUnbalancedEntries
+	}
+
+	sorted := make([]models.NewLedgerEntry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].WalletId < sorted[j].WalletId })
+
+	// Wallets are always updated in id order so concurrent postings between
+	// the same wallets cannot deadlock.
+	updateQuery := `UPDATE wallets SET balance = balance + $1
+		WHERE id = $2 AND currency = $3 AND (kind <> 'user' OR balance + $1 >= 0)`
+	for _, entry := range sorted {
+		result, err := repo.DB.Exec(updateQuery, entry.Amount.Amount, entry.WalletId, entry.Amount.Currency)
+		if err != nil {
+			return 0, err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+
+		if affected == 0 {
+			return 0, ErrInsufficientFunds
+		}
+	}
+
+	var transactionId int64
+	if err := repo.DB.QueryRow("SELECT nextval('ledger_transaction_seq')").Scan(&transactionId); err != nil {
+		return 0, err
+	}
+
+	insertQuery := `INSERT INTO ledger_entries (transaction_id, wallet_id, amount, currency, kind, deal_id, description)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	for _, entry := range entries {
+		_, err := repo.DB.Exec(insertQuery, transactionId, entry.WalletId, entry.Amount.Amount, entry.Amount.Currency,
+			entry.Kind, entry.DealId, entry.Description)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return transactionId, nil
+}
@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"testing"
+
+	"market/internal/database/models"
+)
+
+func TestWalletGetSystem(t *testing.T) {
+	tx := testTx(t)
+	repo := &WalletRepository{DB: tx}
+
+	first, err := repo.GetSystem(models.WalletKindEscrow, "XTS")
+	if err != nil {
+		t.Fatalf("opening system wallet: %v", err)
+	}
+
+	again, err := repo.GetSystem(models.WalletKindEscrow, "XTS")
+	if err != nil {
+		t.Fatalf("reading system wallet: %v", err)
+	}
+	if again.Id != first.Id {
+		t.Errorf("got wallet %d, want the existing wallet %d", again.Id, first.Id)
+	}
+	if again.Kind != models.WalletKindEscrow {
+		t.Errorf("kind = %q, want %q", again.Kind, models.WalletKindEscrow)
+	}
+}
+
+// A deleted user's wallet keeps its kind with a NULL owner; it must neither
+// clash with nor stand in for the system wallets.
+func TestWalletGetSystemWithOrphanedWallets(t *testing.T) {
+	tx := testTx(t)
+	repo := &WalletRepository{DB: tx}
+	users := &UserRepository{DB: tx}
+
+	for _, name := range []string{"orphan-a", "orphan-b"} {
+		user, err := users.Create(models.NewUser{Username: name, Email: name + "@example.com", Password: "x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.GetByUser(user.Id, "XTS"); err != nil {
+			t.Fatal(err)
+		}
+		if err := users.Delete(user.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	escrow, err := repo.GetSystem(models.WalletKindEscrow, "XTS")
+	if err != nil {
+		t.Fatalf("opening system wallet: %v", err)
+	}
+	if escrow.Kind != models.WalletKindEscrow {
+		t.Errorf("kind = %q, want %q", escrow.Kind, models.WalletKindEscrow)
+	}
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Executor is the subset of query methods shared by *sqlx.DB and *sqlx.Tx,
+// so repositories can run the same queries inside or outside a transaction.
+type Executor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+	Get(dest any, query string, args ...any) error
+	Select(dest any, query string, args ...any) error
+}
+
+type Transactor interface {
+	WithTx(fn func(tx *sqlx.Tx) error) error
+}
+
+type TxManager struct {
+	DB *sqlx.DB
+}
+
+// WithTx runs fn inside a transaction, committing if it returns nil and
+// rolling back on error or panic.
+func (manager *TxManager) WithTx(fn func(tx *sqlx.Tx) error) error {
+	tx, err := manager.DB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,12 @@
+package mailer
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers plain text emails such as password reset links.
+type Mailer interface {
+	Send(message Message) error
+}
@@ -0,0 +1,40 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(message Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	body := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + message.To,
+		"Subject: " + message.Subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		message.Body,
+	}, "\r\n")
+
+	err := smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, m.From, []string{message.To}, []byte(body))
+	if err != nil {
+		return fmt.Errorf("sending mail to %s: %w", message.To, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrProviderError = errors.New("identity provider returned an error")
+
+// Config describes one OpenID Connect provider. Everything else is read from
+// its discovery document.
+type Config struct {
+	// Name identifies the provider in routes and linked identities, e.g.
+	// "google".
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes requested besides "openid", "email" and "profile" when empty.
+	Scopes []string
+}
+
+// Metadata is the part of the discovery document the client uses.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Token is a successful token endpoint response.
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Client runs the authorization code flow with PKCE against one provider.
+// Discovery and the provider's keys are fetched lazily and cached.
+type Client struct {
+	Config Config
+	HTTP   *http.Client
+
+	mu       sync.Mutex
+	metadata *Metadata
+	keys     *keyCache
+}
+
+func NewClient(config Config) *Client {
+	return &Client{Config: config, HTTP: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Discover fetches the provider's discovery document once. The document must
+// name the configured issuer.
+func (client *Client) Discover(ctx context.Context) (*Metadata, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.metadata != nil {
+		return client.metadata, nil
+	}
+
+	var metadata Metadata
+	discoveryURL := strings.TrimSuffix(client.Config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := client.getJSON(ctx, discoveryURL, &metadata); err != nil {
+		return nil, fmt.Errorf("discovering %s: %w", client.Config.Name, err)
+	}
+
+	if metadata.Issuer != client.Config.Issuer {
+		return nil, fmt.Errorf("discovering %s: issuer %q does not match %q", client.Config.Name, metadata.Issuer, client.Config.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("discovering %s: incomplete discovery document", client.Config.Name)
+	}
+
+	client.metadata = &metadata
+	client.keys = &keyCache{url: metadata.JWKSURI}
+
+	return client.metadata, nil
+}
+
+// AuthCodeURL returns where to send the user to sign in. state and nonce
+// bind the callback and ID token to this login; challenge is the PKCE S256
+// challenge of the verifier later passed to Exchange.
+func (client *Client) AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	metadata, err := client.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := client.Config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"email", "profile"}
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {client.Config.ClientID},
+		"redirect_uri":          {client.Config.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code at the token endpoint.
+func (client *Client) Exchange(ctx context.Context, code, verifier string) (*Token, error) {
+	metadata, err := client.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {client.Config.RedirectURL},
+		"client_id":     {client.Config.ClientID},
+		"code_verifier": {verifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if client.Config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(client.Config.ClientID), url.QueryEscape(client.Config.ClientSecret))
+	}
+
+	resp, err := client.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("exchanging code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("exchanging code: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var providerErr struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		_ = json.Unmarshal(body, &providerErr)
+		return nil, fmt.Errorf("%w: %s", ErrProviderError, strings.TrimSpace(providerErr.Error+" "+providerErr.Description))
+	}
+
+	var token Token
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("exchanging code: %w", err)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("%w: no id_token in response", ErrProviderError)
+	}
+
+	return &token, nil
+}
+
+func (client *Client) getJSON(ctx context.Context, url string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(target)
+}
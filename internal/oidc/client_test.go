@@ -0,0 +1,153 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"market/internal/oidc"
+	"market/internal/oidc/oidctest"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// login runs the authorization code flow against the stub provider and
+// returns the verified ID token claims.
+func login(t *testing.T, provider *oidctest.Provider, claims jwt.MapClaims) (*oidc.IDTokenClaims, error) {
+	t.Helper()
+
+	client := oidc.NewClient(provider.Config("stub"))
+	ctx := context.Background()
+
+	verifier, _ := oidc.RandomString()
+	authURL, err := client.AuthCodeURL(ctx, "state", "nonce", oidc.S256Challenge(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, err := provider.Authorize(authURL, "subject", claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := client.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client.VerifyIDToken(ctx, token.IDToken, "nonce")
+}
+
+func TestAuthCodeURLSendsPKCEChallenge(t *testing.T) {
+	provider := oidctest.NewProvider("client")
+	defer provider.Close()
+
+	client := oidc.NewClient(provider.Config("stub"))
+	authURL, err := client.AuthCodeURL(context.Background(), "state", "nonce", oidc.S256Challenge("verifier"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := parsed.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             "client",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge":        oidc.S256Challenge("verifier"),
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := query.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestS256Challenge(t *testing.T) {
+	// RFC 7636, appendix B.
+	got := oidc.S256Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Fatalf("S256Challenge = %q, want %q", got, want)
+	}
+}
+
+func TestExchangeRoundTripsPKCEVerifier(t *testing.T) {
+	provider := oidctest.NewProvider("client")
+	defer provider.Close()
+
+	claims, err := login(t, provider, jwt.MapClaims{"email": "alice@example.com", "email_verified": "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Subject != "subject" || claims.Email != "alice@example.com" || !claims.EmailVerified {
+		t.Fatalf("claims = %+v", claims)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	provider := oidctest.NewProvider("client")
+	defer provider.Close()
+
+	client := oidc.NewClient(provider.Config("stub"))
+	ctx := context.Background()
+
+	authURL, err := client.AuthCodeURL(ctx, "state", "nonce", oidc.S256Challenge("verifier"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, err := provider.Authorize(authURL, "subject", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Exchange(ctx, code, "other-verifier"); !errors.Is(err, oidc.ErrProviderError) {
+		t.Fatalf("err = %v, want %v", err, oidc.ErrProviderError)
+	}
+}
+
+func TestVerifyIDTokenRejectsMismatches(t *testing.T) {
+	provider := oidctest.NewProvider("client")
+	defer provider.Close()
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{"nonce", jwt.MapClaims{"nonce": "other"}},
+		{"issuer", jwt.MapClaims{"iss": "https://evil.example.com"}},
+		{"audience", jwt.MapClaims{"aud": "other-client"}},
+		{"authorized party", jwt.MapClaims{"aud": []string{"client", "other-client"}, "azp": "other-client"}},
+		{"missing authorized party", jwt.MapClaims{"aud": []string{"client", "other-client"}}},
+		{"expired", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}},
+		{"missing subject", jwt.MapClaims{"sub": ""}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := login(t, provider, test.claims)
+			if !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Fatalf("err = %v, want %v", err, oidc.ErrInvalidIDToken)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenAcceptsAuthorizedParty(t *testing.T) {
+	provider := oidctest.NewProvider("client")
+	defer provider.Close()
+
+	_, err := login(t, provider, jwt.MapClaims{"aud": []string{"client", "other-client"}, "azp": "client"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,88 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidIDToken = errors.New("invalid ID token")
+
+// idTokenLeeway tolerates clock skew between us and the provider.
+const idTokenLeeway = time.Minute
+
+var idTokenAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+type IDTokenClaims struct {
+	Email             string       `json:"email"`
+	EmailVerified     flexibleBool `json:"email_verified"`
+	Name              string       `json:"name"`
+	PreferredUsername string       `json:"preferred_username"`
+	Nonce             string       `json:"nonce"`
+	AuthorizedParty   string       `json:"azp"`
+	jwt.RegisteredClaims
+}
+
+// VerifyIDToken checks the ID token's signature against the provider's
+// keys, its issuer, audience, lifetime and that it carries the login's
+// nonce.
+func (client *Client) VerifyIDToken(ctx context.Context, raw string, nonce string) (*IDTokenClaims, error) {
+	if _, err := client.Discover(ctx); err != nil {
+		return nil, err
+	}
+
+	claims := &IDTokenClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return client.keys.get(ctx, client, kid)
+	},
+		jwt.WithValidMethods(idTokenAlgorithms),
+		jwt.WithIssuer(client.Config.Issuer),
+		jwt.WithAudience(client.Config.ClientID),
+		jwt.WithLeeway(idTokenLeeway),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != client.Config.ClientID {
+		return nil, fmt.Errorf("%w: token was issued to %q", ErrInvalidIDToken, claims.AuthorizedParty)
+	}
+
+	return claims, nil
+}
+
+// flexibleBool accepts both true and "true", as some providers send
+// email_verified as a string.
+type flexibleBool bool
+
+func (value *flexibleBool) UnmarshalJSON(data []byte) error {
+	var parsed interface{}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+
+	switch typed := parsed.(type) {
+	case bool:
+		*value = flexibleBool(typed)
+	case string:
+		*value = typed == "true"
+	default:
+		*value = false
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+var ErrUnknownKey = errors.New("unknown provider signing key")
+
+// minKeyRefresh limits how often an unknown kid makes us refetch the
+// provider's keys.
+const minKeyRefresh = time.Minute
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// keyCache holds the provider's public keys by kid. Keys are refetched when
+// a token names a kid we do not know, which is how providers rotate.
+type keyCache struct {
+	url string
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func (cache *keyCache) get(ctx context.Context, client *Client, kid string) (crypto.PublicKey, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if key, ok := cache.lookup(kid); ok {
+		return key, nil
+	}
+
+	if time.Since(cache.fetchedAt) < minKeyRefresh {
+		return nil, ErrUnknownKey
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := client.getJSON(ctx, cache.url, &set); err != nil {
+		return nil, fmt.Errorf("fetching provider keys: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		public, err := key.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[key.Kid] = public
+	}
+
+	cache.keys = keys
+	cache.fetchedAt = time.Now()
+
+	if key, ok := cache.lookup(kid); ok {
+		return key, nil
+	}
+
+	return nil, ErrUnknownKey
+}
+
+// lookup finds the key by kid. Tokens without a kid are accepted when the
+// provider publishes a single key.
+func (cache *keyCache) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(cache.keys) == 1 {
+		for _, key := range cache.keys {
+			return key, true
+		}
+	}
+
+	key, ok := cache.keys[kid]
+	return key, ok
+}
+
+func (key jwk) publicKey() (crypto.PublicKey, error) {
+	switch key.Kty {
+	case "RSA":
+		n, err := decodeBigInt(key.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(key.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", key.Crv)
+		}
+		x, err := decodeBigInt(key.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(key.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if key.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", key.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(key.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", key.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,172 @@
+// Package oidctest runs a minimal OpenID Connect provider for tests: it
+// serves discovery, its keys and a token endpoint that enforces PKCE.
+package oidctest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"market/internal/oidc"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyId = "test-key"
+
+// Provider is a stub identity provider. Logins are made with Authorize
+// instead of a browser.
+type Provider struct {
+	Server   *httptest.Server
+	ClientID string
+
+	public  ed25519.PublicKey
+	private ed25519.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]grant
+}
+
+// grant is an authorization code waiting to be redeemed.
+type grant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func NewProvider(clientID string) *Provider {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	provider := &Provider{ClientID: clientID, public: public, private: private, codes: map[string]grant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", provider.discovery)
+	mux.HandleFunc("/jwks", provider.jwks)
+	mux.HandleFunc("/token", provider.token)
+	provider.Server = httptest.NewServer(mux)
+
+	return provider
+}
+
+func (provider *Provider) Issuer() string {
+	return provider.Server.URL
+}
+
+func (provider *Provider) Close() {
+	provider.Server.Close()
+}
+
+// Config is a client configuration for the provider.
+func (provider *Provider) Config(name string) oidc.Config {
+	return oidc.Config{
+		Name:        name,
+		Issuer:      provider.Issuer(),
+		ClientID:    provider.ClientID,
+		RedirectURL: "http://localhost/oidc/" + name + "/callback",
+	}
+}
+
+// Authorize signs a user in at the authorization URL a client built and
+// returns the code and state the provider would redirect back with. The ID
+// token will carry the request's nonce and the given claims, which may
+// override the defaults, e.g. "iss" or "aud".
+func (provider *Provider) Authorize(authURL string, subject string, claims jwt.MapClaims) (string, string, error) {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		return "", "", errors.New("authorization request without S256 PKCE challenge")
+	}
+
+	now := time.Now()
+	idClaims := jwt.MapClaims{
+		"iss":   provider.Issuer(),
+		"aud":   provider.ClientID,
+		"sub":   subject,
+		"nonce": query.Get("nonce"),
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	for name, value := range claims {
+		idClaims[name] = value
+	}
+
+	code, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	provider.mu.Lock()
+	provider.codes[code] = grant{challenge: query.Get("code_challenge"), claims: idClaims}
+	provider.mu.Unlock()
+
+	return code, query.Get("state"), nil
+}
+
+func (provider *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, oidc.Metadata{
+		Issuer:                provider.Issuer(),
+		AuthorizationEndpoint: provider.Issuer() + "/authorize",
+		TokenEndpoint:         provider.Issuer() + "/token",
+		JWKSURI:               provider.Issuer() + "/jwks",
+	})
+}
+
+func (provider *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"kid": keyId,
+			"use": "sig",
+			"x":   base64.RawURLEncoding.EncodeToString(provider.public),
+		}},
+	})
+}
+
+// token redeems a code once, and only with the verifier of its challenge.
+func (provider *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	provider.mu.Lock()
+	code := r.PostForm.Get("code")
+	grant, ok := provider.codes[code]
+	delete(provider.codes, code)
+	provider.mu.Unlock()
+
+	if !ok || oidc.S256Challenge(r.PostForm.Get("code_verifier")) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, grant.claims)
+	token.Header["kid"] = keyId
+	idToken, err := token.SignedString(provider.private)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, oidc.Token{AccessToken: "access", TokenType: "Bearer", IDToken: idToken, ExpiresIn: 3600})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"market/internal/database"
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+	"market/internal/mailer"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	DefaultPasswordResetTTL     = time.Hour
+	DefaultEmailVerificationTTL = 48 * time.Hour
+)
+
+var (
+	ErrInvalidAccountToken = errors.New("token is invalid or has expired")
+	ErrEmptyPassword       = errors.New("password cannot be empty")
+)
+
+type AccountService interface {
+	ForgotPassword(request models.ForgotPassword) error
+	ResetPassword(request models.ResetPassword) error
+	SendVerification(userId int) error
+	VerifyEmail(request models.VerifyEmail) error
+}
+
+// AccountServiceImpl runs the flows that prove control of a user's email
+// address with single-use tokens sent by mail.
+type AccountServiceImpl struct {
+	Repo     repositories.AccountTokenRepo
+	UserRepo repositories.UserRepo
+	Pass     PasswordManager
+	Sessions SessionService
+	APIKeys  repositories.APIKeyRepo
+	Guard    LoginGuard
+	Mailer   mailer.Mailer
+	Tx       database.Transactor
+	// BaseURL is where the links in the emails point to.
+	BaseURL         string
+	ResetTTL        time.Duration
+	VerificationTTL time.Duration
+}
+
+// ForgotPassword mails a reset link. It succeeds for unknown addresses too,
+// so the endpoint does not reveal who has an account.
+func (ser *AccountServiceImpl) ForgotPassword(request models.ForgotPassword) error {
+	user, err := ser.UserRepo.GetByEmail(strings.TrimSpace(request.Email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("Error retrieving user by email: %v", err)
+		return fmt.Errorf("failed to request password reset")
+	}
+
+	token, err := ser.createToken(user.Id, models.AccountTokenPasswordReset, ser.resetTTL())
+	if err != nil {
+		return fmt.Errorf("failed to request password reset")
+	}
+
+	err = ser.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to choose a new password. It expires in %s.\n\n%s/password/reset?token=%s\n\nIf you did not ask for this, ignore this email.",
+			user.Username, ser.resetTTL(), ser.BaseURL, token),
+	})
+	if err != nil {
+		log.Printf("Error sending password reset mail: %v", err)
+		return fmt.Errorf("failed to send email")
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token, ends all of the
+// user's sessions, revokes their API keys and lifts a login lockout.
+func (ser *AccountServiceImpl) ResetPassword(request models.ResetPassword) error {
+	if len(strings.TrimSpace(request.Password)) == 0 {
+		return ErrEmptyPassword
+	}
+
+	var userId int
+	err := ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		token, err := ser.useToken(tx, models.AccountTokenPasswordReset, request.Token)
+		if err != nil {
+			return err
+		}
+		userId = token.UserId
+
+		hashedPassword, err := ser.Pass.HashPassword(request.Password)
+		if err != nil {
+			return fmt.Errorf("failed to hash password")
+		}
+
+		if err := ser.UserRepo.WithTx(tx).UpdatePassword(token.UserId, hashedPassword); err != nil {
+			log.Printf("Error updating password: %v", err)
+			return fmt.Errorf("failed to reset password")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := ser.Sessions.RevokeUser(userId); err != nil {
+		return err
+	}
+
+	if err := ser.APIKeys.RevokeAllForUser(userId); err != nil {
+		log.Printf("Error revoking API keys: %v", err)
+		return fmt.Errorf("failed to revoke API keys")
+	}
+
+	// Whoever can read the user's email may lift a login lockout.
+	user, err := ser.UserRepo.Get(userId)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		return nil
+	}
+
+	return ser.Guard.Unlock(user.Username, nil, models.UnlockReasonPasswordReset)
+}
+
+func (ser *AccountServiceImpl) SendVerification(userId int) error {
+	user, err := ser.UserRepo.Get(userId)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return fmt.Errorf("email is already verified")
+	}
+
+	token, err := ser.createToken(user.Id, models.AccountTokenEmailVerification, ser.verificationTTL())
+	if err != nil {
+		return fmt.Errorf("failed to send verification")
+	}
+
+	err = ser.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to verify your email address. It expires in %s.\n\n%s/email/verify?token=%s",
+			user.Username, ser.verificationTTL(), ser.BaseURL, token),
+	})
+	if err != nil {
+		log.Printf("Error sending verification mail: %v", err)
+		return fmt.Errorf("failed to send email")
+	}
+
+	return nil
+}
+
+func (ser *AccountServiceImpl) VerifyEmail(request models.VerifyEmail) error {
+	return ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		token, err := ser.useToken(tx, models.AccountTokenEmailVerification, request.Token)
+		if err != nil {
+			return err
+		}
+
+		if err := ser.UserRepo.WithTx(tx).MarkEmailVerified(token.UserId); err != nil {
+			log.Printf("Error verifying email: %v", err)
+			return fmt.Errorf("failed to verify email")
+		}
+
+		return nil
+	})
+}
+
+// createToken replaces the user's outstanding tokens of the purpose with a
+// new one and returns it in plain text for the email.
+func (ser *AccountServiceImpl) createToken(userId int, purpose string, ttl time.Duration) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		log.Printf("Error generating account token: %v", err)
+		return "", err
+	}
+
+	err = ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		repo := ser.Repo.WithTx(tx)
+
+		if err := repo.InvalidateForUser(userId, purpose); err != nil {
+			return err
+		}
+
+		return repo.Create(userId, purpose, hashToken(token), time.Now().Add(ttl))
+	})
+	if err != nil {
+		log.Printf("Error creating account token: %v", err)
+		return "", err
+	}
+
+	return token, nil
+}
+
+// useToken consumes a token inside the caller's transaction.
+func (ser *AccountServiceImpl) useToken(tx *sqlx.Tx, purpose string, plain string) (models.AccountToken, error) {
+	repo := ser.Repo.WithTx(tx)
+
+	token, err := repo.GetByHashForUpdate(purpose, hashToken(plain))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.AccountToken{}, ErrInvalidAccountToken
+	}
+	if err != nil {
+		log.Printf("Error retrieving account token: %v", err)
+		return models.AccountToken{}, fmt.Errorf("failed to check token")
+	}
+
+	if token.UsedAt != nil || !token.ExpiresAt.After(time.Now()) {
+		return models.AccountToken{}, ErrInvalidAccountToken
+	}
+
+	if err := repo.MarkUsed(token.Id); err != nil {
+		log.Printf("Error using account token: %v", err)
+		return models.AccountToken{}, fmt.Errorf("failed to check token")
+	}
+
+	return token, nil
+}
+
+func (ser *AccountServiceImpl) resetTTL() time.Duration {
+	if ser.ResetTTL <= 0 {
+		return DefaultPasswordResetTTL
+	}
+	return ser.ResetTTL
+}
+
+func (ser *AccountServiceImpl) verificationTTL() time.Duration {
+	if ser.VerificationTTL <= 0 {
+		return DefaultEmailVerificationTTL
+	}
+	return ser.VerificationTTL
+}
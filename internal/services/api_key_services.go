@@ -0,0 +1,140 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+	"market/web/handlers/middlewares"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAPIKeyName     = errors.New("API key name must be 1 to 100 characters")
+	ErrAPIKeyScope    = errors.New("unknown API key scope")
+	ErrAPIKeyNoScopes = errors.New("API key needs at least one scope")
+	ErrAPIKeyExpiry   = errors.New("API key expiry must be in the future")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrAPIKeyInvalid  = errors.New("API key is invalid, expired or revoked")
+)
+
+type APIKeyService interface {
+	Create(newKey models.NewAPIKey, claims *middlewares.Claims) (models.CreatedAPIKey, error)
+	GetAll(claims *middlewares.Claims) ([]models.APIKey, error)
+	Revoke(id int, claims *middlewares.Claims) error
+	AuthenticateAPIKey(key string) (*middlewares.Claims, error)
+}
+
+// APIKeyServiceImpl manages long-lived personal keys for scripts and bots.
+// Only a hash of each key is stored; the key itself is returned once, when
+// it is created.
+type APIKeyServiceImpl struct {
+	Repo     repositories.APIKeyRepo
+	UserRepo repositories.UserRepo
+}
+
+func (ser *APIKeyServiceImpl) Create(newKey models.NewAPIKey, claims *middlewares.Claims) (models.CreatedAPIKey, error) {
+	newKey.Name = strings.TrimSpace(newKey.Name)
+	if newKey.Name == "" || len(newKey.Name) > 100 {
+		return models.CreatedAPIKey{}, ErrAPIKeyName
+	}
+
+	scopes := make([]string, 0, len(newKey.Scopes))
+	for _, scope := range newKey.Scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !slices.Contains(models.APIKeyScopes, scope) {
+			return models.CreatedAPIKey{}, fmt.Errorf("%w %q", ErrAPIKeyScope, scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return models.CreatedAPIKey{}, ErrAPIKeyNoScopes
+	}
+	newKey.Scopes = scopes
+
+	if newKey.ExpiresAt != nil && !newKey.ExpiresAt.After(time.Now()) {
+		return models.CreatedAPIKey{}, ErrAPIKeyExpiry
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		log.Printf("Error generating API key: %v", err)
+		return models.CreatedAPIKey{}, fmt.Errorf("failed to create API key")
+	}
+	plain := middlewares.APIKeyPrefix + secret
+
+	key, err := ser.Repo.Create(claims.UserId, plain[:len(middlewares.APIKeyPrefix)+8], hashToken(plain), newKey)
+	if err != nil {
+		log.Printf("Error creating API key: %v", err)
+		return models.CreatedAPIKey{}, fmt.Errorf("failed to create API key")
+	}
+
+	return models.CreatedAPIKey{APIKey: key, Key: plain}, nil
+}
+
+func (ser *APIKeyServiceImpl) GetAll(claims *middlewares.Claims) ([]models.APIKey, error) {
+	keys, err := ser.Repo.GetByUser(claims.UserId)
+	if err != nil {
+		log.Printf("Error retrieving API keys: %v", err)
+		return nil, fmt.Errorf("failed to get API keys")
+	}
+
+	return keys, nil
+}
+
+func (ser *APIKeyServiceImpl) Revoke(id int, claims *middlewares.Claims) error {
+	revoked, err := ser.Repo.Revoke(id, claims.UserId)
+	if err != nil {
+		log.Printf("Error revoking API key: %v", err)
+		return fmt.Errorf("failed to revoke API key")
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey returns the claims a request made with the key acts
+// with: the owner and the key's scopes, but none of the owner's role
+// permissions.
+func (ser *APIKeyServiceImpl) AuthenticateAPIKey(plain string) (*middlewares.Claims, error) {
+	if !strings.HasPrefix(plain, middlewares.APIKeyPrefix) {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	key, err := ser.Repo.GetByHash(hashToken(plain))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyInvalid
+	}
+	if err != nil {
+		log.Printf("Error retrieving API key: %v", err)
+		return nil, fmt.Errorf("failed to check API key")
+	}
+
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now())) {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	user, err := ser.UserRepo.Get(key.UserId)
+	if err != nil || user.BannedAt != nil {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	if err := ser.Repo.Touch(key.Id); err != nil {
+		log.Printf("Error recording API key use: %v", err)
+	}
+
+	return &middlewares.Claims{
+		UserId:   user.Id,
+		Username: user.Username,
+		APIKeyId: key.Id,
+		Scopes:   key.Scopes,
+	}, nil
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"market/internal/database"
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const DefaultAuctionExtension = 2 * time.Minute
+
+type AuctionService interface {
+	Create(newAuction models.NewAuction, userId int) (models.Auction, error)
+	Get(id int) (models.Auction, error)
+	GetOpen(page database.PageInfo) (database.Page[models.Auction], error)
+	PlaceBid(auctionId int, amount models.Money, userId int) (models.Bid, error)
+	GetBids(auctionId int, page database.PageInfo) (database.Page[models.Bid], error)
+	CloseExpired() (int, error)
+}
+
+type AuctionServiceImpl struct {
+	Repo        repositories.AuctionRepo
+	ItemRepo    repositories.ItemRepo
+	DealService DealService
+	Tx          database.Transactor
+	// Extension is the anti-sniping window: a bid landing this close to the
+	// end pushes the end out to this long after the bid.
+	Extension time.Duration
+}
+
+func (ser *AuctionServiceImpl) Create(newAuction models.NewAuction, userId int) (models.Auction, error) {
+	if err := validateAmount(newAuction.StartPrice); err != nil {
+		return models.Auction{}, err
+	}
+
+	if err := validateAmount(newAuction.Increment); err != nil {
+		return models.Auction{}, fmt.Errorf("invalid increment: %w", err)
+	}
+
+	if newAuction.ReservePrice.Currency == "" {
+		newAuction.ReservePrice = models.NewMoney(0, newAuction.StartPrice.Currency)
+	}
+
+	if newAuction.ReservePrice.Amount < 0 {
+		return models.Auction{}, fmt.Errorf("reserve price cannot be negative")
+	}
+
+	currency := newAuction.StartPrice.Currency
+	if newAuction.Increment.Currency != currency || newAuction.ReservePrice.Currency != currency {
+		return models.Auction{}, fmt.Errorf("auction prices must share one currency")
+	}
+
+	if !newAuction.EndsAt.After(time.Now()) {
+		return models.Auction{}, fmt.Errorf("auction must end in the future")
+	}
+
+	item, err := ser.ItemRepo.Get(newAuction.ItemId)
+	if err != nil {
+		log.Printf("Item not found: %v", err)
+		return models.Auction{}, fmt.Errorf("item not found")
+	}
+
+	if item.OwnerId != userId {
+		return models.Auction{}, fmt.Errorf("you can only auction your own items")
+	}
+
+	newAuction.SellerId = userId
+
+	auction, err := ser.Repo.Create(newAuction)
+	if err != nil {
+		log.Printf("Error creating auction: %v", err)
+		return models.Auction{}, fmt.Errorf("failed to create auction")
+	}
+
+	return auction, nil
+}
+
+func (ser *AuctionServiceImpl) Get(id int) (models.Auction, error) {
+	if id <= 0 {
+		return models.Auction{}, fmt.Errorf("invalid auction ID")
+	}
+
+	auction, err := ser.Repo.Get(id)
+	if err != nil {
+		log.Printf("Error retrieving auction: %v", err)
+		return models.Auction{}, fmt.Errorf("auction not found")
+	}
+
+	return auction, nil
+}
+
+func (ser *AuctionServiceImpl) GetOpen(page database.PageInfo) (database.Page[models.Auction], error) {
+	if page.PageNumber <= 0 || page.PageSize <= 0 {
+		return database.Page[models.Auction]{}, fmt.Errorf("invalid pagination")
+	}
+
+	auctions, err := ser.Repo.GetOpen(page)
+	if err != nil {
+		log.Printf("Error retrieving auctions: %v", err)
+		return database.Page[models.Auction]{}, fmt.Errorf("failed to get auctions")
+	}
+
+	total, err := ser.Repo.CountOpen()
+	if err != nil {
+		log.Printf("Error counting auctions: %v", err)
+		return database.Page[models.Auction]{}, fmt.Errorf("failed to get auctions")
+	}
+
+	return database.NewPage(auctions, total, page), nil
+}
+
+// PlaceBid records a bid that meets the start price, or beats the leading
+// bid by at least the increment, and extends the auction when the bid lands
+// inside the anti-sniping window.
+func (ser *AuctionServiceImpl) PlaceBid(auctionId int, amount models.Money, userId int) (models.Bid, error) {
+	if err := validateAmount(amount); err != nil {
+		return models.Bid{}, err
+	}
+
+	var bid models.Bid
+	err := ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		repo := ser.Repo.WithTx(tx)
+
+		auction, err := repo.GetForUpdate(auctionId)
+		if err != nil {
+			log.Printf("Auction not found: %v", err)
+			return fmt.Errorf("auction not found")
+		}
+
+		now := time.Now()
+		if auction.Status != models.AuctionStatusOpen || !now.Before(auction.EndsAt) {
+			return fmt.Errorf("auction is closed")
+		}
+
+		if auction.SellerId == userId {
+			return fmt.Errorf("you cannot bid on your own auction")
+		}
+
+		minimum := auction.StartPrice
+		highest, err := repo.GetHighestBid(auction.Id)
+		switch {
+		case err == nil:
+			minimum, err = highest.Amount.Add(auction.Increment)
+			if err != nil {
+				return err
+			}
+		case !errors.Is(err, sql.ErrNoRows):
+			log.Printf("Error retrieving highest bid: %v", err)
+			return fmt.Errorf("failed to place bid")
+		}
+
+		cmp, err := amount.Cmp(minimum)
+		if err != nil {
+			return fmt.Errorf("bid currency does not match auction currency")
+		}
+
+		if cmp < 0 {
+			return fmt.Errorf("bid must be at least %s", minimum)
+		}
+
+		bid, err = repo.CreateBid(auction.Id, userId, amount)
+		if err != nil {
+			log.Printf("Error creating bid: %v", err)
+			return fmt.Errorf("failed to place bid")
+		}
+
+		extension := ser.extension()
+		if auction.EndsAt.Sub(now) < extension {
+			if err := repo.ExtendEnd(auction.Id, now.Add(extension)); err != nil {
+				log.Printf("Error extending auction: %v", err)
+				return fmt.Errorf("failed to place bid")
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return models.Bid{}, err
+	}
+
+	return bid, nil
+}
+
+func (ser *AuctionServiceImpl) GetBids(auctionId int, page database.PageInfo) (database.Page[models.Bid], error) {
+	if page.PageNumber <= 0 || page.PageSize <= 0 {
+		return database.Page[models.Bid]{}, fmt.Errorf("invalid pagination")
+	}
+
+	bids, err := ser.Repo.GetBids(auctionId, page)
+	if err != nil {
+		log.Printf("Error retrieving bids: %v", err)
+		return database.Page[models.Bid]{}, fmt.Errorf("failed to get bids")
+	}
+
+	total, err := ser.Repo.CountBids(auctionId)
+	if err != nil {
+		log.Printf("Error counting bids: %v", err)
+		return database.Page[models.Bid]{}, fmt.Errorf("failed to get bids")
+	}
+
+	return database.NewPage(bids, total, page), nil
+}
+
+// CloseExpired closes every auction past its end time, one transaction per
+// auction, and returns how many were closed. A winning bid that meets the
+// reserve becomes an accepted deal for the winner to pay.
+func (ser *AuctionServiceImpl) CloseExpired() (int, error) {
+	closed := 0
+	for {
+		found := false
+		err := ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+			auction, err := ser.Repo.WithTx(tx).GetExpiredForUpdate()
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			found = true
+			return ser.close(tx, auction)
+		})
+		if err != nil {
+			return closed, err
+		}
+
+		if !found {
+			return closed, nil
+		}
+
+		closed++
+	}
+}
+
+func (ser *AuctionServiceImpl) close(tx *sqlx.Tx, auction models.Auction) error {
+	repo := ser.Repo.WithTx(tx)
+
+	winner, err := repo.GetHighestBid(auction.Id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && winner.Amount.Amount < auction.ReservePrice.Amount) {
+		return repo.Close(auction.Id, models.AuctionStatusUnsold, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	item, err := ser.ItemRepo.WithTx(tx).GetForUpdate(auction.ItemId)
+	if err != nil {
+		return err
+	}
+
+	if item.OwnerId != auction.SellerId {
+		log.Printf("Auction %d closed unsold: seller no longer owns item %d", auction.Id, item.Id)
+		return repo.Close(auction.Id, models.AuctionStatusUnsold, nil)
+	}
+
+	deal, err := ser.DealService.CreateAgreed(tx, models.NewDeal{
+		Item:   item,
+		User:   models.User{Id: winner.UserId},
+		Seller: models.User{Id: auction.SellerId},
+		Price:  winner.Amount,
+	}, auction.SellerId)
+	if err != nil {
+		return err
+	}
+
+	return repo.Close(auction.Id, models.AuctionStatusSold, &deal.Id)
+}
+
+func (ser *AuctionServiceImpl) extension() time.Duration {
+	if ser.Extension <= 0 {
+		return DefaultAuctionExtension
+	}
+	return ser.Extension
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"market/internal/database"
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrCategoryNotFound    = errors.New("category not found")
+	ErrInvalidCategory     = errors.New("invalid category")
+	ErrCategoryHasChildren = errors.New("category has subcategories")
+	ErrInvalidAttributes   = errors.New("invalid item attributes")
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+type CategoryService interface {
+	GetAll() ([]models.Category, error)
+	Get(id int) (models.Category, error)
+	Create(category models.NewCategory) (models.Category, error)
+	Update(category models.NewCategory) (models.Category, error)
+	Delete(id int) error
+	// Attributes returns the definitions that apply to items in the
+	// category: its own and those inherited from its parents.
+	Attributes(categoryId int) ([]models.CategoryAttribute, error)
+}
+
+type CategoryServiceImpl struct {
+	Repo repositories.CategoryRepo
+	Tx   database.Transactor
+}
+
+func (ser *CategoryServiceImpl) GetAll() ([]models.Category, error) {
+	categories, err := ser.Repo.GetAll()
+	if err != nil {
+		log.Printf("Error retrieving categories: %v", err)
+		return nil, fmt.Errorf("failed to get categories")
+	}
+
+	return categories, nil
+}
+
+func (ser *CategoryServiceImpl) Get(id int) (models.Category, error) {
+	category, err := ser.Repo.Get(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Category{}, ErrCategoryNotFound
+	}
+	if err != nil {
+		log.Printf("Error retrieving category: %v", err)
+		return models.Category{}, fmt.Errorf("failed to get category")
+	}
+
+	category.Attributes, err = ser.Attributes(id)
+	if err != nil {
+		return models.Category{}, err
+	}
+
+	return category, nil
+}
+
+func (ser *CategoryServiceImpl) Create(category models.NewCategory) (models.Category, error) {
+	if err := validateCategory(&category); err != nil {
+		return models.Category{}, err
+	}
+
+	var created models.Category
+	err := ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		repo := ser.Repo.WithTx(tx)
+
+		if err := ser.checkParent(repo, category); err != nil {
+			return err
+		}
+
+		var err error
+		created, err = repo.Create(category)
+		if err != nil {
+			log.Printf("Error creating category: %v", err)
+			return fmt.Errorf("failed to create category, the slug may be taken")
+		}
+
+		if err := repo.ReplaceAttributes(created.Id, category.Attributes); err != nil {
+			log.Printf("Error saving category attributes: %v", err)
+			return fmt.Errorf("failed to create category")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return models.Category{}, err
+	}
+
+	return ser.Get(created.Id)
+}
+
+// Update replaces the category's name, slug, parent and own attribute
+// definitions. Existing items are checked against the new definitions the
+// next time they are updated.
+func (ser *CategoryServiceImpl) Update(category models.NewCategory) (models.Category, error) {
+	if err := validateCategory(&category); err != nil {
+		return models.Category{}, err
+	}
+
+	err := ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		repo := ser.Repo.WithTx(tx)
+
+		if _, err := repo.Get(category.Id); err != nil {
+			return ErrCategoryNotFound
+		}
+
+		if err := ser.checkParent(repo, category); err != nil {
+			return err
+		}
+
+		if err := repo.Update(category); err != nil {
+			log.Printf("Error updating category: %v", err)
+			return fmt.Errorf("failed to update category, the slug may be taken")
+		}
+
+		if err := repo.ReplaceAttributes(category.Id, category.Attributes); err != nil {
+			log.Printf("Error saving category attributes: %v", err)
+			return fmt.Errorf("failed to update category")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return models.Category{}, err
+	}
+
+	return ser.Get(category.Id)
+}
+
+// Delete removes a category without subcategories. Its items stay, without
+// a category.
+func (ser *CategoryServiceImpl) Delete(id int) error {
+	if _, err := ser.Repo.Get(id); err != nil {
+		return ErrCategoryNotFound
+	}
+
+	children, err := ser.Repo.CountChildren(id)
+	if err != nil {
+		log.Printf("Error counting subcategories: %v", err)
+		return fmt.Errorf("failed to delete category")
+	}
+	if children > 0 {
+		return ErrCategoryHasChildren
+	}
+
+	if err := ser.Repo.Delete(id); err != nil {
+		log.Printf("Error deleting category: %v", err)
+		return fmt.Errorf("failed to delete category")
+	}
+
+	return nil
+}
+
+func (ser *CategoryServiceImpl) Attributes(categoryId int) ([]models.CategoryAttribute, error) {
+	ancestors, err := ser.Repo.GetAncestors(categoryId)
+	if err != nil {
+		log.Printf("Error retrieving parent categories: %v", err)
+		return nil, fmt.Errorf("failed to get category attributes")
+	}
+	if len(ancestors) == 0 {
+		return nil, ErrCategoryNotFound
+	}
+
+	ids := make([]int, len(ancestors))
+	for i, ancestor := range ancestors {
+		ids[i] = ancestor.Id
+	}
+
+	attributes, err := ser.Repo.GetAttributes(ids)
+	if err != nil {
+		log.Printf("Error retrieving category attributes: %v", err)
+		return nil, fmt.Errorf("failed to get category attributes")
+	}
+
+	// A subcategory's definition replaces a parent's one of the same name.
+	effective := []models.CategoryAttribute{}
+	for _, id := range ids {
+		for _, attribute := range attributes {
+			if attribute.CategoryId != id {
+				continue
+			}
+			effective = slices.DeleteFunc(effective, func(other models.CategoryAttribute) bool {
+				return other.Name == attribute.Name
+			})
+			effective = append(effective, attribute)
+		}
+	}
+
+	return effective, nil
+}
+
+// checkParent makes sure the parent exists and is not the category itself
+// or one of its subcategories.
+func (ser *CategoryServiceImpl) checkParent(repo repositories.CategoryRepo, category models.NewCategory) error {
+	if category.ParentId == nil {
+		return nil
+	}
+
+	ancestors, err := repo.GetAncestors(*category.ParentId)
+	if err != nil {
+		log.Printf("Error retrieving parent categories: %v", err)
+		return fmt.Errorf("failed to save category")
+	}
+	if len(ancestors) == 0 {
+		return fmt.Errorf("%w: parent category not found", ErrInvalidCategory)
+	}
+
+	for _, ancestor := range ancestors {
+		if category.Id != 0 && ancestor.Id == category.Id {
+			return fmt.Errorf("%w: a category cannot be its own parent", ErrInvalidCategory)
+		}
+	}
+
+	return nil
+}
+
+func validateCategory(category *models.NewCategory) error {
+	category.Name = fixName(category.Name)
+	if category.Name == "" || len(category.Name) > 50 {
+		return fmt.Errorf("%w: name must be 1 to 50 characters", ErrInvalidCategory)
+	}
+
+	category.Slug = strings.ToLower(strings.TrimSpace(category.Slug))
+	if !slugPattern.MatchString(category.Slug) || len(category.Slug) > 50 {
+		return fmt.Errorf("%w: slug must be lowercase letters, digits and dashes", ErrInvalidCategory)
+	}
+
+	seen := map[string]bool{}
+	for i := range category.Attributes {
+		attribute := &category.Attributes[i]
+
+		attribute.Name = strings.TrimSpace(attribute.Name)
+		if attribute.Name == "" || len(attribute.Name) > 50 {
+			return fmt.Errorf("%w: attribute names must be 1 to 50 characters", ErrInvalidCategory)
+		}
+		if seen[attribute.Name] {
+			return fmt.Errorf("%w: attribute %q is defined twice", ErrInvalidCategory, attribute.Name)
+		}
+		seen[attribute.Name] = true
+
+		switch attribute.Type {
+		case models.AttributeEnum:
+			if len(attribute.Options) == 0 {
+				return fmt.Errorf("%w: enum attribute %q needs options", ErrInvalidCategory, attribute.Name)
+			}
+		case models.AttributeString, models.AttributeNumber, models.AttributeBool:
+			if len(attribute.Options) > 0 {
+				return fmt.Errorf("%w: only enum attributes have options", ErrInvalidCategory)
+			}
+			attribute.Options = []string{}
+		default:
+			return fmt.Errorf("%w: attribute %q has unknown type %q", ErrInvalidCategory, attribute.Name, attribute.Type)
+		}
+	}
+
+	return nil
+}
+
+// validateAttributes checks item attribute values against the definitions
+// of the item's category and returns them cleaned up.
+func validateAttributes(definitions []models.CategoryAttribute, values models.Attributes) (models.Attributes, error) {
+	cleaned := models.Attributes{}
+
+	for name := range values {
+		if !slices.ContainsFunc(definitions, func(definition models.CategoryAttribute) bool { return definition.Name == name }) {
+			return nil, fmt.Errorf("%w: unknown attribute %q", ErrInvalidAttributes, name)
+		}
+	}
+
+	for _, definition := range definitions {
+		value, ok := values[definition.Name]
+		if !ok || value == nil {
+			if definition.Required {
+				return nil, fmt.Errorf("%w: %q is required", ErrInvalidAttributes, definition.Name)
+			}
+			continue
+		}
+
+		switch definition.Type {
+		case models.AttributeString:
+			text, ok := value.(string)
+			text = strings.TrimSpace(text)
+			if !ok || len(text) > 255 || (definition.Required && text == "") {
+				return nil, fmt.Errorf("%w: %q must be a text of up to 255 characters", ErrInvalidAttributes, definition.Name)
+			}
+			value = text
+		case models.AttributeNumber:
+			number, ok := value.(float64)
+			if !ok || math.IsNaN(number) || math.IsInf(number, 0) {
+				return nil, fmt.Errorf("%w: %q must be a number", ErrInvalidAttributes, definition.Name)
+			}
+		case models.AttributeEnum:
+			option, ok := value.(string)
+			if !ok || !slices.Contains(definition.Options, option) {
+				return nil, fmt.Errorf("%w: %q must be one of %s", ErrInvalidAttributes, definition.Name, strings.Join(definition.Options, ", "))
+			}
+		case models.AttributeBool:
+			if _, ok := value.(bool); !ok {
+				return nil, fmt.Errorf("%w: %q must be true or false", ErrInvalidAttributes, definition.Name)
+			}
+		}
+
+		cleaned[definition.Name] = value
+	}
+
+	return cleaned, nil
+}
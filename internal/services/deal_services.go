@@ -1,40 +1,103 @@
 package services
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"market/internal/database"
 	"market/internal/database/models"
+	"market/internal/database/query"
 	"market/internal/database/repositories"
 	"market/web/handlers/middlewares"
+
+	"github.com/jmoiron/sqlx"
 )
 
 type DealService interface {
 	Create(deal models.NewDeal, userId int) (models.Deal, error)
+	CreateAgreed(tx *sqlx.Tx, deal models.NewDeal, actorId int) (models.Deal, error)
 	Get(id int) (models.Deal, error)
-	GetAll(page database.PageInfo) ([]models.Deal, error)
+	GetAll(page database.PageInfo, params query.Params) (database.Page[models.Deal], error)
+	GetAllByCursor(cursor string, size int, params query.Params) (database.CursorPage[models.Deal], error)
+	GetEvents(id int, claims *middlewares.Claims) ([]models.DealEvent, error)
 	Update(deal models.Deal, claims *middlewares.Claims) (models.Deal, error)
+	Transition(id int, to string, claims *middlewares.Claims) (models.Deal, error)
+	Resolve(id int, to string, claims *middlewares.Claims) (models.Deal, error)
+	ReleaseDue() (int, error)
 	Delete(id int, claims *middlewares.Claims) error
 }
 
 type DealServiceImpl struct {
-	Repo repositories.DealRepo
+	Repo     repositories.DealRepo
+	ItemRepo repositories.ItemRepo
+	Escrow   EscrowService
+	Tx       database.Transactor
 }
 
+// Create opens a deal in the offered status. Nothing changes hands until the
+// seller accepts and the buyer pays.
 func (ser *DealServiceImpl) Create(newDeal models.NewDeal, userId int) (models.Deal, error) {
-	if newDeal.Price <= 0 {
+	if err := newDeal.Price.Validate(); err != nil {
+		return models.Deal{}, fmt.Errorf("unsupported currency")
+	}
+
+	if !newDeal.Price.IsPositive() {
 		return models.Deal{}, fmt.Errorf("price must be positive")
 	}
 
-	_, err := ser.Repo.Get(int(newDeal.Item.Id))
+	item, err := ser.ItemRepo.Get(newDeal.Item.Id)
 	if err != nil {
 		log.Printf("Item not found: %v", err)
 		return models.Deal{}, fmt.Errorf("item not found")
 	}
 
+	if item.OwnerId == userId {
+		return models.Deal{}, fmt.Errorf("you already own this item")
+	}
+
+	cmp, err := newDeal.Price.Cmp(item.Price)
+	if err != nil {
+		return models.Deal{}, fmt.Errorf("deal currency does not match item currency")
+	}
+
+	if cmp < 0 {
+		return models.Deal{}, fmt.Errorf("price is below the item price")
+	}
+
+	newDeal.Item = item
 	newDeal.User.Id = userId
+	newDeal.Seller.Id = item.OwnerId
+	newDeal.Status = models.DealStatusOffered
+
+	var createdDeal models.Deal
+	err = ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		createdDeal, err = ser.insert(tx, newDeal, userId)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error creating deal: %v", err)
+		return models.Deal{}, fmt.Errorf("failed to create deal")
+	}
+
+	return createdDeal, nil
+}
+
+// CreateAgreed records a deal whose price both sides have already agreed
+// on, such as an accepted offer, directly in the accepted status. It runs in
+// the caller's transaction.
+func (ser *DealServiceImpl) CreateAgreed(tx *sqlx.Tx, newDeal models.NewDeal, actorId int) (models.Deal, error) {
+	if !newDeal.Price.IsPositive() {
+		return models.Deal{}, fmt.Errorf("price must be positive")
+	}
+
+	if newDeal.User.Id == newDeal.Seller.Id {
+		return models.Deal{}, fmt.Errorf("buyer and seller must differ")
+	}
+
+	newDeal.Status = models.DealStatusAccepted
 
-	createdDeal, err := ser.Repo.Create(newDeal)
+	createdDeal, err := ser.insert(tx, newDeal, actorId)
 	if err != nil {
 		log.Printf("Error creating deal: %v", err)
 		return models.Deal{}, fmt.Errorf("failed to create deal")
@@ -43,6 +106,289 @@ func (ser *DealServiceImpl) Create(newDeal models.NewDeal, userId int) (models.D
 	return createdDeal, nil
 }
 
+func (ser *DealServiceImpl) insert(tx *sqlx.Tx, newDeal models.NewDeal, actorId int) (models.Deal, error) {
+	dealRepo := ser.Repo.WithTx(tx)
+
+	createdDeal, err := dealRepo.Create(newDeal)
+	if err != nil {
+		return models.Deal{}, err
+	}
+
+	err = dealRepo.AddEvent(models.DealEvent{DealId: createdDeal.Id, ToStatus: createdDeal.Status, ActorId: &actorId})
+
+	return createdDeal, err
+}
+
+// Transition moves a deal to a new status if the state machine allows it for
+// the caller, recording the change in the deal history. Paying puts the price
+// in escrow, completing releases it to the seller together with the item, and
+// cancelling a paid deal refunds the buyer. Completing a deal whose item the
+// seller no longer has cancels it with a refund instead.
+func (ser *DealServiceImpl) Transition(id int, to string, claims *middlewares.Claims) (models.Deal, error) {
+	check := func(tx *sqlx.Tx, deal models.Deal, to string) error {
+		return ser.checkTransition(tx, deal, to, claims.UserId)
+	}
+	return ser.transition(id, to, &claims.UserId, check)
+}
+
+// Resolve settles a disputed deal for users who may resolve disputes, either
+// completing it in the seller's favour or cancelling it with a refund to the
+// buyer. The decision is recorded in the deal history under the resolver.
+func (ser *DealServiceImpl) Resolve(id int, to string, claims *middlewares.Claims) (models.Deal, error) {
+	if !claims.HasPermission(models.PermDealsResolve) {
+		return models.Deal{}, ErrDealForbidden
+	}
+
+	return ser.transition(id, to, &claims.UserId, checkResolution)
+}
+
+// ReleaseDue completes paid deals whose escrow hold has timed out without the
+// buyer confirming delivery, and returns how many were completed.
+func (ser *DealServiceImpl) ReleaseDue() (int, error) {
+	dealIds, err := ser.Escrow.GetDueDealIds()
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, dealId := range dealIds {
+		if _, err := ser.transition(dealId, models.DealStatusCompleted, nil, ser.checkSystemTransition); err != nil {
+			log.Printf("Error releasing escrow for deal %d: %v", dealId, err)
+			continue
+		}
+		released++
+	}
+
+	return released, nil
+}
+
+// transition applies a status change on behalf of actorId, or of the system
+// when actorId is nil, once check allows it for the locked deal.
+func (ser *DealServiceImpl) transition(id int, to string, actorId *int, check func(tx *sqlx.Tx, deal models.Deal, to string) error) (models.Deal, error) {
+	if id <= 0 {
+		return models.Deal{}, fmt.Errorf("invalid deal ID")
+	}
+
+	var deal models.Deal
+	err := ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		dealRepo := ser.Repo.WithTx(tx)
+
+		var err error
+		deal, err = dealRepo.GetForUpdate(id)
+		if err != nil {
+			log.Printf("Deal not found: %v", err)
+			return fmt.Errorf("deal not found")
+		}
+
+		if err := check(tx, deal, to); err != nil {
+			return err
+		}
+
+		// A deal whose item the seller no longer has cannot complete, so the
+		// buyer is refunded instead of the money staying in escrow.
+		if to == models.DealStatusCompleted {
+			lost, err := ser.sellerLostItem(tx, deal)
+			if err != nil {
+				return err
+			}
+			if lost {
+				to = models.DealStatusCancelled
+			}
+		}
+
+		if err := ser.applyTransition(tx, deal, to); err != nil {
+			return err
+		}
+
+		if err := dealRepo.UpdateStatus(deal.Id, deal.Status, to); err != nil {
+			log.Printf("Error updating deal status: %v", err)
+			return fmt.Errorf("failed to update deal")
+		}
+
+		err = dealRepo.AddEvent(models.DealEvent{DealId: deal.Id, FromStatus: deal.Status, ToStatus: to, ActorId: actorId})
+		if err != nil {
+			log.Printf("Error recording deal event: %v", err)
+			return fmt.Errorf("failed to update deal")
+		}
+
+		deal.Status = to
+		return nil
+	})
+	if err != nil {
+		return models.Deal{}, err
+	}
+
+	return deal, nil
+}
+
+// checkTransition applies the state machine rules, and additionally lets
+// the buyer cancel a disputed deal once the seller no longer has the item.
+func (ser *DealServiceImpl) checkTransition(tx *sqlx.Tx, deal models.Deal, to string, actorId int) error {
+	err := checkDealTransition(deal, to, actorId)
+	if !errors.Is(err, ErrDealForbidden) || deal.Status != models.DealStatusDisputed ||
+		to != models.DealStatusCancelled || dealRoleOf(deal, actorId)&dealRoleBuyer == 0 {
+		return err
+	}
+
+	lost, lostErr := ser.sellerLostItem(tx, deal)
+	if lostErr != nil {
+		return lostErr
+	}
+	if !lost {
+		return err
+	}
+
+	return nil
+}
+
+// checkResolution only lets a dispute be settled one way or the other.
+func checkResolution(tx *sqlx.Tx, deal models.Deal, to string) error {
+	if deal.Status != models.DealStatusDisputed {
+		return ErrInvalidDealTransition
+	}
+
+	if to != models.DealStatusCompleted && to != models.DealStatusCancelled {
+		return ErrInvalidDealTransition
+	}
+
+	return nil
+}
+
+// checkSystemTransition only lets the system complete a deal that is still
+// paid and whose hold is due, as the deal may have been disputed since it
+// was picked for release.
+func (ser *DealServiceImpl) checkSystemTransition(tx *sqlx.Tx, deal models.Deal, to string) error {
+	if to != models.DealStatusCompleted || deal.Status != models.DealStatusPaid {
+		return ErrInvalidDealTransition
+	}
+
+	due, err := ser.Escrow.IsDue(tx, deal)
+	if err != nil {
+		return err
+	}
+	if !due {
+		return fmt.Errorf("escrow hold is not due for release")
+	}
+
+	return nil
+}
+
+// applyTransition moves money and the item for status changes that have
+// side effects.
+func (ser *DealServiceImpl) applyTransition(tx *sqlx.Tx, deal models.Deal, to string) error {
+	switch {
+	case to == models.DealStatusPaid:
+		if _, err := ser.lockSellerItem(tx, deal); err != nil {
+			return err
+		}
+
+		_, err := ser.Escrow.Hold(tx, deal)
+		return err
+
+	case to == models.DealStatusCompleted:
+		return ser.complete(tx, deal)
+
+	case to == models.DealStatusCancelled && deal.Status != models.DealStatusOffered && deal.Status != models.DealStatusAccepted:
+		_, err := ser.Escrow.Refund(tx, deal)
+		if errors.Is(err, ErrNoEscrowHold) {
+			return fmt.Errorf("deal was settled without escrow and cannot be refunded")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// complete transfers the item to the buyer, releases the held price to the
+// seller and cancels the item's other open deals, which can no longer
+// complete. The item is locked before any wallet, in the same order as
+// paying, so the two cannot deadlock.
+func (ser *DealServiceImpl) complete(tx *sqlx.Tx, deal models.Deal) error {
+	item, err := ser.lockSellerItem(tx, deal)
+	if err != nil {
+		return err
+	}
+
+	_, err = ser.Escrow.Release(tx, deal)
+	if errors.Is(err, ErrNoEscrowHold) {
+		// Deals paid before escrow existed were settled at payment.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := ser.ItemRepo.WithTx(tx).UpdateOwner(item.Id, deal.User.Id); err != nil {
+		log.Printf("Error transferring item: %v", err)
+		return fmt.Errorf("failed to transfer item")
+	}
+
+	return ser.cancelOtherDeals(tx, deal)
+}
+
+// cancelOtherDeals cancels the open deals on the item other than deal,
+// refunding the buyers who already paid.
+func (ser *DealServiceImpl) cancelOtherDeals(tx *sqlx.Tx, deal models.Deal) error {
+	dealRepo := ser.Repo.WithTx(tx)
+
+	others, err := dealRepo.GetOpenByItemForUpdate(deal.Item.Id, deal.Id)
+	if err != nil {
+		log.Printf("Error retrieving open deals: %v", err)
+		return fmt.Errorf("failed to cancel other deals")
+	}
+
+	for _, other := range others {
+		if other.Status == models.DealStatusPaid || other.Status == models.DealStatusDisputed {
+			if _, err := ser.Escrow.Refund(tx, other); err != nil && !errors.Is(err, ErrNoEscrowHold) {
+				return err
+			}
+		}
+
+		if err := dealRepo.UpdateStatus(other.Id, other.Status, models.DealStatusCancelled); err != nil {
+			log.Printf("Error cancelling deal: %v", err)
+			return fmt.Errorf("failed to cancel other deals")
+		}
+
+		err = dealRepo.AddEvent(models.DealEvent{DealId: other.Id, FromStatus: other.Status, ToStatus: models.DealStatusCancelled})
+		if err != nil {
+			log.Printf("Error recording deal event: %v", err)
+			return fmt.Errorf("failed to cancel other deals")
+		}
+	}
+
+	return nil
+}
+
+// sellerLostItem locks the deal's item and reports whether it was deleted or
+// now belongs to someone other than the seller.
+func (ser *DealServiceImpl) sellerLostItem(tx *sqlx.Tx, deal models.Deal) (bool, error) {
+	item, err := ser.ItemRepo.WithTx(tx).GetForUpdate(deal.Item.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		log.Printf("Error retrieving item: %v", err)
+		return false, fmt.Errorf("failed to get item")
+	}
+
+	return item.OwnerId != deal.Seller.Id, nil
+}
+
+// lockSellerItem locks the deal's item and checks the seller still owns it.
+func (ser *DealServiceImpl) lockSellerItem(tx *sqlx.Tx, deal models.Deal) (models.Item, error) {
+	item, err := ser.ItemRepo.WithTx(tx).GetForUpdate(deal.Item.Id)
+	if err != nil {
+		log.Printf("Item not found: %v", err)
+		return models.Item{}, fmt.Errorf("item not found")
+	}
+
+	if item.OwnerId != deal.Seller.Id {
+		return models.Item{}, fmt.Errorf("seller no longer owns this item")
+	}
+
+	return item, nil
+}
+
 func (ser *DealServiceImpl) Get(id int) (models.Deal, error) {
 	if id <= 0 {
 		return models.Deal{}, fmt.Errorf("invalid deal ID")
@@ -57,55 +403,159 @@ func (ser *DealServiceImpl) Get(id int) (models.Deal, error) {
 	return deal, nil
 }
 
-func (ser *DealServiceImpl) GetAll(page database.PageInfo) ([]models.Deal, error) {
-	if page.PageNumber <= 0 || page.PageSize < 0 {
-		return nil, fmt.Errorf("invalid pagination")
+func (ser *DealServiceImpl) GetAll(page database.PageInfo, params query.Params) (database.Page[models.Deal], error) {
+	if page.PageNumber <= 0 || page.PageSize <= 0 {
+		return database.Page[models.Deal]{}, fmt.Errorf("invalid pagination")
 	}
 
-	deals, err := ser.Repo.GetAll(page)
+	q, err := query.Parse(repositories.DealQueryFields, params)
+	if err != nil {
+		return database.Page[models.Deal]{}, err
+	}
+
+	deals, err := ser.Repo.GetAll(page, q)
 	if err != nil {
 		log.Printf("Error retrieving deals: %v", err)
-		return nil, fmt.Errorf("failed to get deals")
+		return database.Page[models.Deal]{}, fmt.Errorf("failed to get deals")
 	}
 
-	return deals, nil
+	total, err := ser.Repo.Count(q)
+	if err != nil {
+		log.Printf("Error counting deals: %v", err)
+		return database.Page[models.Deal]{}, fmt.Errorf("failed to get deals")
+	}
+
+	return database.NewPage(deals, total, page), nil
 }
 
-func (ser *DealServiceImpl) Update(deal models.Deal, claims *middlewares.Claims) (models.Deal, error) {
-	if deal.Id <= 0 {
-		return models.Deal{}, fmt.Errorf("deal does not exist")
+func (ser *DealServiceImpl) GetAllByCursor(cursor string, size int, params query.Params) (database.CursorPage[models.Deal], error) {
+	info, err := cursorInfo("deals", cursor, size, params)
+	if err != nil {
+		return database.CursorPage[models.Deal]{}, err
 	}
 
-	_, err := ser.Repo.Get(int(deal.Item.Id))
+	q, err := query.Parse(repositories.DealQueryFields, params)
 	if err != nil {
-		log.Printf("Item not found: %v", err)
-		return models.Deal{}, fmt.Errorf("item not found")
+		return database.CursorPage[models.Deal]{}, err
 	}
 
-	deal.User.Id = claims.UserId
-
-	err = ser.Repo.Update(deal)
+	deals, err := ser.Repo.GetAllByCursor(info, q)
 	if err != nil {
-		log.Printf("Failed to update deal: %v", err)
-		return models.Deal{}, fmt.Errorf("failed to update deal")
+		log.Printf("Error retrieving deals: %v", err)
+		return database.CursorPage[models.Deal]{}, fmt.Errorf("failed to get deals")
 	}
 
-	return deal, nil
+	return database.NewCursorPage("deals", deals, info, func(deal models.Deal) int64 {
+		return int64(deal.Id)
+	}), nil
 }
 
-func (ser *DealServiceImpl) Delete(id int, claims *middlewares.Claims) error {
+// GetEvents returns the deal's history to its buyer and seller, and to
+// users who may manage any deal or resolve disputes.
+func (ser *DealServiceImpl) GetEvents(id int, claims *middlewares.Claims) ([]models.DealEvent, error) {
 	if id <= 0 {
-		return fmt.Errorf("invalid deal ID")
+		return nil, fmt.Errorf("invalid deal ID")
 	}
 
 	deal, err := ser.Repo.Get(id)
 	if err != nil {
-		return fmt.Errorf("deal not found")
+		log.Printf("Deal not found: %v", err)
+		return nil, fmt.Errorf("deal not found")
+	}
+
+	if dealRoleOf(deal, claims.UserId) == 0 && !claims.HasPermission(models.PermDealsDeleteAny) &&
+		!claims.HasPermission(models.PermDealsResolve) {
+		return nil, ErrDealForbidden
+	}
+
+	events, err := ser.Repo.GetEvents(id)
+	if err != nil {
+		log.Printf("Error retrieving deal events: %v", err)
+		return nil, fmt.Errorf("failed to get deal history")
 	}
 
-	if deal.User.Id != claims.UserId {
-		return fmt.Errorf("you can only delete your own deals")
+	return events, nil
+}
+
+// Update lets the buyer change the price of a deal while it is still only
+// offered. The deal stays locked from the status check to the write, so an
+// accept or payment cannot slip in between.
+func (ser *DealServiceImpl) Update(deal models.Deal, claims *middlewares.Claims) (models.Deal, error) {
+	if deal.Id <= 0 {
+		return models.Deal{}, fmt.Errorf("deal does not exist")
 	}
 
-	return ser.Repo.Delete(id)
+	var updated models.Deal
+	err := ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		repo := ser.Repo.WithTx(tx)
+
+		existing, err := repo.GetForUpdate(deal.Id)
+		if err != nil {
+			log.Printf("Deal not found: %v", err)
+			return fmt.Errorf("deal not found")
+		}
+
+		if existing.User.Id != claims.UserId {
+			return ErrDealForbidden
+		}
+
+		if existing.Status != models.DealStatusOffered {
+			return fmt.Errorf("only offered deals can be changed")
+		}
+
+		cmp, err := deal.Price.Cmp(existing.Item.Price)
+		if err != nil {
+			return fmt.Errorf("deal currency does not match item currency")
+		}
+
+		if cmp < 0 {
+			return fmt.Errorf("price is below the item price")
+		}
+
+		existing.Price = deal.Price
+
+		if err := repo.Update(existing); err != nil {
+			log.Printf("Failed to update deal: %v", err)
+			return fmt.Errorf("failed to update deal")
+		}
+
+		updated = existing
+		return nil
+	})
+	if err != nil {
+		return models.Deal{}, err
+	}
+
+	return updated, nil
+}
+
+// Delete removes a deal nobody has agreed to yet. Its events are kept.
+func (ser *DealServiceImpl) Delete(id int, claims *middlewares.Claims) error {
+	if id <= 0 {
+		return fmt.Errorf("invalid deal ID")
+	}
+
+	return ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		repo := ser.Repo.WithTx(tx)
+
+		deal, err := repo.GetForUpdate(id)
+		if err != nil {
+			return fmt.Errorf("deal not found")
+		}
+
+		if deal.User.Id != claims.UserId && !claims.HasPermission(models.PermDealsDeleteAny) {
+			return fmt.Errorf("you can only delete your own deals")
+		}
+
+		if deal.Status != models.DealStatusOffered {
+			return fmt.Errorf("only offered deals can be deleted")
+		}
+
+		if err := repo.Delete(id); err != nil {
+			log.Printf("Error deleting deal: %v", err)
+			return fmt.Errorf("failed to delete deal")
+		}
+
+		return nil
+	})
 }
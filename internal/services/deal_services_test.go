@@ -0,0 +1,41 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"market/internal/database/models"
+	"market/web/handlers/middlewares"
+)
+
+func TestCheckResolution(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		want error
+	}{
+		{models.DealStatusDisputed, models.DealStatusCompleted, nil},
+		{models.DealStatusDisputed, models.DealStatusCancelled, nil},
+		{models.DealStatusDisputed, models.DealStatusPaid, ErrInvalidDealTransition},
+		{models.DealStatusPaid, models.DealStatusCompleted, ErrInvalidDealTransition},
+		{models.DealStatusCompleted, models.DealStatusCancelled, ErrInvalidDealTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			err := checkResolution(nil, models.Deal{Status: tt.from}, tt.to)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveNeedsPermission(t *testing.T) {
+	ser := &DealServiceImpl{}
+
+	_, err := ser.Resolve(1, models.DealStatusCancelled, &middlewares.Claims{UserId: 1})
+	if !errors.Is(err, ErrDealForbidden) {
+		t.Errorf("got %v, want ErrDealForbidden", err)
+	}
+}
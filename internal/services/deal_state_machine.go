@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+	"market/internal/database/models"
+)
+
+var (
+	ErrInvalidDealTransition = errors.New("deal cannot move to this status")
+	ErrDealForbidden         = errors.New("you are not allowed to change this deal")
+)
+
+type dealRole int
+
+const (
+	dealRoleBuyer dealRole = 1 << iota
+	dealRoleSeller
+)
+
+type dealTransition struct {
+	from  string
+	to    string
+	roles dealRole
+}
+
+// dealTransitions lists every allowed status change and which side of the
+// deal may perform it.
+var dealTransitions = []dealTransition{
+	{models.DealStatusOffered, models.DealStatusAccepted, dealRoleSeller},
+	{models.DealStatusOffered, models.DealStatusCancelled, dealRoleBuyer | dealRoleSeller},
+	{models.DealStatusAccepted, models.DealStatusPaid, dealRoleBuyer},
+	{models.DealStatusAccepted, models.DealStatusCancelled, dealRoleBuyer | dealRoleSeller},
+	{models.DealStatusPaid, models.DealStatusCompleted, dealRoleBuyer},
+	{models.DealStatusPaid, models.DealStatusCancelled, dealRoleSeller},
+	{models.DealStatusPaid, models.DealStatusDisputed, dealRoleBuyer | dealRoleSeller},
+	{models.DealStatusDisputed, models.DealStatusCompleted, dealRoleBuyer},
+	{models.DealStatusDisputed, models.DealStatusCancelled, dealRoleSeller},
+}
+
+func dealRoleOf(deal models.Deal, userId int) dealRole {
+	var role dealRole
+	if deal.User.Id == userId {
+		role |= dealRoleBuyer
+	}
+	if deal.Seller.Id == userId {
+		role |= dealRoleSeller
+	}
+	return role
+}
+
+func findDealTransition(from, to string) (dealTransition, bool) {
+	for _, transition := range dealTransitions {
+		if transition.from == from && transition.to == to {
+			return transition, true
+		}
+	}
+	return dealTransition{}, false
+}
+
+// checkDealTransition reports whether userId may move deal to the status to.
+func checkDealTransition(deal models.Deal, to string, userId int) error {
+	role := dealRoleOf(deal, userId)
+	if role == 0 {
+		return ErrDealForbidden
+	}
+
+	transition, ok := findDealTransition(deal.Status, to)
+	if !ok {
+		return ErrInvalidDealTransition
+	}
+
+	if transition.roles&role == 0 {
+		return ErrDealForbidden
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+	"market/web/handlers/middlewares"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const DefaultEscrowReleaseAfter = 7 * 24 * time.Hour
+
+var ErrNoEscrowHold = errors.New("deal has no escrow hold")
+
+type EscrowService interface {
+	Hold(tx *sqlx.Tx, deal models.Deal) (models.EscrowHold, error)
+	Release(tx *sqlx.Tx, deal models.Deal) (models.EscrowHold, error)
+	Refund(tx *sqlx.Tx, deal models.Deal) (models.EscrowHold, error)
+	GetByDeal(dealId int, claims *middlewares.Claims) (models.EscrowHold, error)
+	GetDueDealIds() ([]int, error)
+	IsDue(tx *sqlx.Tx, deal models.Deal) (bool, error)
+}
+
+// EscrowServiceImpl keeps a buyer's payment in the platform escrow wallet
+// until the deal completes or is cancelled. Every movement is a ledger
+// transaction referenced from the hold.
+type EscrowServiceImpl struct {
+	Repo       repositories.EscrowRepo
+	DealRepo   repositories.DealRepo
+	WalletRepo repositories.WalletRepo
+	// ReleaseAfter is how long money stays held before it is released to
+	// the seller without the buyer confirming delivery.
+	ReleaseAfter time.Duration
+}
+
+// Hold moves the deal price from the buyer's wallet into escrow. It runs in
+// the caller's transaction.
+func (ser *EscrowServiceImpl) Hold(tx *sqlx.Tx, deal models.Deal) (models.EscrowHold, error) {
+	walletRepo := ser.WalletRepo.WithTx(tx)
+
+	buyer, err := walletRepo.GetByUser(deal.User.Id, deal.Price.Currency)
+	if err != nil {
+		log.Printf("Error retrieving wallet: %v", err)
+		return models.EscrowHold{}, fmt.Errorf("failed to get wallet")
+	}
+
+	escrow, err := walletRepo.GetSystem(models.WalletKindEscrow, deal.Price.Currency)
+	if err != nil {
+		log.Printf("Error retrieving escrow wallet: %v", err)
+		return models.EscrowHold{}, fmt.Errorf("failed to get wallet")
+	}
+
+	transactionId, err := postBetween(walletRepo, buyer, escrow, deal.Price, models.LedgerKindHold, &deal.Id)
+	if err != nil {
+		return models.EscrowHold{}, err
+	}
+
+	hold, err := ser.Repo.WithTx(tx).Create(models.EscrowHold{
+		DealId:            deal.Id,
+		BuyerId:           deal.User.Id,
+		SellerId:          deal.Seller.Id,
+		Amount:            deal.Price,
+		ReleaseAt:         time.Now().Add(ser.releaseAfter()),
+		HoldTransactionId: transactionId,
+	})
+	if err != nil {
+		log.Printf("Error creating escrow hold: %v", err)
+		return models.EscrowHold{}, fmt.Errorf("failed to hold payment")
+	}
+
+	return hold, nil
+}
+
+// Release pays the held money out to the seller.
+func (ser *EscrowServiceImpl) Release(tx *sqlx.Tx, deal models.Deal) (models.EscrowHold, error) {
+	return ser.settle(tx, deal, models.EscrowStatusReleased, models.LedgerKindRelease)
+}
+
+// Refund returns the held money to the buyer.
+func (ser *EscrowServiceImpl) Refund(tx *sqlx.Tx, deal models.Deal) (models.EscrowHold, error) {
+	return ser.settle(tx, deal, models.EscrowStatusRefunded, models.LedgerKindRefund)
+}
+
+func (ser *EscrowServiceImpl) GetByDeal(dealId int, claims *middlewares.Claims) (models.EscrowHold, error) {
+	deal, err := ser.DealRepo.Get(dealId)
+	if err != nil {
+		log.Printf("Deal not found: %v", err)
+		return models.EscrowHold{}, fmt.Errorf("deal not found")
+	}
+
+	if dealRoleOf(deal, claims.UserId) == 0 {
+		return models.EscrowHold{}, ErrDealForbidden
+	}
+
+	hold, err := ser.Repo.GetByDeal(dealId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.EscrowHold{}, ErrNoEscrowHold
+	}
+	if err != nil {
+		log.Printf("Error retrieving escrow hold: %v", err)
+		return models.EscrowHold{}, fmt.Errorf("failed to get escrow hold")
+	}
+
+	return hold, nil
+}
+
+func (ser *EscrowServiceImpl) GetDueDealIds() ([]int, error) {
+	dealIds, err := ser.Repo.GetDueDealIds()
+	if err != nil {
+		log.Printf("Error retrieving due escrow holds: %v", err)
+		return nil, fmt.Errorf("failed to get due escrow holds")
+	}
+
+	return dealIds, nil
+}
+
+// IsDue locks the deal's hold and reports whether it is still held and past
+// its release time. It runs in the caller's transaction.
+func (ser *EscrowServiceImpl) IsDue(tx *sqlx.Tx, deal models.Deal) (bool, error) {
+	hold, err := ser.Repo.WithTx(tx).GetByDealForUpdate(deal.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("Error retrieving escrow hold: %v", err)
+		return false, fmt.Errorf("failed to get escrow hold")
+	}
+
+	return hold.Status == models.EscrowStatusHeld && !hold.ReleaseAt.After(time.Now()), nil
+}
+
+func (ser *EscrowServiceImpl) settle(tx *sqlx.Tx, deal models.Deal, status string, kind string) (models.EscrowHold, error) {
+	repo := ser.Repo.WithTx(tx)
+	walletRepo := ser.WalletRepo.WithTx(tx)
+
+	hold, err := repo.GetByDealForUpdate(deal.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.EscrowHold{}, ErrNoEscrowHold
+	}
+	if err != nil {
+		log.Printf("Error retrieving escrow hold: %v", err)
+		return models.EscrowHold{}, fmt.Errorf("failed to get escrow hold")
+	}
+
+	if hold.Status != models.EscrowStatusHeld {
+		return models.EscrowHold{}, fmt.Errorf("escrow hold is already %s", hold.Status)
+	}
+
+	recipientId := hold.SellerId
+	if status == models.EscrowStatusRefunded {
+		recipientId = hold.BuyerId
+	}
+
+	escrow, err := walletRepo.GetSystem(models.WalletKindEscrow, hold.Amount.Currency)
+	if err != nil {
+		log.Printf("Error retrieving escrow wallet: %v", err)
+		return models.EscrowHold{}, fmt.Errorf("failed to get wallet")
+	}
+
+	recipient, err := walletRepo.GetByUser(recipientId, hold.Amount.Currency)
+	if err != nil {
+		log.Printf("Error retrieving wallet: %v", err)
+		return models.EscrowHold{}, fmt.Errorf("failed to get wallet")
+	}
+
+	transactionId, err := postBetween(walletRepo, escrow, recipient, hold.Amount, kind, &deal.Id)
+	if err != nil {
+		return models.EscrowHold{}, err
+	}
+
+	if err := repo.Settle(hold.Id, status, transactionId); err != nil {
+		log.Printf("Error settling escrow hold: %v", err)
+		return models.EscrowHold{}, fmt.Errorf("failed to settle escrow hold")
+	}
+
+	hold.Status = status
+	hold.SettleTransactionId = &transactionId
+	return hold, nil
+}
+
+func (ser *EscrowServiceImpl) releaseAfter() time.Duration {
+	if ser.ReleaseAfter <= 0 {
+		return DefaultEscrowReleaseAfter
+	}
+	return ser.ReleaseAfter
+}
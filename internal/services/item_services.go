@@ -1,25 +1,46 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"market/internal/database"
 	"market/internal/database/models"
+	"market/internal/database/query"
 	"market/internal/database/repositories"
 	"market/web/handlers/middlewares"
 	"strings"
+	"unicode"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	maxSearchLength = 200
+	maxSearchTerms  = 10
+)
+
+var (
+	ErrEmptySearch   = errors.New("search query must contain letters or digits")
+	ErrItemNotFound  = errors.New("item not found")
+	ErrItemForbidden = errors.New("you can only delete your own items")
+	ErrItemInUse     = errors.New("item is in an active deal or an open auction")
 )
 
 type ItemService interface {
 	Create(newItem models.NewItem, userId int) (models.Item, error)
 	Get(id int) (models.Item, error)
-	GetAll(page database.PageInfo) ([]models.Item, error)
+	GetAll(page database.PageInfo, filter models.ItemFilter, params query.Params) (database.Page[models.Item], error)
+	GetAllByCursor(cursor string, size int, filter models.ItemFilter, params query.Params) (database.CursorPage[models.Item], error)
+	Search(query string, page database.PageInfo, filter models.ItemFilter) (database.Page[models.ItemSearchResult], error)
 	Update(item models.Item, claims *middlewares.Claims) (models.Item, error)
 	Delete(id int, claims *middlewares.Claims) error
 }
 
 type ItemServiceIml struct {
-	Repo repositories.ItemRepo
+	Repo       repositories.ItemRepo
+	Categories CategoryService
+	Tx         database.Transactor
 }
 
 func (ser *ItemServiceIml) Create(newItem models.NewItem, userId int) (models.Item, error) {
@@ -27,10 +48,20 @@ func (ser *ItemServiceIml) Create(newItem models.NewItem, userId int) (models.It
 		return models.Item{}, fmt.Errorf("invalid new item name")
 	}
 
-	if newItem.Price <= 0 {
+	if err := newItem.Price.Validate(); err != nil {
+		return models.Item{}, fmt.Errorf("unsupported currency")
+	}
+
+	if !newItem.Price.IsPositive() {
 		return models.Item{}, fmt.Errorf("item price cannot be less or equal 0")
 	}
 
+	attributes, err := ser.checkAttributes(newItem.CategoryId, newItem.Attributes)
+	if err != nil {
+		return models.Item{}, err
+	}
+	newItem.Attributes = attributes
+
 	newItem.OwnerId = userId
 
 	newItem.Name = fixName(newItem.Name)
@@ -58,22 +89,92 @@ func (ser *ItemServiceIml) Get(id int) (models.Item, error) {
 	return item, nil
 }
 
-func (ser *ItemServiceIml) GetAll(page database.PageInfo) ([]models.Item, error) {
-	if page.PageNumber <= 0 || page.PageSize < 0 {
-		return nil, fmt.Errorf("invalid pagination")
+func (ser *ItemServiceIml) GetAll(page database.PageInfo, filter models.ItemFilter, params query.Params) (database.Page[models.Item], error) {
+	if page.PageNumber <= 0 || page.PageSize <= 0 {
+		return database.Page[models.Item]{}, fmt.Errorf("invalid pagination")
+	}
+
+	q, err := query.Parse(repositories.ItemQueryFields, params)
+	if err != nil {
+		return database.Page[models.Item]{}, err
 	}
 
-	items, err := ser.Repo.GetAll(page)
+	items, err := ser.Repo.GetAll(page, filter, q)
 	if err != nil {
 		log.Printf("failed to get items: %v", err)
-		return nil, fmt.Errorf("failed to get items")
+		return database.Page[models.Item]{}, fmt.Errorf("failed to get items")
+	}
+
+	total, err := ser.Repo.Count(filter, q)
+	if err != nil {
+		log.Printf("failed to count items: %v", err)
+		return database.Page[models.Item]{}, fmt.Errorf("failed to get items")
+	}
+
+	return database.NewPage(items, total, page), nil
+}
+
+func (ser *ItemServiceIml) GetAllByCursor(cursor string, size int, filter models.ItemFilter, params query.Params) (database.CursorPage[models.Item], error) {
+	info, err := cursorInfo("items", cursor, size, params)
+	if err != nil {
+		return database.CursorPage[models.Item]{}, err
+	}
+
+	q, err := query.Parse(repositories.ItemQueryFields, params)
+	if err != nil {
+		return database.CursorPage[models.Item]{}, err
+	}
+
+	items, err := ser.Repo.GetAllByCursor(info, filter, q)
+	if err != nil {
+		log.Printf("failed to get items: %v", err)
+		return database.CursorPage[models.Item]{}, fmt.Errorf("failed to get items")
+	}
+
+	return database.NewCursorPage("items", items, info, func(item models.Item) int64 {
+		return int64(item.Id)
+	}), nil
+}
+
+// Search ranks items by full-text match on name and text attributes, each
+// word also matching as prefix, plus name similarity for misspellings.
+func (ser *ItemServiceIml) Search(query string, page database.PageInfo, filter models.ItemFilter) (database.Page[models.ItemSearchResult], error) {
+	if page.PageNumber <= 0 || page.PageSize <= 0 {
+		return database.Page[models.ItemSearchResult]{}, fmt.Errorf("invalid pagination")
+	}
+
+	query = strings.TrimSpace(query)
+	if len(query) > maxSearchLength {
+		query = strings.ToValidUTF8(query[:maxSearchLength], "")
+	}
+
+	terms := searchTerms(query)
+	if terms == "" {
+		return database.Page[models.ItemSearchResult]{}, ErrEmptySearch
+	}
+
+	results, err := ser.Repo.Search(query, terms, page, filter)
+	if err != nil {
+		log.Printf("failed to search items: %v", err)
+		return database.Page[models.ItemSearchResult]{}, fmt.Errorf("failed to search items")
+	}
+
+	total, err := ser.Repo.CountSearch(query, terms, filter)
+	if err != nil {
+		log.Printf("failed to count search results: %v", err)
+		return database.Page[models.ItemSearchResult]{}, fmt.Errorf("failed to search items")
 	}
 
-	return items, nil
+	return database.NewPage(results, total, page), nil
 }
 
 func (ser *ItemServiceIml) Update(item models.Item, claims *middlewares.Claims) (models.Item, error) {
-	if item.OwnerId != claims.UserId {
+	existing, err := ser.Repo.Get(item.Id)
+	if err != nil {
+		return models.Item{}, fmt.Errorf("item not found")
+	}
+
+	if existing.OwnerId != claims.UserId && !claims.HasPermission(models.PermItemsUpdateAny) {
 		return models.Item{}, fmt.Errorf("user does not own this item")
 	}
 
@@ -81,13 +182,23 @@ func (ser *ItemServiceIml) Update(item models.Item, claims *middlewares.Claims)
 		return models.Item{}, fmt.Errorf("item name cannot be empty")
 	}
 
-	if item.Price <= 0 {
+	if item.Price.Currency != existing.Price.Currency {
+		return models.Item{}, fmt.Errorf("item currency cannot be changed")
+	}
+
+	if !item.Price.IsPositive() {
 		return models.Item{}, fmt.Errorf("price cannot be 0")
 	}
 
+	item.Attributes, err = ser.checkAttributes(item.CategoryId, item.Attributes)
+	if err != nil {
+		return models.Item{}, err
+	}
+
+	item.OwnerId = existing.OwnerId
 	item.Name = fixName(item.Name)
 
-	err := ser.Repo.Update(item)
+	err = ser.Repo.Update(item)
 	if err != nil {
 		log.Printf("failed to update item: %v", err)
 		return models.Item{}, fmt.Errorf("failed to update item")
@@ -96,22 +207,88 @@ func (ser *ItemServiceIml) Update(item models.Item, claims *middlewares.Claims)
 	return item, nil
 }
 
+// Delete removes the item unless a deal the seller agreed to or an open
+// auction still depends on it. The item stays locked while this is checked,
+// so neither can start in between.
 func (ser *ItemServiceIml) Delete(id int, claims *middlewares.Claims) error {
 	if id <= 0 {
 		return fmt.Errorf("invalid item ID")
 	}
 
-	item, err := ser.Repo.Get(id)
+	return ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		repo := ser.Repo.WithTx(tx)
+
+		item, err := repo.GetForUpdate(id)
+		if err != nil {
+			return ErrItemNotFound
+		}
+
+		if item.OwnerId != claims.UserId && !claims.HasPermission(models.PermItemsDeleteAny) {
+			return ErrItemForbidden
+		}
+
+		inDeal, err := repo.HasActiveDeal(id)
+		if err != nil {
+			log.Printf("Error checking item deals: %v", err)
+			return fmt.Errorf("failed to delete item")
+		}
+
+		inAuction, err := repo.HasOpenAuction(id)
+		if err != nil {
+			log.Printf("Error checking item auctions: %v", err)
+			return fmt.Errorf("failed to delete item")
+		}
+
+		if inDeal || inAuction {
+			return ErrItemInUse
+		}
+
+		if err := repo.Delete(id); err != nil {
+			log.Printf("Error deleting item: %v", err)
+			return fmt.Errorf("failed to delete item")
+		}
+
+		return nil
+	})
+}
+
+// checkAttributes validates attribute values against the category's
+// definitions. Items without a category have no attributes.
+func (ser *ItemServiceIml) checkAttributes(categoryId *int, attributes models.Attributes) (models.Attributes, error) {
+	if categoryId == nil {
+		if len(attributes) > 0 {
+			return nil, fmt.Errorf("%w: items without a category have no attributes", ErrInvalidAttributes)
+		}
+		return models.Attributes{}, nil
+	}
+
+	definitions, err := ser.Categories.Attributes(*categoryId)
+	if errors.Is(err, ErrCategoryNotFound) {
+		return nil, fmt.Errorf("%w: category not found", ErrInvalidAttributes)
+	}
 	if err != nil {
-		return fmt.Errorf("item not found")
+		return nil, err
+	}
+
+	return validateAttributes(definitions, attributes)
+}
+
+// searchTerms turns free text into a tsquery of prefix terms, such as
+// "red bik" into "red:* & bik:*". Only letters and digits are kept, so user
+// input cannot break the tsquery syntax.
+func searchTerms(query string) string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
 	}
 
-	if item.OwnerId != claims.UserId {
-		return fmt.Errorf("you can only delete your own items")
+	for i, word := range words {
+		words[i] = word + ":*"
 	}
 
-	ser.Repo.Delete(id)
-	return nil
+	return strings.Join(words, " & ")
 }
 
 func fixName(itemName string) string {
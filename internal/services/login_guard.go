@@ -0,0 +1,397 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"market/internal/database"
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrLoginBlocked = errors.New("too many failed login attempts")
+
+// LoginBlockedError is returned while a username or IP address has to wait
+// before trying again. It matches ErrLoginBlocked with errors.Is.
+type LoginBlockedError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (err *LoginBlockedError) Error() string {
+	if err.Locked {
+		return fmt.Sprintf("account temporarily locked, try again in %s", err.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many failed login attempts, try again in %s", err.RetryAfter.Round(time.Second))
+}
+
+func (err *LoginBlockedError) Is(target error) bool {
+	return target == ErrLoginBlocked
+}
+
+// LoginAttemptStore keeps failed login counters per key.
+type LoginAttemptStore interface {
+	Get(key string) (models.LoginAttempts, error)
+	RecordFailure(key string, at time.Time, window time.Duration) (models.LoginAttempts, error)
+	// Reserve atomically takes a slot for a login in progress unless the key
+	// is locked, still waiting out the delay after its last failure, or its
+	// recent failures and logins in progress reach maxFailures, and reports
+	// whether it did. Delays double from baseDelay up to maxDelay. Failures
+	// are left alone.
+	Reserve(key string, at time.Time, maxFailures int, window, baseDelay, maxDelay time.Duration) (models.LoginAttempts, bool, error)
+	// Release gives back the slot of a finished login.
+	Release(key string) error
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+// LoginPolicy configures LoginGuardImpl. Each failure doubles the wait
+// before the next attempt, starting at BaseDelay and capped at MaxDelay;
+// reaching the failure limit locks the key for LockoutDuration.
+type LoginPolicy struct {
+	MaxUserFailures int
+	MaxIPFailures   int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutDuration time.Duration
+	// Window is how long a failure is remembered.
+	Window time.Duration
+}
+
+var DefaultLoginPolicy = LoginPolicy{
+	MaxUserFailures: 5,
+	MaxIPFailures:   50,
+	BaseDelay:       time.Second,
+	MaxDelay:        30 * time.Second,
+	LockoutDuration: 15 * time.Minute,
+	Window:          15 * time.Minute,
+}
+
+type LoginGuard interface {
+	Attempt(username string, ip string) error
+	Failure(username string, ip string) error
+	Success(username string, ip string) error
+	Release(username string, ip string) error
+	Unlock(username string, unlockedBy *int, reason string) error
+	UnlockUser(userId int, actorId int) error
+	GetLockouts(page database.PageInfo) (database.Page[models.LoginLockout], error)
+}
+
+// LoginGuardImpl throttles password guessing per username and per IP
+// address, and keeps an audit record of every lockout.
+type LoginGuardImpl struct {
+	Store    LoginAttemptStore
+	Lockouts repositories.LoginLockoutRepo
+	UserRepo repositories.UserRepo
+	Policy   LoginPolicy
+}
+
+// Attempt reserves a slot for the username and IP address before the
+// password is checked; Failure or Success gives it back. Reserving is
+// atomic and logins in progress count against the failure limit, so
+// parallel guesses cannot exceed it. It returns a *LoginBlockedError when
+// the username or IP address must not try to log in yet.
+func (ser *LoginGuardImpl) Attempt(username string, ip string) error {
+	now := time.Now()
+	userKey := userAttemptKey(username)
+
+	if err := ser.reserve(userKey, now, ser.Policy.MaxUserFailures); err != nil {
+		return err
+	}
+
+	if err := ser.reserve(ipAttemptKey(ip), now, ser.Policy.MaxIPFailures); err != nil {
+		if releaseErr := ser.Store.Release(userKey); releaseErr != nil {
+			log.Printf("Error releasing login attempt: %v", releaseErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Failure records a wrong password for the username and IP address, gives
+// back their slots and locks either once its failures reach the limit.
+func (ser *LoginGuardImpl) Failure(username string, ip string) error {
+	if err := ser.fail(userAttemptKey(username), ip, ser.Policy.MaxUserFailures); err != nil {
+		return err
+	}
+
+	return ser.fail(ipAttemptKey(ip), ip, ser.Policy.MaxIPFailures)
+}
+
+// Success clears the username's failures and gives back the IP address's
+// slot. Earlier IP failures are kept, so logging into an own account does
+// not reset guessing at others.
+func (ser *LoginGuardImpl) Success(username string, ip string) error {
+	if err := ser.Store.Reset(userAttemptKey(username)); err != nil {
+		log.Printf("Error resetting login attempts: %v", err)
+		return fmt.Errorf("failed to log in")
+	}
+
+	if err := ser.Store.Release(ipAttemptKey(ip)); err != nil {
+		log.Printf("Error releasing login attempt: %v", err)
+		return fmt.Errorf("failed to log in")
+	}
+
+	return nil
+}
+
+// Release gives back the slots of a login that neither failed nor
+// succeeded yet, such as a right password still waiting for its two-factor
+// code. Failures are kept, so password and code guesses add up.
+func (ser *LoginGuardImpl) Release(username string, ip string) error {
+	for _, key := range []string{userAttemptKey(username), ipAttemptKey(ip)} {
+		if err := ser.Store.Release(key); err != nil {
+			log.Printf("Error releasing login attempt: %v", err)
+			return fmt.Errorf("failed to log in")
+		}
+	}
+
+	return nil
+}
+
+func (ser *LoginGuardImpl) Unlock(username string, unlockedBy *int, reason string) error {
+	key := userAttemptKey(username)
+
+	if err := ser.Store.Reset(key); err != nil {
+		log.Printf("Error resetting login attempts: %v", err)
+		return fmt.Errorf("failed to unlock user")
+	}
+
+	if err := ser.Lockouts.MarkUnlocked(key, unlockedBy, reason); err != nil {
+		log.Printf("Error auditing unlock: %v", err)
+	}
+
+	return nil
+}
+
+func (ser *LoginGuardImpl) UnlockUser(userId int, actorId int) error {
+	user, err := ser.UserRepo.Get(userId)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	return ser.Unlock(user.Username, &actorId, models.UnlockReasonAdmin)
+}
+
+func (ser *LoginGuardImpl) GetLockouts(page database.PageInfo) (database.Page[models.LoginLockout], error) {
+	if page.PageNumber <= 0 || page.PageSize <= 0 {
+		return database.Page[models.LoginLockout]{}, fmt.Errorf("invalid pagination")
+	}
+
+	lockouts, err := ser.Lockouts.GetAll(page)
+	if err != nil {
+		log.Printf("Error retrieving lockouts: %v", err)
+		return database.Page[models.LoginLockout]{}, fmt.Errorf("failed to get lockouts")
+	}
+
+	total, err := ser.Lockouts.Count()
+	if err != nil {
+		log.Printf("Error counting lockouts: %v", err)
+		return database.Page[models.LoginLockout]{}, fmt.Errorf("failed to get lockouts")
+	}
+
+	return database.NewPage(lockouts, total, page), nil
+}
+
+func (ser *LoginGuardImpl) reserve(key string, now time.Time, maxFailures int) error {
+	attempts, reserved, err := ser.Store.Reserve(key, now, maxFailures, ser.Policy.Window, ser.Policy.BaseDelay, ser.Policy.MaxDelay)
+	if err != nil {
+		log.Printf("Error reserving login attempt: %v", err)
+		return fmt.Errorf("failed to log in")
+	}
+	if reserved {
+		return nil
+	}
+
+	if attempts.LockedUntil != nil && attempts.LockedUntil.After(now) {
+		return &LoginBlockedError{RetryAfter: attempts.LockedUntil.Sub(now), Locked: true}
+	}
+
+	// Refused for logins in progress, or the key changed after it was
+	// refused, so it is retried shortly.
+	retryAfter := attempts.LastFailureAt.Add(ser.backoff(attempts.Failures)).Sub(now)
+	return &LoginBlockedError{RetryAfter: max(retryAfter, ser.Policy.BaseDelay)}
+}
+
+func (ser *LoginGuardImpl) fail(key string, ip string, maxFailures int) error {
+	now := time.Now()
+
+	attempts, err := ser.Store.RecordFailure(key, now, ser.Policy.Window)
+	if err != nil {
+		log.Printf("Error recording login failure: %v", err)
+		return fmt.Errorf("failed to log in")
+	}
+
+	if err := ser.Store.Release(key); err != nil {
+		log.Printf("Error releasing login attempt: %v", err)
+		return fmt.Errorf("failed to log in")
+	}
+
+	if attempts.Failures < maxFailures || (attempts.LockedUntil != nil && attempts.LockedUntil.After(now)) {
+		return nil
+	}
+
+	until := now.Add(ser.Policy.LockoutDuration)
+	if err := ser.Store.Lock(key, until); err != nil {
+		log.Printf("Error locking login: %v", err)
+		return fmt.Errorf("failed to log in")
+	}
+
+	log.Printf("Login locked for %s after %d failures until %s", key, attempts.Failures, until.Format(time.RFC3339))
+	err = ser.Lockouts.Create(models.LoginLockout{
+		Key:         key,
+		Failures:    attempts.Failures,
+		IpAddress:   ip,
+		LockedUntil: until,
+	})
+	if err != nil {
+		log.Printf("Error auditing lockout: %v", err)
+	}
+
+	return nil
+}
+
+func (ser *LoginGuardImpl) backoff(failures int) time.Duration {
+	return loginBackoff(failures, ser.Policy.BaseDelay, ser.Policy.MaxDelay)
+}
+
+// loginBackoff is how long to wait after the given number of failures.
+func loginBackoff(failures int, baseDelay, maxDelay time.Duration) time.Duration {
+	delay := baseDelay
+	for i := 1; i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
+
+func userAttemptKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// MemoryLoginAttemptStore keeps login counters in process memory. It only
+// works for a single instance and forgets everything on restart.
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempts
+	// windows keeps the window each key was counted with, as logins and
+	// MFA challenges remember failures for different times.
+	windows map[string]time.Duration
+}
+
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{attempts: map[string]models.LoginAttempts{}, windows: map[string]time.Duration{}}
+}
+
+func (store *MemoryLoginAttemptStore) Get(key string) (models.LoginAttempts, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	attempts, ok := store.attempts[key]
+	if !ok {
+		return models.LoginAttempts{Key: key}, nil
+	}
+
+	return attempts, nil
+}
+
+func (store *MemoryLoginAttemptStore) RecordFailure(key string, at time.Time, window time.Duration) (models.LoginAttempts, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.recordFailure(key, at, window), nil
+}
+
+// recordFailure must be called with the mutex held.
+func (store *MemoryLoginAttemptStore) recordFailure(key string, at time.Time, window time.Duration) models.LoginAttempts {
+	for other, attempts := range store.attempts {
+		expired := attempts.LockedUntil == nil || attempts.LockedUntil.Before(at)
+		if expired && attempts.ActivePending(at) == 0 && attempts.LastFailureAt.Before(at.Add(-store.windows[other])) {
+			delete(store.attempts, other)
+			delete(store.windows, other)
+		}
+	}
+
+	attempts := store.attempts[key]
+	if attempts.LastFailureAt.Before(at.Add(-window)) {
+		attempts.Failures = 0
+	}
+	attempts.Key = key
+	attempts.Failures++
+	attempts.LastFailureAt = at
+	store.attempts[key] = attempts
+	store.windows[key] = window
+
+	return attempts
+}
+
+func (store *MemoryLoginAttemptStore) Reserve(key string, at time.Time, maxFailures int, window, baseDelay, maxDelay time.Duration) (models.LoginAttempts, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	attempts := store.attempts[key]
+	attempts.Key = key
+
+	if attempts.LockedUntil != nil && attempts.LockedUntil.After(at) {
+		return attempts, false, nil
+	}
+
+	failures := attempts.ActiveFailures(at, window)
+	if failures > 0 && attempts.LastFailureAt.Add(loginBackoff(failures, baseDelay, maxDelay)).After(at) {
+		return attempts, false, nil
+	}
+
+	pending := attempts.ActivePending(at)
+	if failures+pending >= maxFailures {
+		return attempts, false, nil
+	}
+
+	attempts.Pending = pending + 1
+	attempts.ReservedAt = &at
+	store.attempts[key] = attempts
+	if _, ok := store.windows[key]; !ok {
+		store.windows[key] = window
+	}
+
+	return attempts, true, nil
+}
+
+func (store *MemoryLoginAttemptStore) Release(key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if attempts, ok := store.attempts[key]; ok && attempts.Pending > 0 {
+		attempts.Pending--
+		store.attempts[key] = attempts
+	}
+
+	return nil
+}
+
+func (store *MemoryLoginAttemptStore) Lock(key string, until time.Time) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	attempts := store.attempts[key]
+	attempts.Key = key
+	attempts.LockedUntil = &until
+	store.attempts[key] = attempts
+
+	return nil
+}
+
+func (store *MemoryLoginAttemptStore) Reset(key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.attempts, key)
+	delete(store.windows, key)
+	return nil
+}
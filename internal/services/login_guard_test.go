@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+)
+
+type lockoutsStub struct {
+	repositories.LoginLockoutRepo
+}
+
+func (lockoutsStub) Create(lockout models.LoginLockout) error {
+	return nil
+}
+
+func newTestGuard() (*LoginGuardImpl, *MemoryLoginAttemptStore) {
+	store := NewMemoryLoginAttemptStore()
+	return &LoginGuardImpl{Store: store, Lockouts: lockoutsStub{}, Policy: DefaultLoginPolicy}, store
+}
+
+func TestLoginGuardParallelGuessesStayWithinLimit(t *testing.T) {
+	guard, _ := newTestGuard()
+
+	var passed atomic.Int32
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if guard.Attempt("alice", "10.0.0.1") == nil {
+				passed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := int(passed.Load()); got != DefaultLoginPolicy.MaxUserFailures {
+		t.Errorf("%d parallel attempts passed, want %d", got, DefaultLoginPolicy.MaxUserFailures)
+	}
+}
+
+func TestLoginGuardSuccessLeavesNoFailures(t *testing.T) {
+	guard, store := newTestGuard()
+
+	// An office behind one address: many users logging in at once.
+	users := []string{"alice", "bob", "carol", "dave", "erin", "frank", "grace"}
+	var wg sync.WaitGroup
+	for _, user := range users {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := guard.Attempt(user, "10.0.0.1"); err != nil {
+				t.Errorf("attempt for %s: %v", user, err)
+				return
+			}
+			if err := guard.Success(user, "10.0.0.1"); err != nil {
+				t.Errorf("success for %s: %v", user, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	attempts, _ := store.Get(ipAttemptKey("10.0.0.1"))
+	if attempts.Failures != 0 || attempts.Pending != 0 {
+		t.Errorf("IP key has %d failures and %d pending, want none", attempts.Failures, attempts.Pending)
+	}
+
+	if err := guard.Attempt("alice", "10.0.0.1"); err != nil {
+		t.Errorf("attempt after successful logins: %v", err)
+	}
+}
+
+func TestLoginGuardFailureBacksOff(t *testing.T) {
+	guard, store := newTestGuard()
+
+	if err := guard.Attempt("alice", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := guard.Failure("alice", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	attempts, _ := store.Get(userAttemptKey("alice"))
+	if attempts.Failures != 1 || attempts.Pending != 0 {
+		t.Errorf("user key has %d failures and %d pending, want 1 and 0", attempts.Failures, attempts.Pending)
+	}
+
+	err := guard.Attempt("alice", "10.0.0.2")
+	var blocked *LoginBlockedError
+	if !errors.As(err, &blocked) || blocked.Locked {
+		t.Fatalf("attempt right after a failure: got %v, want a backoff", err)
+	}
+	if blocked.RetryAfter <= 0 || blocked.RetryAfter > time.Second {
+		t.Errorf("retry after %s, want up to the base delay", blocked.RetryAfter)
+	}
+}
+
+func TestLoginGuardLocksAtLimit(t *testing.T) {
+	guard, store := newTestGuard()
+	key := userAttemptKey("alice")
+
+	for i := range DefaultLoginPolicy.MaxUserFailures {
+		// Skip the backoff between guesses.
+		for _, key := range []string{key, ipAttemptKey("10.0.0.1")} {
+			attempts, _ := store.Get(key)
+			attempts.LastFailureAt = attempts.LastFailureAt.Add(-time.Minute)
+			store.attempts[key] = attempts
+		}
+
+		if err := guard.Attempt("alice", "10.0.0.1"); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+		if err := guard.Failure("alice", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := guard.Attempt("alice", "10.0.0.1")
+	var blocked *LoginBlockedError
+	if !errors.As(err, &blocked) || !blocked.Locked {
+		t.Errorf("attempt after %d failures: got %v, want a lockout", DefaultLoginPolicy.MaxUserFailures, err)
+	}
+}
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"market/internal/database"
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+	"market/web/handlers/middlewares"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	DefaultTOTPIssuer = "Market API"
+	recoveryCodeCount = 10
+	// maxMFAAttempts is how many codes may be tried with one login
+	// challenge before it is revoked.
+	maxMFAAttempts = 5
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrMFANotEnrolled    = errors.New("start two-factor enrollment first")
+	ErrInvalidMFACode    = errors.New("invalid two-factor code")
+	ErrInvalidMFAToken   = errors.New("invalid or expired MFA token")
+)
+
+type MFAService interface {
+	Enroll(claims *middlewares.Claims) (models.MFAEnrollment, error)
+	Activate(code models.MFACode, claims *middlewares.Claims) (models.RecoveryCodes, error)
+	Disable(code models.MFACode, claims *middlewares.Claims) error
+	RegenerateRecoveryCodes(code models.MFACode, claims *middlewares.Claims) (models.RecoveryCodes, error)
+	Challenge(user models.UserResponse) (models.MFAChallenge, bool, error)
+	CompleteLogin(login models.MFALogin, ip string) (models.UserResponse, error)
+}
+
+// MFAServiceImpl manages TOTP two-factor authentication. Codes are accepted
+// from an authenticator app or, once each, from the recovery codes handed
+// out on activation.
+type MFAServiceImpl struct {
+	Repo     repositories.MFARepo
+	UserRepo repositories.UserRepo
+	// Revocations makes each login challenge single-use.
+	Revocations middlewares.RevocationStore
+	// Attempts counts the codes tried per login challenge.
+	Attempts LoginAttemptStore
+	// Guard counts wrong codes together with wrong passwords per user, so
+	// fresh challenges do not give fresh guesses.
+	Guard LoginGuard
+	Tx    database.Transactor
+	// Issuer is the account name shown in authenticator apps.
+	Issuer string
+}
+
+func (ser *MFAServiceImpl) Enroll(claims *middlewares.Claims) (models.MFAEnrollment, error) {
+	existing, err := ser.Repo.Get(claims.UserId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error retrieving MFA settings: %v", err)
+		return models.MFAEnrollment{}, fmt.Errorf("failed to start enrollment")
+	}
+	if err == nil && existing.EnabledAt != nil {
+		return models.MFAEnrollment{}, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		log.Printf("Error generating TOTP secret: %v", err)
+		return models.MFAEnrollment{}, fmt.Errorf("failed to start enrollment")
+	}
+
+	if err := ser.Repo.SetSecret(claims.UserId, secret); err != nil {
+		log.Printf("Error saving TOTP secret: %v", err)
+		return models.MFAEnrollment{}, fmt.Errorf("failed to start enrollment")
+	}
+
+	return models.MFAEnrollment{
+		Secret: secret,
+		URI:    totpURI(ser.issuer(), claims.Username, secret),
+	}, nil
+}
+
+// Activate enables two-factor authentication once the user proves the app
+// was set up with a valid code, and returns fresh recovery codes.
+func (ser *MFAServiceImpl) Activate(code models.MFACode, claims *middlewares.Claims) (models.RecoveryCodes, error) {
+	var codes models.RecoveryCodes
+
+	err := ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		repo := ser.Repo.WithTx(tx)
+
+		mfa, err := repo.GetForUpdate(claims.UserId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrMFANotEnrolled
+		}
+		if err != nil {
+			log.Printf("Error retrieving MFA settings: %v", err)
+			return fmt.Errorf("failed to activate two-factor authentication")
+		}
+
+		if mfa.EnabledAt != nil {
+			return ErrMFAAlreadyEnabled
+		}
+
+		step, ok := verifyTOTP(mfa.Secret, strings.TrimSpace(code.Code), time.Now(), 0)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+
+		if err := repo.Enable(claims.UserId, step); err != nil {
+			log.Printf("Error enabling MFA: %v", err)
+			return fmt.Errorf("failed to activate two-factor authentication")
+		}
+
+		codes, err = ser.replaceRecoveryCodes(repo, claims.UserId)
+		return err
+	})
+
+	return codes, err
+}
+
+func (ser *MFAServiceImpl) Disable(code models.MFACode, claims *middlewares.Claims) error {
+	return ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		repo := ser.Repo.WithTx(tx)
+
+		if err := ser.verifyCode(repo, claims.UserId, code.Code); err != nil {
+			return err
+		}
+
+		if err := repo.Delete(claims.UserId); err != nil {
+			log.Printf("Error disabling MFA: %v", err)
+			return fmt.Errorf("failed to disable two-factor authentication")
+		}
+
+		return nil
+	})
+}
+
+func (ser *MFAServiceImpl) RegenerateRecoveryCodes(code models.MFACode, claims *middlewares.Claims) (models.RecoveryCodes, error) {
+	var codes models.RecoveryCodes
+
+	err := ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		repo := ser.Repo.WithTx(tx)
+
+		if err := ser.verifyCode(repo, claims.UserId, code.Code); err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = ser.replaceRecoveryCodes(repo, claims.UserId)
+		return err
+	})
+
+	return codes, err
+}
+
+// Challenge returns a login challenge when the user has two-factor
+// authentication enabled, and false when tokens can be issued right away.
+func (ser *MFAServiceImpl) Challenge(user models.UserResponse) (models.MFAChallenge, bool, error) {
+	mfa, err := ser.Repo.Get(user.Id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && mfa.EnabledAt == nil) {
+		return models.MFAChallenge{}, false, nil
+	}
+	if err != nil {
+		log.Printf("Error retrieving MFA settings: %v", err)
+		return models.MFAChallenge{}, false, fmt.Errorf("failed to log in")
+	}
+
+	token, err := middlewares.GenerateMFAChallenge(middlewares.Claims{
+		UserId:   user.Id,
+		Username: user.Username,
+	})
+	if err != nil {
+		log.Printf("Error signing MFA challenge: %v", err)
+		return models.MFAChallenge{}, false, fmt.Errorf("failed to log in")
+	}
+
+	return models.MFAChallenge{MFARequired: true, MFAToken: token}, true, nil
+}
+
+// CompleteLogin exchanges a login challenge and a valid code for the user
+// the tokens are to be issued to. The challenge cannot be used again, and
+// is revoked after maxMFAAttempts codes. Attempts are counted before the
+// code is checked, so parallel guesses cannot exceed the limit. Wrong codes
+// also count as failed logins of the user and the IP address, with the
+// same backoff and lockout as wrong passwords.
+func (ser *MFAServiceImpl) CompleteLogin(login models.MFALogin, ip string) (models.UserResponse, error) {
+	claims, err := middlewares.GetValidatedClaims(login.MFAToken)
+	if err != nil || !claims.MFAPending {
+		return models.UserResponse{}, ErrInvalidMFAToken
+	}
+
+	revoked, err := middlewares.IsRevoked(claims)
+	if err != nil {
+		log.Printf("Error checking MFA challenge: %v", err)
+		return models.UserResponse{}, fmt.Errorf("failed to log in")
+	}
+	if revoked {
+		return models.UserResponse{}, ErrInvalidMFAToken
+	}
+
+	if err := ser.Guard.Attempt(claims.Username, ip); err != nil {
+		return models.UserResponse{}, err
+	}
+
+	user, wrongCode, err := ser.completeLogin(claims, login.Code)
+
+	var guardErr error
+	switch {
+	case wrongCode:
+		guardErr = ser.Guard.Failure(claims.Username, ip)
+	case err != nil:
+		guardErr = ser.Guard.Release(claims.Username, ip)
+	default:
+		guardErr = ser.Guard.Success(claims.Username, ip)
+	}
+	if err != nil {
+		return models.UserResponse{}, err
+	}
+	if guardErr != nil {
+		return models.UserResponse{}, guardErr
+	}
+
+	return user, nil
+}
+
+// completeLogin checks the code against the challenge's per-challenge
+// limit and reports whether a code was tried and found wrong.
+func (ser *MFAServiceImpl) completeLogin(claims *middlewares.Claims, code string) (models.UserResponse, bool, error) {
+	attemptKey := mfaAttemptKey(claims.ID)
+	attempts, err := ser.Attempts.RecordFailure(attemptKey, time.Now(), middlewares.MFAChallengeTTL)
+	if err != nil {
+		log.Printf("Error counting MFA attempts: %v", err)
+		return models.UserResponse{}, false, fmt.Errorf("failed to log in")
+	}
+	if attempts.Failures > maxMFAAttempts {
+		if err := ser.endChallenge(claims); err != nil {
+			return models.UserResponse{}, false, err
+		}
+		return models.UserResponse{}, false, ErrInvalidMFAToken
+	}
+
+	err = ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		return ser.verifyCode(ser.Repo.WithTx(tx), claims.UserId, code)
+	})
+	if errors.Is(err, ErrInvalidMFACode) {
+		if attempts.Failures == maxMFAAttempts {
+			if err := ser.endChallenge(claims); err != nil {
+				return models.UserResponse{}, true, err
+			}
+			return models.UserResponse{}, true, ErrInvalidMFAToken
+		}
+		return models.UserResponse{}, true, err
+	}
+	if err != nil {
+		return models.UserResponse{}, false, err
+	}
+
+	if err := ser.endChallenge(claims); err != nil {
+		return models.UserResponse{}, false, err
+	}
+
+	user, err := ser.UserRepo.Get(claims.UserId)
+	if err != nil || user.BannedAt != nil {
+		return models.UserResponse{}, false, ErrInvalidMFAToken
+	}
+
+	return user.ToResponse(), false, nil
+}
+
+// endChallenge revokes a login challenge and forgets its attempts.
+func (ser *MFAServiceImpl) endChallenge(claims *middlewares.Claims) error {
+	if err := ser.Revocations.Revoke(claims.ID, claims.ExpiresAt.Time); err != nil {
+		log.Printf("Error revoking MFA challenge: %v", err)
+		return fmt.Errorf("failed to log in")
+	}
+
+	if err := ser.Attempts.Reset(mfaAttemptKey(claims.ID)); err != nil {
+		log.Printf("Error resetting MFA attempts: %v", err)
+	}
+
+	return nil
+}
+
+// verifyCode accepts a current TOTP code or an unused recovery code. It
+// must run in a transaction, which locks the user's MFA settings.
+func (ser *MFAServiceImpl) verifyCode(repo repositories.MFARepo, userId int, code string) error {
+	mfa, err := repo.GetForUpdate(userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrMFANotEnabled
+	}
+	if err != nil {
+		log.Printf("Error retrieving MFA settings: %v", err)
+		return fmt.Errorf("failed to verify code")
+	}
+
+	if mfa.EnabledAt == nil {
+		return ErrMFANotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if step, ok := verifyTOTP(mfa.Secret, code, time.Now(), mfa.LastUsedStep); ok {
+		if err := repo.SetLastUsedStep(userId, step); err != nil {
+			log.Printf("Error saving TOTP step: %v", err)
+			return fmt.Errorf("failed to verify code")
+		}
+		return nil
+	}
+
+	used, err := repo.UseRecoveryCode(userId, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		log.Printf("Error using recovery code: %v", err)
+		return fmt.Errorf("failed to verify code")
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (ser *MFAServiceImpl) replaceRecoveryCodes(repo repositories.MFARepo, userId int) (models.RecoveryCodes, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for len(codes) < recoveryCodeCount {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			log.Printf("Error generating recovery code: %v", err)
+			return models.RecoveryCodes{}, fmt.Errorf("failed to generate recovery codes")
+		}
+
+		code := strings.ToLower(totpEncoding.EncodeToString(raw))
+		codes = append(codes, code[:4]+"-"+code[4:])
+		hashes = append(hashes, hashToken(code))
+	}
+
+	if err := repo.ReplaceRecoveryCodes(userId, hashes); err != nil {
+		log.Printf("Error saving recovery codes: %v", err)
+		return models.RecoveryCodes{}, fmt.Errorf("failed to generate recovery codes")
+	}
+
+	return models.RecoveryCodes{Codes: codes}, nil
+}
+
+func (ser *MFAServiceImpl) issuer() string {
+	if ser.Issuer == "" {
+		return DefaultTOTPIssuer
+	}
+	return ser.Issuer
+}
+
+func mfaAttemptKey(challengeId string) string {
+	return "mfa:" + challengeId
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+	return strings.ToLower(code)
+}
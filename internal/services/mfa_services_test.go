@@ -0,0 +1,81 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+	"market/web/handlers/middlewares"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// enabledMFARepo holds one user with two-factor authentication enabled and
+// no recovery codes.
+type enabledMFARepo struct {
+	repositories.MFARepo
+}
+
+func (repo enabledMFARepo) WithTx(tx *sqlx.Tx) repositories.MFARepo {
+	return repo
+}
+
+func (repo enabledMFARepo) Get(userId int) (models.UserMFA, error) {
+	enabledAt := time.Now()
+	return models.UserMFA{UserId: userId, Secret: "JBSWY3DPEHPK3PXP", EnabledAt: &enabledAt}, nil
+}
+
+func (repo enabledMFARepo) GetForUpdate(userId int) (models.UserMFA, error) {
+	return repo.Get(userId)
+}
+
+func (repo enabledMFARepo) UseRecoveryCode(userId int, codeHash string) (bool, error) {
+	return false, nil
+}
+
+func TestCompleteLoginCountsWrongCodesAcrossChallenges(t *testing.T) {
+	keys, err := middlewares.GenerateKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	middlewares.SetKeySet(keys)
+	middlewares.SetTokenConfig(middlewares.TokenConfig{Issuer: "market-api", Audience: []string{"market-api"}})
+	revocations := middlewares.NewMemoryRevocationStore()
+	middlewares.SetRevocationStore(revocations)
+
+	guard, store := newTestGuard()
+	ser := &MFAServiceImpl{
+		Repo:        enabledMFARepo{},
+		Revocations: revocations,
+		Attempts:    NewMemoryLoginAttemptStore(),
+		Guard:       guard,
+		Tx:          noTx{},
+	}
+	user := models.UserResponse{Id: 1, Username: "alice"}
+
+	first, _, err := ser.Challenge(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ser.CompleteLogin(models.MFALogin{MFAToken: first.MFAToken, Code: "wrong"}, "10.0.0.1")
+	if !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("wrong code: got %v, want ErrInvalidMFACode", err)
+	}
+
+	attempts, _ := store.Get(userAttemptKey("alice"))
+	if attempts.Failures != 1 {
+		t.Errorf("user key has %d failures, want 1", attempts.Failures)
+	}
+
+	// A fresh challenge does not bring fresh guesses.
+	second, _, err := ser.Challenge(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ser.CompleteLogin(models.MFALogin{MFAToken: second.MFAToken, Code: "wrong"}, "10.0.0.2")
+	if !errors.Is(err, ErrLoginBlocked) {
+		t.Errorf("code right after a wrong one: got %v, want ErrLoginBlocked", err)
+	}
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"market/internal/database"
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+	"market/web/handlers/middlewares"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const DefaultOfferTTL = 48 * time.Hour
+
+var (
+	ErrOfferForbidden  = errors.New("you are not allowed to respond to this offer")
+	ErrOfferNotPending = errors.New("offer is no longer pending")
+)
+
+type OfferService interface {
+	Create(itemId int, price models.Money, buyerId int) (models.Offer, error)
+	Get(id int, claims *middlewares.Claims) (models.Offer, error)
+	GetByItem(itemId int, claims *middlewares.Claims, page database.PageInfo) (database.Page[models.Offer], error)
+	GetByUser(userId int, page database.PageInfo) (database.Page[models.Offer], error)
+	Accept(id int, claims *middlewares.Claims) (models.Deal, error)
+	Reject(id int, claims *middlewares.Claims) (models.Offer, error)
+	Counter(id int, price models.Money, claims *middlewares.Claims) (models.Offer, error)
+}
+
+type OfferServiceImpl struct {
+	Repo        repositories.OfferRepo
+	ItemRepo    repositories.ItemRepo
+	OrderRepo   repositories.OrderRepo
+	DealService DealService
+	Tx          database.Transactor
+	TTL         time.Duration
+}
+
+func (ser *OfferServiceImpl) Create(itemId int, price models.Money, buyerId int) (models.Offer, error) {
+	if err := validateAmount(price); err != nil {
+		return models.Offer{}, err
+	}
+
+	item, err := ser.ItemRepo.Get(itemId)
+	if err != nil {
+		log.Printf("Item not found: %v", err)
+		return models.Offer{}, fmt.Errorf("item not found")
+	}
+
+	if item.OwnerId == buyerId {
+		return models.Offer{}, fmt.Errorf("you already own this item")
+	}
+
+	if price.Currency != item.Price.Currency {
+		return models.Offer{}, fmt.Errorf("offer currency does not match item currency")
+	}
+
+	offer, err := ser.Repo.Create(models.NewOffer{
+		ItemId:     item.Id,
+		BuyerId:    buyerId,
+		SellerId:   item.OwnerId,
+		ProposerId: buyerId,
+		Price:      price,
+		ExpiresAt:  time.Now().Add(ser.ttl()),
+	})
+	if err != nil {
+		log.Printf("Error creating offer: %v", err)
+		return models.Offer{}, fmt.Errorf("failed to create offer")
+	}
+
+	return offer, nil
+}
+
+func (ser *OfferServiceImpl) Get(id int, claims *middlewares.Claims) (models.Offer, error) {
+	if id <= 0 {
+		return models.Offer{}, fmt.Errorf("invalid offer ID")
+	}
+
+	offer, err := ser.Repo.Get(id)
+	if err != nil {
+		log.Printf("Error retrieving offer: %v", err)
+		return models.Offer{}, fmt.Errorf("offer not found")
+	}
+
+	if offer.BuyerId != claims.UserId && offer.SellerId != claims.UserId {
+		return models.Offer{}, fmt.Errorf("offer not found")
+	}
+
+	return offer, nil
+}
+
+// GetByItem lists all offers on an item for its owner, and only the caller's
+// own offers for anyone else.
+func (ser *OfferServiceImpl) GetByItem(itemId int, claims *middlewares.Claims, page database.PageInfo) (database.Page[models.Offer], error) {
+	if page.PageNumber <= 0 || page.PageSize <= 0 {
+		return database.Page[models.Offer]{}, fmt.Errorf("invalid pagination")
+	}
+
+	item, err := ser.ItemRepo.Get(itemId)
+	if err != nil {
+		log.Printf("Item not found: %v", err)
+		return database.Page[models.Offer]{}, fmt.Errorf("item not found")
+	}
+
+	buyerId := claims.UserId
+	if item.OwnerId == claims.UserId {
+		buyerId = 0
+	}
+
+	ser.expireStale()
+
+	offers, err := ser.Repo.GetByItem(itemId, buyerId, page)
+	if err != nil {
+		log.Printf("Error retrieving offers: %v", err)
+		return database.Page[models.Offer]{}, fmt.Errorf("failed to get offers")
+	}
+
+	total, err := ser.Repo.CountByItem(itemId, buyerId)
+	if err != nil {
+		log.Printf("Error counting offers: %v", err)
+		return database.Page[models.Offer]{}, fmt.Errorf("failed to get offers")
+	}
+
+	return database.NewPage(offers, total, page), nil
+}
+
+func (ser *OfferServiceImpl) GetByUser(userId int, page database.PageInfo) (database.Page[models.Offer], error) {
+	if page.PageNumber <= 0 || page.PageSize <= 0 {
+		return database.Page[models.Offer]{}, fmt.Errorf("invalid pagination")
+	}
+
+	ser.expireStale()
+
+	offers, err := ser.Repo.GetByUser(userId, page)
+	if err != nil {
+		log.Printf("Error retrieving offers: %v", err)
+		return database.Page[models.Offer]{}, fmt.Errorf("failed to get offers")
+	}
+
+	total, err := ser.Repo.CountByUser(userId)
+	if err != nil {
+		log.Printf("Error counting offers: %v", err)
+		return database.Page[models.Offer]{}, fmt.Errorf("failed to get offers")
+	}
+
+	return database.NewPage(offers, total, page), nil
+}
+
+// Accept closes the offer and opens an accepted deal at the offered price.
+func (ser *OfferServiceImpl) Accept(id int, claims *middlewares.Claims) (models.Deal, error) {
+	var deal models.Deal
+	err := ser.respond(id, claims, models.OfferStatusAccepted, func(tx *sqlx.Tx, offer models.Offer) error {
+		item, err := ser.ItemRepo.WithTx(tx).GetForUpdate(offer.ItemId)
+		if err != nil {
+			log.Printf("Item not found: %v", err)
+			return fmt.Errorf("item not found")
+		}
+
+		if item.OwnerId != offer.SellerId {
+			return fmt.Errorf("seller no longer owns this item")
+		}
+
+		if err := ser.checkAvailable(tx, item); err != nil {
+			return err
+		}
+
+		deal, err = ser.DealService.CreateAgreed(tx, models.NewDeal{
+			Item:   item,
+			User:   models.User{Id: offer.BuyerId},
+			Seller: models.User{Id: offer.SellerId},
+			Price:  offer.Price,
+		}, claims.UserId)
+		if err != nil {
+			return err
+		}
+
+		return ser.Repo.WithTx(tx).SetDeal(offer.Id, deal.Id)
+	})
+	if err != nil {
+		return models.Deal{}, err
+	}
+
+	return deal, nil
+}
+
+// checkAvailable rejects accepting an offer on an item that is already
+// promised elsewhere: in another deal, an open auction, or resting asks that
+// cover every unit of its name the seller owns. The caller holds the item
+// lock.
+func (ser *OfferServiceImpl) checkAvailable(tx *sqlx.Tx, item models.Item) error {
+	itemRepo := ser.ItemRepo.WithTx(tx)
+
+	inDeal, err := itemRepo.HasActiveDeal(item.Id)
+	if err != nil {
+		log.Printf("Error checking item deals: %v", err)
+		return fmt.Errorf("failed to accept offer")
+	}
+	if inDeal {
+		return fmt.Errorf("item already has an active deal")
+	}
+
+	inAuction, err := itemRepo.HasOpenAuction(item.Id)
+	if err != nil {
+		log.Printf("Error checking item auctions: %v", err)
+		return fmt.Errorf("failed to accept offer")
+	}
+	if inAuction {
+		return fmt.Errorf("item is in an open auction")
+	}
+
+	owned, err := itemRepo.CountOwnedByName(item.OwnerId, item.Name)
+	if err != nil {
+		log.Printf("Error counting items: %v", err)
+		return fmt.Errorf("failed to accept offer")
+	}
+
+	reserved, err := ser.OrderRepo.WithTx(tx).OpenAskQuantity(item.OwnerId, item.Name)
+	if err != nil {
+		log.Printf("Error counting open asks: %v", err)
+		return fmt.Errorf("failed to accept offer")
+	}
+	if owned-reserved < 1 {
+		return fmt.Errorf("item is reserved by an open sell order")
+	}
+
+	return nil
+}
+
+func (ser *OfferServiceImpl) Reject(id int, claims *middlewares.Claims) (models.Offer, error) {
+	var rejected models.Offer
+	err := ser.respond(id, claims, models.OfferStatusRejected, func(tx *sqlx.Tx, offer models.Offer) error {
+		rejected = offer
+		rejected.Status = models.OfferStatusRejected
+		return nil
+	})
+	if err != nil {
+		return models.Offer{}, err
+	}
+
+	return rejected, nil
+}
+
+// Counter closes the offer and proposes a new price back to the other side.
+func (ser *OfferServiceImpl) Counter(id int, price models.Money, claims *middlewares.Claims) (models.Offer, error) {
+	if err := validateAmount(price); err != nil {
+		return models.Offer{}, err
+	}
+
+	var counter models.Offer
+	err := ser.respond(id, claims, models.OfferStatusCountered, func(tx *sqlx.Tx, offer models.Offer) error {
+		if price.Currency != offer.Price.Currency {
+			return fmt.Errorf("counter-offer currency does not match offer currency")
+		}
+
+		var err error
+		counter, err = ser.Repo.WithTx(tx).Create(models.NewOffer{
+			ItemId:     offer.ItemId,
+			BuyerId:    offer.BuyerId,
+			SellerId:   offer.SellerId,
+			ProposerId: claims.UserId,
+			ParentId:   &offer.Id,
+			Price:      price,
+			ExpiresAt:  time.Now().Add(ser.ttl()),
+		})
+		if err != nil {
+			log.Printf("Error creating counter-offer: %v", err)
+			return fmt.Errorf("failed to create counter-offer")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return models.Offer{}, err
+	}
+
+	return counter, nil
+}
+
+// respond locks a pending offer, checks that the caller is the side that did
+// not propose it, runs apply and moves the offer to status, all in one
+// transaction.
+func (ser *OfferServiceImpl) respond(id int, claims *middlewares.Claims, status string, apply func(tx *sqlx.Tx, offer models.Offer) error) error {
+	if id <= 0 {
+		return fmt.Errorf("invalid offer ID")
+	}
+
+	return ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		repo := ser.Repo.WithTx(tx)
+
+		offer, err := repo.GetForUpdate(id)
+		if err != nil {
+			log.Printf("Offer not found: %v", err)
+			return fmt.Errorf("offer not found")
+		}
+
+		if offer.BuyerId != claims.UserId && offer.SellerId != claims.UserId {
+			return ErrOfferForbidden
+		}
+
+		if offer.ProposerId == claims.UserId {
+			return ErrOfferForbidden
+		}
+
+		if offer.Status != models.OfferStatusPending || !time.Now().Before(offer.ExpiresAt) {
+			return ErrOfferNotPending
+		}
+
+		if err := apply(tx, offer); err != nil {
+			return err
+		}
+
+		if err := repo.UpdateStatus(offer.Id, offer.Status, status); err != nil {
+			log.Printf("Error updating offer status: %v", err)
+			return fmt.Errorf("failed to update offer")
+		}
+
+		return nil
+	})
+}
+
+func (ser *OfferServiceImpl) expireStale() {
+	if err := ser.Repo.ExpireStale(); err != nil {
+		log.Printf("Error expiring offers: %v", err)
+	}
+}
+
+func (ser *OfferServiceImpl) ttl() time.Duration {
+	if ser.TTL <= 0 {
+		return DefaultOfferTTL
+	}
+	return ser.TTL
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"market/internal/database"
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+	"market/internal/oidc"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const DefaultOIDCStateTTL = 10 * time.Minute
+
+var (
+	ErrUnknownOIDCProvider = errors.New("unknown identity provider")
+	ErrInvalidOIDCState    = errors.New("login expired or was already used, start again")
+	ErrOIDCLoginFailed     = errors.New("identity provider login failed")
+	ErrOIDCEmailUnverified = errors.New("identity provider did not confirm a verified email")
+	ErrOIDCEmailConflict   = errors.New("an account with this email exists; verify its email before signing in with a provider")
+	ErrOIDCUserBanned      = errors.New("user is banned")
+)
+
+type OIDCService interface {
+	Providers() models.OIDCProviders
+	Begin(provider string) (string, error)
+	Callback(provider string, code string, state string) (models.UserResponse, error)
+}
+
+// OIDCServiceImpl signs users in through external OpenID Connect providers.
+// A provider account is linked to the user it logged in before, or else to
+// the user with the same verified email; a new user is created when there
+// is none.
+type OIDCServiceImpl struct {
+	Clients  map[string]*oidc.Client
+	Repo     repositories.IdentityRepo
+	UserRepo repositories.UserRepo
+	Tx       database.Transactor
+	StateTTL time.Duration
+}
+
+func (ser *OIDCServiceImpl) Providers() models.OIDCProviders {
+	names := make([]string, 0, len(ser.Clients))
+	for name := range ser.Clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return models.OIDCProviders{Providers: names}
+}
+
+// Begin starts a login and returns the provider URL to send the user to.
+func (ser *OIDCServiceImpl) Begin(provider string) (string, error) {
+	client, ok := ser.Clients[provider]
+	if !ok {
+		return "", ErrUnknownOIDCProvider
+	}
+
+	state, errState := oidc.RandomString()
+	nonce, errNonce := oidc.RandomString()
+	verifier, errVerifier := oidc.RandomString()
+	if err := errors.Join(errState, errNonce, errVerifier); err != nil {
+		log.Printf("Error generating OIDC state: %v", err)
+		return "", fmt.Errorf("failed to start login")
+	}
+
+	err := ser.Repo.CreateState(models.OIDCLoginState{
+		StateHash:    hashToken(state),
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(ser.stateTTL()),
+	})
+	if err != nil {
+		log.Printf("Error saving OIDC state: %v", err)
+		return "", fmt.Errorf("failed to start login")
+	}
+
+	authURL, err := client.AuthCodeURL(context.Background(), state, nonce, oidc.S256Challenge(verifier))
+	if err != nil {
+		log.Printf("Error building OIDC authorization URL: %v", err)
+		return "", fmt.Errorf("failed to start login")
+	}
+
+	return authURL, nil
+}
+
+// Callback finishes a login: it redeems the code with the login's PKCE
+// verifier, validates the ID token and returns the linked user.
+func (ser *OIDCServiceImpl) Callback(provider string, code string, state string) (models.UserResponse, error) {
+	client, ok := ser.Clients[provider]
+	if !ok {
+		return models.UserResponse{}, ErrUnknownOIDCProvider
+	}
+
+	login, err := ser.Repo.TakeState(hashToken(state))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.UserResponse{}, ErrInvalidOIDCState
+	}
+	if err != nil {
+		log.Printf("Error retrieving OIDC state: %v", err)
+		return models.UserResponse{}, fmt.Errorf("failed to log in")
+	}
+	if login.Provider != provider || !login.ExpiresAt.After(time.Now()) {
+		return models.UserResponse{}, ErrInvalidOIDCState
+	}
+
+	ctx := context.Background()
+	token, err := client.Exchange(ctx, code, login.CodeVerifier)
+	if err != nil {
+		log.Printf("Error exchanging OIDC code with %s: %v", provider, err)
+		return models.UserResponse{}, ErrOIDCLoginFailed
+	}
+
+	claims, err := client.VerifyIDToken(ctx, token.IDToken, login.Nonce)
+	if err != nil {
+		log.Printf("Error verifying ID token from %s: %v", provider, err)
+		return models.UserResponse{}, ErrOIDCLoginFailed
+	}
+
+	var user models.User
+	err = ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		user, err = ser.linkUser(tx, provider, claims)
+		return err
+	})
+	if err != nil {
+		return models.UserResponse{}, err
+	}
+
+	if user.BannedAt != nil {
+		return models.UserResponse{}, ErrOIDCUserBanned
+	}
+
+	return user.ToResponse(), nil
+}
+
+func (ser *OIDCServiceImpl) linkUser(tx *sqlx.Tx, provider string, claims *oidc.IDTokenClaims) (models.User, error) {
+	repo := ser.Repo.WithTx(tx)
+	userRepo := ser.UserRepo.WithTx(tx)
+
+	identity, err := repo.Get(provider, claims.Subject)
+	if err == nil {
+		if err := repo.TouchLogin(identity.Id, claims.Email); err != nil {
+			log.Printf("Error updating identity: %v", err)
+		}
+
+		user, err := userRepo.Get(identity.UserId)
+		if err != nil {
+			log.Printf("Error retrieving linked user: %v", err)
+			return models.User{}, fmt.Errorf("failed to log in")
+		}
+		return user, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error retrieving identity: %v", err)
+		return models.User{}, fmt.Errorf("failed to log in")
+	}
+
+	// Linking and sign-up both rely on the provider vouching for the email.
+	if claims.Email == "" || !claims.EmailVerified {
+		return models.User{}, ErrOIDCEmailUnverified
+	}
+
+	user, err := userRepo.GetByEmail(claims.Email)
+	switch {
+	case err == nil:
+		// An unverified local account could have been registered by anyone
+		// with this address, so it must not inherit the provider login.
+		if user.EmailVerifiedAt == nil {
+			return models.User{}, ErrOIDCEmailConflict
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		user, err = ser.createUser(userRepo, claims)
+		if err != nil {
+			return models.User{}, err
+		}
+	default:
+		log.Printf("Error retrieving user by email: %v", err)
+		return models.User{}, fmt.Errorf("failed to log in")
+	}
+
+	err = repo.Create(models.UserIdentity{
+		UserId:   user.Id,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	})
+	if err != nil {
+		log.Printf("Error linking identity: %v", err)
+		return models.User{}, fmt.Errorf("failed to log in")
+	}
+
+	return user, nil
+}
+
+// createUser signs up a user without a local password. They can set one
+// through the password reset flow.
+func (ser *OIDCServiceImpl) createUser(userRepo repositories.UserRepo, claims *oidc.IDTokenClaims) (models.User, error) {
+	username, err := ser.freeUsername(userRepo, claims)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user, err := userRepo.Create(models.NewUser{Username: username, Email: claims.Email})
+	if err != nil {
+		log.Printf("Error creating user from identity: %v", err)
+		return models.User{}, fmt.Errorf("failed to create user")
+	}
+
+	if err := userRepo.MarkEmailVerified(user.Id); err != nil {
+		log.Printf("Error verifying email: %v", err)
+		return models.User{}, fmt.Errorf("failed to create user")
+	}
+
+	return userRepo.Get(user.Id)
+}
+
+// freeUsername derives a username from the provider's profile, adding a
+// number when it is taken.
+func (ser *OIDCServiceImpl) freeUsername(userRepo repositories.UserRepo, claims *oidc.IDTokenClaims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	base = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.' || r == '-' {
+			return r
+		}
+		return -1
+	}, base)
+	if len(base) > 15 {
+		base = base[:15]
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	candidate := base
+	for range 10 {
+		_, err := userRepo.GetByUsername(candidate)
+		if errors.Is(err, sql.ErrNoRows) {
+			return candidate, nil
+		}
+		if err != nil {
+			log.Printf("Error retrieving user by username: %v", err)
+			return "", fmt.Errorf("failed to create user")
+		}
+
+		suffix, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			return "", fmt.Errorf("failed to create user")
+		}
+		candidate = fmt.Sprintf("%s%04d", base, suffix.Int64())
+	}
+
+	return "", fmt.Errorf("failed to create user")
+}
+
+func (ser *OIDCServiceImpl) stateTTL() time.Duration {
+	if ser.StateTTL <= 0 {
+		return DefaultOIDCStateTTL
+	}
+	return ser.StateTTL
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+	"market/internal/oidc"
+	"market/internal/oidc/oidctest"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jmoiron/sqlx"
+)
+
+// noTx runs the function without a transaction; the in-memory repositories
+// below ignore it.
+type noTx struct{}
+
+func (noTx) WithTx(fn func(tx *sqlx.Tx) error) error {
+	return fn(nil)
+}
+
+type memoryIdentityRepo struct {
+	identities []models.UserIdentity
+	states     map[string]models.OIDCLoginState
+}
+
+func (repo *memoryIdentityRepo) WithTx(tx *sqlx.Tx) repositories.IdentityRepo {
+	return repo
+}
+
+func (repo *memoryIdentityRepo) Get(provider string, subject string) (models.UserIdentity, error) {
+	for _, identity := range repo.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return models.UserIdentity{}, sql.ErrNoRows
+}
+
+func (repo *memoryIdentityRepo) Create(identity models.UserIdentity) error {
+	identity.Id = len(repo.identities) + 1
+	repo.identities = append(repo.identities, identity)
+	return nil
+}
+
+func (repo *memoryIdentityRepo) TouchLogin(id int, email string) error {
+	return nil
+}
+
+func (repo *memoryIdentityRepo) CreateState(state models.OIDCLoginState) error {
+	repo.states[state.StateHash] = state
+	return nil
+}
+
+func (repo *memoryIdentityRepo) TakeState(stateHash string) (models.OIDCLoginState, error) {
+	state, ok := repo.states[stateHash]
+	if !ok {
+		return models.OIDCLoginState{}, sql.ErrNoRows
+	}
+	delete(repo.states, stateHash)
+	return state, nil
+}
+
+func (repo *memoryIdentityRepo) DeleteExpiredStates() (int, error) {
+	return 0, nil
+}
+
+// memoryUserRepo implements the user lookups OIDC login needs.
+type memoryUserRepo struct {
+	repositories.UserRepo
+	users []models.User
+}
+
+func (repo *memoryUserRepo) WithTx(tx *sqlx.Tx) repositories.UserRepo {
+	return repo
+}
+
+func (repo *memoryUserRepo) Create(newUser models.NewUser) (models.User, error) {
+	user := models.User{Id: len(repo.users) + 1, Username: newUser.Username, Email: newUser.Email}
+	repo.users = append(repo.users, user)
+	return user, nil
+}
+
+func (repo *memoryUserRepo) Get(id int) (models.User, error) {
+	for _, user := range repo.users {
+		if user.Id == id {
+			return user, nil
+		}
+	}
+	return models.User{}, sql.ErrNoRows
+}
+
+func (repo *memoryUserRepo) GetByUsername(username string) (models.User, error) {
+	for _, user := range repo.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return models.User{}, sql.ErrNoRows
+}
+
+func (repo *memoryUserRepo) GetByEmail(email string) (models.User, error) {
+	for _, user := range repo.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return models.User{}, sql.ErrNoRows
+}
+
+func (repo *memoryUserRepo) MarkEmailVerified(id int) error {
+	now := time.Now()
+	for i := range repo.users {
+		if repo.users[i].Id == id {
+			repo.users[i].EmailVerifiedAt = &now
+		}
+	}
+	return nil
+}
+
+func newOIDCTestService(provider *oidctest.Provider, users ...models.User) *OIDCServiceImpl {
+	return &OIDCServiceImpl{
+		Clients:  map[string]*oidc.Client{"stub": oidc.NewClient(provider.Config("stub"))},
+		Repo:     &memoryIdentityRepo{states: map[string]models.OIDCLoginState{}},
+		UserRepo: &memoryUserRepo{users: users},
+		Tx:       noTx{},
+	}
+}
+
+// signIn begins a login, signs in at the provider and returns the code and
+// state the callback receives.
+func signIn(t *testing.T, ser *OIDCServiceImpl, provider *oidctest.Provider, claims jwt.MapClaims) (string, string) {
+	t.Helper()
+
+	authURL, err := ser.Begin("stub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, state, err := provider.Authorize(authURL, "subject", claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return code, state
+}
+
+var verifiedEmail = jwt.MapClaims{"email": "alice@example.com", "email_verified": true, "preferred_username": "alice"}
+
+func TestOIDCCallbackCreatesUser(t *testing.T) {
+	provider := oidctest.NewProvider("client")
+	defer provider.Close()
+
+	ser := newOIDCTestService(provider)
+	code, state := signIn(t, ser, provider, verifiedEmail)
+
+	user, err := ser.Callback("stub", code, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "alice" {
+		t.Fatalf("username = %q, want %q", user.Username, "alice")
+	}
+
+	// The second login finds the linked identity.
+	code, state = signIn(t, ser, provider, verifiedEmail)
+	again, err := ser.Callback("stub", code, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Id != user.Id {
+		t.Fatalf("second login got user %d, want %d", again.Id, user.Id)
+	}
+}
+
+func TestOIDCCallbackRejectsReplayedState(t *testing.T) {
+	provider := oidctest.NewProvider("client")
+	defer provider.Close()
+
+	ser := newOIDCTestService(provider)
+	code, state := signIn(t, ser, provider, verifiedEmail)
+
+	if _, err := ser.Callback("stub", code, state); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ser.Callback("stub", code, state); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidOIDCState)
+	}
+}
+
+func TestOIDCCallbackRejectsUnverifiedLocalEmail(t *testing.T) {
+	provider := oidctest.NewProvider("client")
+	defer provider.Close()
+
+	ser := newOIDCTestService(provider, models.User{Id: 1, Username: "alice", Email: "alice@example.com"})
+	code, state := signIn(t, ser, provider, verifiedEmail)
+
+	if _, err := ser.Callback("stub", code, state); !errors.Is(err, ErrOIDCEmailConflict) {
+		t.Fatalf("err = %v, want %v", err, ErrOIDCEmailConflict)
+	}
+}
+
+func TestOIDCCallbackLinksVerifiedLocalEmail(t *testing.T) {
+	provider := oidctest.NewProvider("client")
+	defer provider.Close()
+
+	verifiedAt := time.Now()
+	ser := newOIDCTestService(provider, models.User{Id: 1, Username: "alice", Email: "alice@example.com", EmailVerifiedAt: &verifiedAt})
+	code, state := signIn(t, ser, provider, verifiedEmail)
+
+	user, err := ser.Callback("stub", code, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Id != 1 {
+		t.Fatalf("linked to user %d, want 1", user.Id)
+	}
+}
+
+func TestOIDCCallbackRejectsUnverifiedProviderEmail(t *testing.T) {
+	provider := oidctest.NewProvider("client")
+	defer provider.Close()
+
+	ser := newOIDCTestService(provider)
+	code, state := signIn(t, ser, provider, jwt.MapClaims{"email": "alice@example.com", "email_verified": false})
+
+	if _, err := ser.Callback("stub", code, state); !errors.Is(err, ErrOIDCEmailUnverified) {
+		t.Fatalf("err = %v, want %v", err, ErrOIDCEmailUnverified)
+	}
+}
+
+func TestOIDCCallbackRejectsForeignNonce(t *testing.T) {
+	provider := oidctest.NewProvider("client")
+	defer provider.Close()
+
+	ser := newOIDCTestService(provider)
+	code, state := signIn(t, ser, provider, jwt.MapClaims{"nonce": "other", "email": "alice@example.com", "email_verified": true})
+
+	if _, err := ser.Callback("stub", code, state); !errors.Is(err, ErrOIDCLoginFailed) {
+		t.Fatalf("err = %v, want %v", err, ErrOIDCLoginFailed)
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"market/internal/database/models"
+	"sort"
+	"sync"
+)
+
+type orderBookKey struct {
+	itemName string
+	currency string
+}
+
+// orderBook holds the open orders for one item name and currency. Bids are
+// kept best first (highest price, then oldest) and asks best first (lowest
+// price, then oldest); order ids grow with submission time. users counts
+// the callers between acquireBook and releaseBook and is guarded by the
+// service's mutex, not the book's.
+type orderBook struct {
+	mu    sync.Mutex
+	key   orderBookKey
+	users int
+	bids  []*models.Order
+	asks  []*models.Order
+}
+
+func bidBefore(a, b *models.Order) bool {
+	if a.Price.Amount != b.Price.Amount {
+		return a.Price.Amount > b.Price.Amount
+	}
+	return a.Id < b.Id
+}
+
+func askBefore(a, b *models.Order) bool {
+	if a.Price.Amount != b.Price.Amount {
+		return a.Price.Amount < b.Price.Amount
+	}
+	return a.Id < b.Id
+}
+
+func (book *orderBook) add(order *models.Order) {
+	if order.Side == models.OrderSideBid {
+		book.bids = insertOrder(book.bids, order, bidBefore)
+	} else {
+		book.asks = insertOrder(book.asks, order, askBefore)
+	}
+}
+
+func insertOrder(orders []*models.Order, order *models.Order, before func(a, b *models.Order) bool) []*models.Order {
+	i := sort.Search(len(orders), func(i int) bool { return before(order, orders[i]) })
+	orders = append(orders, nil)
+	copy(orders[i+1:], orders[i:])
+	orders[i] = order
+	return orders
+}
+
+func (book *orderBook) remove(order *models.Order) {
+	if order.Side == models.OrderSideBid {
+		book.bids = removeOrder(book.bids, order.Id)
+	} else {
+		book.asks = removeOrder(book.asks, order.Id)
+	}
+}
+
+func removeOrder(orders []*models.Order, id int) []*models.Order {
+	for i, order := range orders {
+		if order.Id == id {
+			return append(orders[:i], orders[i+1:]...)
+		}
+	}
+	return orders
+}
+
+func (book *orderBook) find(id int) *models.Order {
+	for _, orders := range [][]*models.Order{book.bids, book.asks} {
+		for _, order := range orders {
+			if order.Id == id {
+				return order
+			}
+		}
+	}
+	return nil
+}
+
+// crossed returns the best bid and ask if they can trade with each other.
+func (book *orderBook) crossed() (*models.Order, *models.Order, bool) {
+	if len(book.bids) == 0 || len(book.asks) == 0 {
+		return nil, nil, false
+	}
+
+	bid, ask := book.bids[0], book.asks[0]
+	if bid.Price.Amount < ask.Price.Amount {
+		return nil, nil, false
+	}
+
+	return bid, ask, true
+}
+
+func (book *orderBook) snapshot() ([]models.BookLevel, []models.BookLevel) {
+	return aggregateLevels(book.bids), aggregateLevels(book.asks)
+}
+
+func aggregateLevels(orders []*models.Order) []models.BookLevel {
+	levels := []models.BookLevel{}
+	for _, order := range orders {
+		last := len(levels) - 1
+		if last >= 0 && levels[last].Price == order.Price {
+			levels[last].Quantity += order.Remaining()
+			continue
+		}
+		levels = append(levels, models.BookLevel{Price: order.Price, Quantity: order.Remaining()})
+	}
+	return levels
+}
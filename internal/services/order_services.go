@@ -0,0 +1,375 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"market/internal/database"
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+	"market/web/handlers/middlewares"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	errBuyerCannotPay   = errors.New("buyer cannot pay for the fill")
+	errSellerHasNoItems = errors.New("seller has no items left to deliver")
+)
+
+type OrderService interface {
+	Load() error
+	Submit(newOrder models.NewOrder, userId int) (models.Order, error)
+	Get(id int, claims *middlewares.Claims) (models.Order, error)
+	GetByUser(userId int, page database.PageInfo) (database.Page[models.Order], error)
+	Cancel(id int, claims *middlewares.Claims) (models.Order, error)
+	GetBook(itemName string, currency string) (models.OrderBookSnapshot, error)
+}
+
+// OrderServiceImpl matches limit orders for identical items, identified by
+// item name, in in-memory books with price-time priority. The database is
+// the source of truth: every order and fill is persisted before the books
+// change, and Load rebuilds the books from open orders on startup.
+type OrderServiceImpl struct {
+	Repo       repositories.OrderRepo
+	ItemRepo   repositories.ItemRepo
+	DealRepo   repositories.DealRepo
+	WalletRepo repositories.WalletRepo
+	Tx         database.Transactor
+
+	mu    sync.Mutex
+	books map[orderBookKey]*orderBook
+}
+
+func (ser *OrderServiceImpl) Load() error {
+	orders, err := ser.Repo.GetOpen()
+	if err != nil {
+		return fmt.Errorf("failed to load open orders: %w", err)
+	}
+
+	for i := range orders {
+		order := orders[i]
+		book := ser.acquireBook(order.ItemName, order.Price.Currency)
+		book.mu.Lock()
+		book.add(&order)
+		ser.releaseBook(book)
+		book.mu.Unlock()
+	}
+
+	ser.mu.Lock()
+	books := make([]*orderBook, 0, len(ser.books))
+	for _, book := range ser.books {
+		book.users++
+		books = append(books, book)
+	}
+	ser.mu.Unlock()
+
+	// A crash between fills can leave crossed orders behind; finish matching
+	// them before accepting new orders.
+	for _, book := range books {
+		book.mu.Lock()
+		ser.match(book)
+		ser.releaseBook(book)
+		book.mu.Unlock()
+	}
+
+	return nil
+}
+
+func (ser *OrderServiceImpl) Submit(newOrder models.NewOrder, userId int) (models.Order, error) {
+	newOrder.ItemName = fixName(newOrder.ItemName)
+	if len(newOrder.ItemName) == 0 {
+		return models.Order{}, fmt.Errorf("invalid item name")
+	}
+
+	if newOrder.Side != models.OrderSideBid && newOrder.Side != models.OrderSideAsk {
+		return models.Order{}, fmt.Errorf("side must be bid or ask")
+	}
+
+	if err := validateAmount(newOrder.Price); err != nil {
+		return models.Order{}, err
+	}
+
+	if newOrder.Quantity <= 0 {
+		return models.Order{}, fmt.Errorf("quantity must be positive")
+	}
+
+	book := ser.acquireBook(newOrder.ItemName, newOrder.Price.Currency)
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	defer ser.releaseBook(book)
+
+	if newOrder.Side == models.OrderSideAsk {
+		if err := ser.checkInventory(userId, newOrder); err != nil {
+			return models.Order{}, err
+		}
+	}
+
+	order, err := ser.Repo.Create(userId, newOrder)
+	if err != nil {
+		log.Printf("Error creating order: %v", err)
+		return models.Order{}, fmt.Errorf("failed to create order")
+	}
+
+	book.add(&order)
+	ser.match(book)
+
+	return order, nil
+}
+
+func (ser *OrderServiceImpl) Get(id int, claims *middlewares.Claims) (models.Order, error) {
+	if id <= 0 {
+		return models.Order{}, fmt.Errorf("invalid order ID")
+	}
+
+	order, err := ser.Repo.Get(id)
+	if err != nil || order.UserId != claims.UserId {
+		return models.Order{}, fmt.Errorf("order not found")
+	}
+
+	return order, nil
+}
+
+func (ser *OrderServiceImpl) GetByUser(userId int, page database.PageInfo) (database.Page[models.Order], error) {
+	if page.PageNumber <= 0 || page.PageSize <= 0 {
+		return database.Page[models.Order]{}, fmt.Errorf("invalid pagination")
+	}
+
+	orders, err := ser.Repo.GetByUser(userId, page)
+	if err != nil {
+		log.Printf("Error retrieving orders: %v", err)
+		return database.Page[models.Order]{}, fmt.Errorf("failed to get orders")
+	}
+
+	total, err := ser.Repo.CountByUser(userId)
+	if err != nil {
+		log.Printf("Error counting orders: %v", err)
+		return database.Page[models.Order]{}, fmt.Errorf("failed to get orders")
+	}
+
+	return database.NewPage(orders, total, page), nil
+}
+
+func (ser *OrderServiceImpl) Cancel(id int, claims *middlewares.Claims) (models.Order, error) {
+	order, err := ser.Get(id, claims)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	book := ser.acquireBook(order.ItemName, order.Price.Currency)
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	defer ser.releaseBook(book)
+
+	if err := ser.Repo.UpdateStatus(order.Id, models.OrderStatusOpen, models.OrderStatusCancelled); err != nil {
+		return models.Order{}, fmt.Errorf("order is no longer open")
+	}
+
+	if resting := book.find(order.Id); resting != nil {
+		order = *resting
+		book.remove(resting)
+	}
+
+	order.Status = models.OrderStatusCancelled
+	return order, nil
+}
+
+func (ser *OrderServiceImpl) GetBook(itemName string, currency string) (models.OrderBookSnapshot, error) {
+	itemName = fixName(itemName)
+	if err := models.ValidateCurrency(currency); err != nil {
+		return models.OrderBookSnapshot{}, fmt.Errorf("unsupported currency")
+	}
+
+	snapshot := models.OrderBookSnapshot{ItemName: itemName, Currency: currency, Bids: []models.BookLevel{}, Asks: []models.BookLevel{}}
+
+	ser.mu.Lock()
+	book, ok := ser.books[orderBookKey{itemName: itemName, currency: currency}]
+	ser.mu.Unlock()
+	if !ok {
+		return snapshot, nil
+	}
+
+	book.mu.Lock()
+	snapshot.Bids, snapshot.Asks = book.snapshot()
+	book.mu.Unlock()
+
+	return snapshot, nil
+}
+
+// acquireBook returns the book for itemName and currency, creating it if
+// needed, and keeps it registered until the matching releaseBook.
+func (ser *OrderServiceImpl) acquireBook(itemName string, currency string) *orderBook {
+	ser.mu.Lock()
+	defer ser.mu.Unlock()
+
+	if ser.books == nil {
+		ser.books = make(map[orderBookKey]*orderBook)
+	}
+
+	key := orderBookKey{itemName: itemName, currency: currency}
+	book, ok := ser.books[key]
+	if !ok {
+		book = &orderBook{key: key}
+		ser.books[key] = book
+	}
+	book.users++
+
+	return book
+}
+
+// releaseBook undoes acquireBook and drops the book once nobody is using it
+// and it holds no orders, so item names that are no longer traded do not
+// keep their books. The caller must hold book.mu.
+func (ser *OrderServiceImpl) releaseBook(book *orderBook) {
+	ser.mu.Lock()
+	defer ser.mu.Unlock()
+
+	book.users--
+	if book.users == 0 && len(book.bids) == 0 && len(book.asks) == 0 {
+		delete(ser.books, book.key)
+	}
+}
+
+// checkInventory rejects an ask for more units than the seller owns and has
+// not already offered in other open asks.
+func (ser *OrderServiceImpl) checkInventory(userId int, newOrder models.NewOrder) error {
+	owned, err := ser.ItemRepo.CountOwnedByName(userId, newOrder.ItemName)
+	if err != nil {
+		log.Printf("Error counting items: %v", err)
+		return fmt.Errorf("failed to check items")
+	}
+
+	reserved, err := ser.Repo.OpenAskQuantity(userId, newOrder.ItemName)
+	if err != nil {
+		log.Printf("Error counting open asks: %v", err)
+		return fmt.Errorf("failed to check items")
+	}
+
+	if owned-reserved < newOrder.Quantity {
+		return fmt.Errorf("not enough items to sell")
+	}
+
+	return nil
+}
+
+// match fills crossing orders until the book is no longer crossed. The
+// caller must hold book.mu. An order whose owner can no longer pay or
+// deliver is cancelled and matching continues with the next one.
+func (ser *OrderServiceImpl) match(book *orderBook) {
+	for {
+		bid, ask, ok := book.crossed()
+		if !ok {
+			return
+		}
+
+		if bid.UserId == ask.UserId {
+			ser.cancelResting(book, newer(bid, ask))
+			continue
+		}
+
+		// The order that was resting first sets the trade price.
+		price := ask.Price
+		if bid.Id < ask.Id {
+			price = bid.Price
+		}
+
+		quantity := min(bid.Remaining(), ask.Remaining())
+
+		err := ser.fill(bid, ask, quantity, price)
+		switch {
+		case errors.Is(err, errBuyerCannotPay):
+			ser.cancelResting(book, bid)
+			continue
+		case errors.Is(err, errSellerHasNoItems):
+			ser.cancelResting(book, ask)
+			continue
+		case err != nil:
+			log.Printf("Error filling orders %d and %d: %v", bid.Id, ask.Id, err)
+			return
+		}
+
+		for _, order := range []*models.Order{bid, ask} {
+			order.Filled += quantity
+			if order.Remaining() == 0 {
+				order.Status = models.OrderStatusFilled
+				book.remove(order)
+			}
+		}
+	}
+}
+
+// fill trades quantity units between bid and ask at price in one
+// transaction, moving one item and recording one deal per unit.
+func (ser *OrderServiceImpl) fill(bid, ask *models.Order, quantity int, price models.Money) error {
+	actorId := newer(bid, ask).UserId
+
+	return ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		itemRepo := ser.ItemRepo.WithTx(tx)
+		dealRepo := ser.DealRepo.WithTx(tx)
+		walletRepo := ser.WalletRepo.WithTx(tx)
+		orderRepo := ser.Repo.WithTx(tx)
+
+		for i := 0; i < quantity; i++ {
+			item, err := itemRepo.GetOwnedByNameForUpdate(ask.UserId, ask.ItemName)
+			if errors.Is(err, sql.ErrNoRows) {
+				return errSellerHasNoItems
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := itemRepo.UpdateOwner(item.Id, bid.UserId); err != nil {
+				return err
+			}
+
+			deal, err := dealRepo.Create(models.NewDeal{
+				Item:   item,
+				User:   models.User{Id: bid.UserId},
+				Seller: models.User{Id: ask.UserId},
+				Price:  price,
+				Status: models.DealStatusCompleted,
+			})
+			if err != nil {
+				return err
+			}
+
+			err = dealRepo.AddEvent(models.DealEvent{DealId: deal.Id, ToStatus: deal.Status, ActorId: &actorId})
+			if err != nil {
+				return err
+			}
+
+			err = postTransfer(walletRepo, bid.UserId, ask.UserId, price, models.LedgerKindDeal, &deal.Id)
+			if errors.Is(err, ErrInsufficientFunds) {
+				return errBuyerCannotPay
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := orderRepo.AddFill(bid.Id, quantity); err != nil {
+			return err
+		}
+
+		return orderRepo.AddFill(ask.Id, quantity)
+	})
+}
+
+func (ser *OrderServiceImpl) cancelResting(book *orderBook, order *models.Order) {
+	err := ser.Repo.UpdateStatus(order.Id, models.OrderStatusOpen, models.OrderStatusCancelled)
+	if err != nil {
+		log.Printf("Error cancelling order %d: %v", order.Id, err)
+	}
+
+	order.Status = models.OrderStatusCancelled
+	book.remove(order)
+}
+
+func newer(a, b *models.Order) *models.Order {
+	if a.Id > b.Id {
+		return a
+	}
+	return b
+}
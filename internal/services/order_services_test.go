@@ -0,0 +1,77 @@
+package services
+
+import (
+	"testing"
+
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+	"market/web/handlers/middlewares"
+)
+
+// memoryOrders stores orders by id without any matching of its own.
+type memoryOrders struct {
+	repositories.OrderRepo
+	orders map[int]models.Order
+}
+
+func (repo *memoryOrders) Create(userId int, newOrder models.NewOrder) (models.Order, error) {
+	order := models.Order{
+		Id:       len(repo.orders) + 1,
+		UserId:   userId,
+		ItemName: newOrder.ItemName,
+		Side:     newOrder.Side,
+		Price:    newOrder.Price,
+		Quantity: newOrder.Quantity,
+		Status:   models.OrderStatusOpen,
+	}
+	repo.orders[order.Id] = order
+	return order, nil
+}
+
+func (repo *memoryOrders) Get(id int) (models.Order, error) {
+	return repo.orders[id], nil
+}
+
+func (repo *memoryOrders) UpdateStatus(id int, from string, to string) error {
+	order := repo.orders[id]
+	order.Status = to
+	repo.orders[id] = order
+	return nil
+}
+
+func TestOrderBooksAreDroppedWhenEmpty(t *testing.T) {
+	ser := &OrderServiceImpl{Repo: &memoryOrders{orders: map[int]models.Order{}}}
+
+	for _, name := range []string{"Sword", "Shield", "Bow"} {
+		book, err := ser.GetBook(name, models.DefaultCurrency)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(book.Bids) != 0 || len(book.Asks) != 0 {
+			t.Errorf("book for %s is not empty", name)
+		}
+	}
+	if len(ser.books) != 0 {
+		t.Errorf("reading created %d books, want none", len(ser.books))
+	}
+
+	order, err := ser.Submit(models.NewOrder{
+		ItemName: "Sword",
+		Side:     models.OrderSideBid,
+		Price:    models.Money{Amount: 100, Currency: models.DefaultCurrency},
+		Quantity: 1,
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ser.books) != 1 {
+		t.Fatalf("%d books after one order, want 1", len(ser.books))
+	}
+
+	if _, err := ser.Cancel(order.Id, &middlewares.Claims{UserId: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if len(ser.books) != 0 {
+		t.Errorf("%d books after cancelling the only order, want none", len(ser.books))
+	}
+}
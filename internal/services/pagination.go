@@ -0,0 +1,33 @@
+package services
+
+import (
+	"fmt"
+	"market/internal/database"
+	"market/internal/database/query"
+)
+
+// cursorInfo decodes the cursor token of a list request. An empty token
+// starts at the beginning. Keyset pagination follows the row id, so it
+// cannot be combined with another sort order.
+func cursorInfo(resource string, token string, size int, params query.Params) (database.CursorInfo, error) {
+	if size <= 0 {
+		return database.CursorInfo{}, fmt.Errorf("invalid pagination")
+	}
+
+	if params.Sort != "" {
+		return database.CursorInfo{}, &query.Error{Message: "sort cannot be combined with cursor pagination, which is ordered by id"}
+	}
+
+	info := database.CursorInfo{Size: size}
+	if token == "" {
+		return info, nil
+	}
+
+	cursor, err := database.DecodeCursor(token, resource)
+	if err != nil {
+		return database.CursorInfo{}, err
+	}
+	info.Cursor = &cursor
+
+	return info, nil
+}
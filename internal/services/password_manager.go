@@ -2,39 +2,197 @@ package services
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	PasswordAlgorithmArgon2id = "argon2id"
+	PasswordAlgorithmBcrypt   = "bcrypt"
+
+	// legacyBcryptPrefix marks hashes from before versioned hashing, which
+	// are bcrypt over password+salt with the old salt column moved inline:
+	// $bcrypt-salted$<salt>$<bcrypt hash>.
+	legacyBcryptPrefix = "$bcrypt-salted$"
+
+	// maxPasswordLength bounds the work a single login can cause.
+	maxPasswordLength = 1024
+)
+
+var (
+	ErrPasswordTooLong     = errors.New("password is too long")
+	ErrUnknownPasswordHash = errors.New("unknown password hash format")
+)
+
+type Argon2Params struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+// DefaultArgon2Params follow the OWASP recommendation for argon2id.
+var DefaultArgon2Params = Argon2Params{Memory: 19 * 1024, Time: 2, Threads: 1, KeyLen: 32, SaltLen: 16}
+
 type PasswordManager interface {
-	GenerateSalt() (string, error)
-	HashPassword(password, salt string) (string, error)
-	CheckPasswordHash(password, hash string) bool
+	HashPassword(password string) (string, error)
+	// CheckPassword reports whether password matches the encoded hash and
+	// whether the hash should be replaced because it uses an outdated
+	// algorithm or parameters.
+	CheckPassword(password, encoded string) (match bool, needsRehash bool)
+}
+
+// PasswordManagerImpl writes self-describing hashes: argon2id in the PHC
+// string format ($argon2id$v=19$m=...,t=...,p=...$salt$hash) or standard
+// bcrypt ($2a$cost$...). Both, and legacy salted bcrypt, can be checked
+// regardless of which algorithm new hashes use.
+type PasswordManagerImpl struct {
+	// Algorithm for new hashes, argon2id when empty.
+	Algorithm  string
+	Argon2     Argon2Params
+	BcryptCost int
 }
 
-type PasswordManagerImpl struct{}
+func (pass *PasswordManagerImpl) HashPassword(password string) (string, error) {
+	if len(password) > maxPasswordLength {
+		return "", ErrPasswordTooLong
+	}
 
-func (pass *PasswordManagerImpl) GenerateSalt() (string, error) {
-	salt := make([]byte, 16)
-	_, err := rand.Read(salt)
-	if err != nil {
+	if pass.algorithm() == PasswordAlgorithmBcrypt {
+		if len(password) > 72 {
+			return "", ErrPasswordTooLong
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), pass.bcryptCost())
+		return string(hashed), err
+	}
+
+	params := pass.argon2Params()
+	salt := make([]byte, params.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
 		return "", err
 	}
-	return base64.StdEncoding.EncodeToString(salt), nil
+
+	key := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, params.KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Time, params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
 }
 
-func (pass *PasswordManagerImpl) HashPassword(password, salt string) (string, error) {
-	combined := password + salt
-	if len([]byte(combined)) > 72 {
-		return "", fmt.Errorf("password is too long")
+func (pass *PasswordManagerImpl) CheckPassword(password, encoded string) (bool, bool) {
+	if len(password) > maxPasswordLength {
+		return false, false
+	}
+
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, false
+		}
+
+		actual := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(actual, key) != 1 {
+			return false, false
+		}
+
+		current := pass.argon2Params()
+		outdated := pass.algorithm() != PasswordAlgorithmArgon2id ||
+			params.Memory != current.Memory || params.Time != current.Time || params.Threads != current.Threads ||
+			uint32(len(key)) != current.KeyLen || uint32(len(salt)) != current.SaltLen
+		return true, outdated
+
+	case strings.HasPrefix(encoded, legacyBcryptPrefix):
+		salt, hash, ok := strings.Cut(strings.TrimPrefix(encoded, legacyBcryptPrefix), "$")
+		if !ok {
+			return false, false
+		}
+
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password+salt))
+		return err == nil, true
+
+	case strings.HasPrefix(encoded, "$2"):
+		if bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) != nil {
+			return false, false
+		}
+
+		cost, err := bcrypt.Cost([]byte(encoded))
+		outdated := err != nil || pass.algorithm() != PasswordAlgorithmBcrypt || cost != pass.bcryptCost()
+		return true, outdated
+
+	default:
+		return false, false
 	}
-	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(combined), bcrypt.DefaultCost)
-	return string(hashedBytes), err
 }
 
-func (pass *PasswordManagerImpl) CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+func (pass *PasswordManagerImpl) algorithm() string {
+	if pass.Algorithm == "" {
+		return PasswordAlgorithmArgon2id
+	}
+	return pass.Algorithm
+}
+
+func (pass *PasswordManagerImpl) argon2Params() Argon2Params {
+	params := pass.Argon2
+	if params.Memory == 0 {
+		params.Memory = DefaultArgon2Params.Memory
+	}
+	if params.Time == 0 {
+		params.Time = DefaultArgon2Params.Time
+	}
+	if params.Threads == 0 {
+		params.Threads = DefaultArgon2Params.Threads
+	}
+	if params.KeyLen == 0 {
+		params.KeyLen = DefaultArgon2Params.KeyLen
+	}
+	if params.SaltLen == 0 {
+		params.SaltLen = DefaultArgon2Params.SaltLen
+	}
+	return params
+}
+
+func (pass *PasswordManagerImpl) bcryptCost() int {
+	if pass.BcryptCost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return pass.BcryptCost
+}
+
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, ErrUnknownPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, ErrUnknownPasswordHash
+	}
+
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownPasswordHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownPasswordHash
+	}
+
+	return params, salt, key, nil
 }
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"market/internal/database"
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type RoleService interface {
+	GetAll() ([]models.Role, error)
+	GetUserRoles(userId int) (models.UserRoles, error)
+	SetUserRoles(userId int, roles models.UserRoles) (models.UserRoles, error)
+}
+
+type RoleServiceImpl struct {
+	Repo     repositories.RoleRepo
+	UserRepo repositories.UserRepo
+	Tx       database.Transactor
+}
+
+func (ser *RoleServiceImpl) GetAll() ([]models.Role, error) {
+	roles, err := ser.Repo.GetAll()
+	if err != nil {
+		log.Printf("Error retrieving roles: %v", err)
+		return nil, fmt.Errorf("failed to get roles")
+	}
+
+	rolePermissions, err := ser.Repo.GetRolePermissions()
+	if err != nil {
+		log.Printf("Error retrieving role permissions: %v", err)
+		return nil, fmt.Errorf("failed to get roles")
+	}
+
+	for i := range roles {
+		roles[i].Permissions = []string{}
+		for _, rolePermission := range rolePermissions {
+			if rolePermission.Role == roles[i].Name {
+				roles[i].Permissions = append(roles[i].Permissions, rolePermission.Permission)
+			}
+		}
+	}
+
+	return roles, nil
+}
+
+func (ser *RoleServiceImpl) GetUserRoles(userId int) (models.UserRoles, error) {
+	if _, err := ser.UserRepo.Get(userId); err != nil {
+		return models.UserRoles{}, fmt.Errorf("user not found")
+	}
+
+	roles, err := ser.Repo.GetUserRoles(userId)
+	if err != nil {
+		log.Printf("Error retrieving user roles: %v", err)
+		return models.UserRoles{}, fmt.Errorf("failed to get user roles")
+	}
+
+	return models.UserRoles{Roles: append([]string{}, roles...)}, nil
+}
+
+// SetUserRoles replaces the user's roles. The user's current tokens keep
+// their old permissions until they are refreshed.
+func (ser *RoleServiceImpl) SetUserRoles(userId int, roles models.UserRoles) (models.UserRoles, error) {
+	if _, err := ser.UserRepo.Get(userId); err != nil {
+		return models.UserRoles{}, fmt.Errorf("user not found")
+	}
+
+	known, err := ser.Repo.GetByNames(roles.Roles)
+	if err != nil {
+		log.Printf("Error retrieving roles: %v", err)
+		return models.UserRoles{}, fmt.Errorf("failed to set user roles")
+	}
+
+	roleIds := make([]int, 0, len(known))
+	names := make([]string, 0, len(known))
+	for _, role := range known {
+		roleIds = append(roleIds, role.Id)
+		names = append(names, role.Name)
+	}
+
+	for _, name := range roles.Roles {
+		found := false
+		for _, role := range known {
+			if role.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return models.UserRoles{}, fmt.Errorf("unknown role %q", name)
+		}
+	}
+
+	err = ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		return ser.Repo.WithTx(tx).SetUserRoles(userId, roleIds)
+	})
+	if err != nil {
+		log.Printf("Error setting user roles: %v", err)
+		return models.UserRoles{}, fmt.Errorf("failed to set user roles")
+	}
+
+	return models.UserRoles{Roles: names}, nil
+}
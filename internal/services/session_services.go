@@ -0,0 +1,269 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"market/internal/database"
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+	"market/web/handlers/middlewares"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jmoiron/sqlx"
+)
+
+const DefaultRefreshTokenTTL = 7 * 24 * time.Hour
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, session revoked")
+	ErrNoSession           = errors.New("token is not bound to a session")
+)
+
+type SessionService interface {
+	Start(user models.UserResponse, meta models.SessionMeta) (models.TokenPair, error)
+	Refresh(refreshToken string, meta models.SessionMeta) (models.TokenPair, error)
+	GetActive(claims *middlewares.Claims) ([]models.Session, error)
+	Logout(claims *middlewares.Claims) error
+	LogoutAll(claims *middlewares.Claims) error
+	RevokeUser(userId int) error
+}
+
+// SessionServiceImpl issues access tokens together with opaque refresh
+// tokens. Only a SHA-256 hash of each refresh token is stored. Every refresh
+// rotates the token within its family; presenting an already rotated token
+// means it leaked, so the whole family is revoked together with the access
+// tokens it issued that have not expired.
+type SessionServiceImpl struct {
+	Repo     repositories.SessionRepo
+	UserRepo repositories.UserRepo
+	RoleRepo repositories.RoleRepo
+	// Revocations rejects access tokens of ended sessions before they
+	// expire.
+	Revocations middlewares.RevocationStore
+	Tx          database.Transactor
+	TTL         time.Duration
+}
+
+func (ser *SessionServiceImpl) Start(user models.UserResponse, meta models.SessionMeta) (models.TokenPair, error) {
+	familyId, err := randomToken(16)
+	if err != nil {
+		log.Printf("Error generating session id: %v", err)
+		return models.TokenPair{}, fmt.Errorf("failed to start session")
+	}
+
+	return ser.issue(ser.Repo, user.Id, user.Username, familyId, meta)
+}
+
+func (ser *SessionServiceImpl) Refresh(refreshToken string, meta models.SessionMeta) (models.TokenPair, error) {
+	var pair models.TokenPair
+	var leaked []models.Session
+	reused := false
+
+	err := ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		repo := ser.Repo.WithTx(tx)
+
+		session, err := repo.GetByTokenHashForUpdate(hashToken(refreshToken))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			log.Printf("Error retrieving session: %v", err)
+			return fmt.Errorf("failed to refresh session")
+		}
+
+		if session.RevokedAt != nil || !session.ExpiresAt.After(time.Now()) {
+			return ErrInvalidRefreshToken
+		}
+
+		if session.RotatedAt != nil {
+			// The revocation has to be committed, so the error is reported
+			// only after the transaction ends.
+			reused = true
+			if err := repo.RevokeFamily(session.FamilyId); err != nil {
+				log.Printf("Error revoking session family: %v", err)
+				return fmt.Errorf("failed to refresh session")
+			}
+			leaked, err = repo.GetLiveAccessTokens(session.FamilyId)
+			if err != nil {
+				log.Printf("Error retrieving session family tokens: %v", err)
+				return fmt.Errorf("failed to refresh session")
+			}
+			return nil
+		}
+
+		user, err := ser.UserRepo.WithTx(tx).Get(session.UserId)
+		if err != nil {
+			log.Printf("Error retrieving session user: %v", err)
+			return ErrInvalidRefreshToken
+		}
+
+		if user.BannedAt != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		if err := repo.MarkRotated(session.Id); err != nil {
+			log.Printf("Error rotating session: %v", err)
+			return fmt.Errorf("failed to refresh session")
+		}
+
+		pair, err = ser.issue(repo, user.Id, user.Username, session.FamilyId, meta)
+		return err
+	})
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	if reused {
+		log.Printf("Refresh token reuse detected, session family revoked")
+		for _, session := range leaked {
+			if err := ser.Revocations.Revoke(session.AccessTokenId, *session.AccessExpiresAt); err != nil {
+				log.Printf("Error revoking access token: %v", err)
+				return models.TokenPair{}, fmt.Errorf("failed to refresh session")
+			}
+		}
+		return models.TokenPair{}, ErrRefreshTokenReused
+	}
+
+	return pair, nil
+}
+
+func (ser *SessionServiceImpl) GetActive(claims *middlewares.Claims) ([]models.Session, error) {
+	sessions, err := ser.Repo.GetActiveByUser(claims.UserId)
+	if err != nil {
+		log.Printf("Error retrieving sessions: %v", err)
+		return nil, fmt.Errorf("failed to get sessions")
+	}
+
+	return sessions, nil
+}
+
+// Logout revokes the session the access token was issued from, together
+// with the access token itself.
+func (ser *SessionServiceImpl) Logout(claims *middlewares.Claims) error {
+	if claims.SessionId == "" {
+		return ErrNoSession
+	}
+
+	if err := ser.Repo.RevokeFamily(claims.SessionId); err != nil {
+		log.Printf("Error revoking session: %v", err)
+		return fmt.Errorf("failed to log out")
+	}
+
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		if err := ser.Revocations.Revoke(claims.ID, claims.ExpiresAt.Time); err != nil {
+			log.Printf("Error revoking access token: %v", err)
+			return fmt.Errorf("failed to log out")
+		}
+	}
+
+	return nil
+}
+
+func (ser *SessionServiceImpl) LogoutAll(claims *middlewares.Claims) error {
+	return ser.RevokeUser(claims.UserId)
+}
+
+// RevokeUser ends every session of the user and rejects all access tokens
+// issued so far, e.g. after a password change or a ban.
+func (ser *SessionServiceImpl) RevokeUser(userId int) error {
+	if err := ser.Repo.RevokeAllForUser(userId); err != nil {
+		log.Printf("Error revoking sessions: %v", err)
+		return fmt.Errorf("failed to log out")
+	}
+
+	if err := ser.Revocations.RevokeUser(userId, time.Now()); err != nil {
+		log.Printf("Error revoking access tokens: %v", err)
+		return fmt.Errorf("failed to log out")
+	}
+
+	return nil
+}
+
+// issue stores a new refresh token in the family and signs an access token
+// bound to it.
+func (ser *SessionServiceImpl) issue(repo repositories.SessionRepo, userId int, username string, familyId string, meta models.SessionMeta) (models.TokenPair, error) {
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		log.Printf("Error generating refresh token: %v", err)
+		return models.TokenPair{}, fmt.Errorf("failed to issue tokens")
+	}
+
+	accessTokenId, err := randomToken(16)
+	if err != nil {
+		log.Printf("Error generating access token id: %v", err)
+		return models.TokenPair{}, fmt.Errorf("failed to issue tokens")
+	}
+
+	// The recorded expiry may run a moment past the token's own, which only
+	// keeps its revocation around slightly longer.
+	now := time.Now()
+	_, err = repo.Create(models.NewSession{
+		UserId:          userId,
+		FamilyId:        familyId,
+		TokenHash:       hashToken(refreshToken),
+		Meta:            meta,
+		ExpiresAt:       now.Add(ser.ttl()),
+		AccessTokenId:   accessTokenId,
+		AccessExpiresAt: now.Add(middlewares.AccessTokenTTL + time.Second),
+	})
+	if err != nil {
+		log.Printf("Error creating session: %v", err)
+		return models.TokenPair{}, fmt.Errorf("failed to issue tokens")
+	}
+
+	roles, err := ser.RoleRepo.GetUserRoles(userId)
+	if err != nil {
+		log.Printf("Error retrieving user roles: %v", err)
+		return models.TokenPair{}, fmt.Errorf("failed to issue tokens")
+	}
+
+	permissions, err := ser.RoleRepo.GetUserPermissions(userId)
+	if err != nil {
+		log.Printf("Error retrieving user permissions: %v", err)
+		return models.TokenPair{}, fmt.Errorf("failed to issue tokens")
+	}
+
+	token, err := middlewares.GenerateJWT(middlewares.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{ID: accessTokenId},
+		UserId:           userId,
+		Username:         username,
+		SessionId:        familyId,
+		Roles:            roles,
+		Permissions:      permissions,
+	})
+	if err != nil {
+		log.Printf("Error signing access token: %v", err)
+		return models.TokenPair{}, fmt.Errorf("failed to issue tokens")
+	}
+
+	return models.TokenPair{Token: token, RefreshToken: refreshToken}, nil
+}
+
+func (ser *SessionServiceImpl) ttl() time.Duration {
+	if ser.TTL <= 0 {
+		return DefaultRefreshTokenTTL
+	}
+	return ser.TTL
+}
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
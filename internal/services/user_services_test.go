@@ -0,0 +1,66 @@
+package services
+
+import (
+	"testing"
+
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+	"market/web/handlers/middlewares"
+)
+
+type plainPasswords struct{}
+
+func (plainPasswords) HashPassword(password string) (string, error) {
+	return password, nil
+}
+
+func (plainPasswords) CheckPassword(password, encoded string) (bool, bool) {
+	return password == encoded, false
+}
+
+type updatedUsers struct {
+	repositories.UserRepo
+}
+
+func (updatedUsers) Update(user models.User) error {
+	return nil
+}
+
+// revokedSessions and revokedKeys record whose credentials were revoked.
+type revokedSessions struct {
+	SessionService
+	users []int
+}
+
+func (sessions *revokedSessions) RevokeUser(userId int) error {
+	sessions.users = append(sessions.users, userId)
+	return nil
+}
+
+type revokedKeys struct {
+	repositories.APIKeyRepo
+	users []int
+}
+
+func (keys *revokedKeys) RevokeAllForUser(userId int) error {
+	keys.users = append(keys.users, userId)
+	return nil
+}
+
+func TestUpdateRevokesSessionsAndAPIKeys(t *testing.T) {
+	sessions := &revokedSessions{}
+	keys := &revokedKeys{}
+	ser := &UserServiceImpl{Repo: updatedUsers{}, Pass: plainPasswords{}, Sessions: sessions, APIKeys: keys}
+
+	user := models.User{Id: 7, Username: "alice", Password: "new password"}
+	if _, err := ser.Update(user, &middlewares.Claims{UserId: 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sessions.users) != 1 || sessions.users[0] != 7 {
+		t.Errorf("revoked sessions of %v, want [7]", sessions.users)
+	}
+	if len(keys.users) != 1 || keys.users[0] != 7 {
+		t.Errorf("revoked API keys of %v, want [7]", keys.users)
+	}
+}
@@ -2,8 +2,10 @@ package services
 
 import (
 	"fmt"
+	"log"
 	"market/internal/database"
 	"market/internal/database/models"
+	"market/internal/database/query"
 	"market/internal/database/repositories"
 	"market/web/handlers/middlewares"
 	"strings"
@@ -12,15 +14,21 @@ import (
 type UserService interface {
 	Create(newUser models.NewUser) (models.UserResponse, error)
 	Get(id int) (models.UserResponse, error)
-	GetAll(page database.PageInfo) ([]models.UserResponse, error)
+	GetAll(page database.PageInfo, params query.Params) (database.Page[models.UserResponse], error)
+	GetAllByCursor(cursor string, size int, params query.Params) (database.CursorPage[models.UserResponse], error)
 	Update(user models.User, claims *middlewares.Claims) (models.UserResponse, error)
 	Delete(id int, claims *middlewares.Claims) error
 	Authenticate(username, password string) (models.UserResponse, error)
+	Ban(id int, claims *middlewares.Claims) error
+	Unban(id int) error
 }
 
 type UserServiceImpl struct {
-	Repo repositories.UserRepo
-	Pass PasswordManager
+	Repo     repositories.UserRepo
+	Pass     PasswordManager
+	Sessions SessionService
+	Accounts AccountService
+	APIKeys  repositories.APIKeyRepo
 }
 
 type UserContext struct {
@@ -33,14 +41,7 @@ func (ser *UserServiceImpl) Create(newUser models.NewUser) (models.UserResponse,
 		return models.UserResponse{}, fmt.Errorf("invalid user name")
 	}
 
-	salt, err := ser.Pass.GenerateSalt()
-	if err != nil {
-		return models.UserResponse{}, fmt.Errorf("failed to generate salt")
-	}
-
-	newUser.Salt = salt
-
-	hashedPassword, err := ser.Pass.HashPassword(newUser.Password, newUser.Salt)
+	hashedPassword, err := ser.Pass.HashPassword(newUser.Password)
 	if err != nil {
 		return models.UserResponse{}, fmt.Errorf("failed to hash password")
 	}
@@ -51,6 +52,12 @@ func (ser *UserServiceImpl) Create(newUser models.NewUser) (models.UserResponse,
 		return models.UserResponse{}, fmt.Errorf("failed to create user")
 	}
 
+	// Registration succeeds even if the mail fails; the user can ask for
+	// another verification email.
+	if err := ser.Accounts.SendVerification(createdUser.Id); err != nil {
+		log.Printf("Error sending verification email: %v", err)
+	}
+
 	return createdUser.ToResponse(), nil
 }
 
@@ -67,14 +74,24 @@ func (ser *UserServiceImpl) Get(id int) (models.UserResponse, error) {
 	return user.ToResponse(), nil
 }
 
-func (ser *UserServiceImpl) GetAll(page database.PageInfo) ([]models.UserResponse, error) {
-	if page.PageNumber <= 0 || page.PageSize < 0 {
-		return nil, fmt.Errorf("invalid pagination")
+func (ser *UserServiceImpl) GetAll(page database.PageInfo, params query.Params) (database.Page[models.UserResponse], error) {
+	if page.PageNumber <= 0 || page.PageSize <= 0 {
+		return database.Page[models.UserResponse]{}, fmt.Errorf("invalid pagination")
 	}
 
-	users, err := ser.Repo.GetAll(page)
+	q, err := query.Parse(repositories.UserQueryFields, params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get users")
+		return database.Page[models.UserResponse]{}, err
+	}
+
+	users, err := ser.Repo.GetAll(page, q)
+	if err != nil {
+		return database.Page[models.UserResponse]{}, fmt.Errorf("failed to get users")
+	}
+
+	total, err := ser.Repo.Count(q)
+	if err != nil {
+		return database.Page[models.UserResponse]{}, fmt.Errorf("failed to get users")
 	}
 
 	userResponses := make([]models.UserResponse, len(users))
@@ -82,11 +99,37 @@ func (ser *UserServiceImpl) GetAll(page database.PageInfo) ([]models.UserRespons
 		userResponses[i] = user.ToResponse()
 	}
 
-	return userResponses, nil
+	return database.NewPage(userResponses, total, page), nil
+}
+
+func (ser *UserServiceImpl) GetAllByCursor(cursor string, size int, params query.Params) (database.CursorPage[models.UserResponse], error) {
+	info, err := cursorInfo("users", cursor, size, params)
+	if err != nil {
+		return database.CursorPage[models.UserResponse]{}, err
+	}
+
+	q, err := query.Parse(repositories.UserQueryFields, params)
+	if err != nil {
+		return database.CursorPage[models.UserResponse]{}, err
+	}
+
+	users, err := ser.Repo.GetAllByCursor(info, q)
+	if err != nil {
+		return database.CursorPage[models.UserResponse]{}, fmt.Errorf("failed to get users")
+	}
+
+	userResponses := make([]models.UserResponse, len(users))
+	for i, user := range users {
+		userResponses[i] = user.ToResponse()
+	}
+
+	return database.NewCursorPage("users", userResponses, info, func(user models.UserResponse) int64 {
+		return int64(user.Id)
+	}), nil
 }
 
 func (ser *UserServiceImpl) Update(user models.User, claims *middlewares.Claims) (models.UserResponse, error) {
-	if user.Id != claims.UserId {
+	if user.Id != claims.UserId && !claims.HasPermission(models.PermUsersUpdateAny) {
 		return models.UserResponse{}, fmt.Errorf("not authorized to update this user")
 	}
 
@@ -94,7 +137,7 @@ func (ser *UserServiceImpl) Update(user models.User, claims *middlewares.Claims)
 		return models.UserResponse{}, fmt.Errorf("name cannot be empty")
 	}
 
-	hashedPassword, err := ser.Pass.HashPassword(user.Password, user.Salt)
+	hashedPassword, err := ser.Pass.HashPassword(user.Password)
 	if err != nil {
 		return models.UserResponse{}, fmt.Errorf("failed to hash password")
 	}
@@ -107,11 +150,22 @@ func (ser *UserServiceImpl) Update(user models.User, claims *middlewares.Claims)
 		return models.UserResponse{}, fmt.Errorf("failed to update user: %w", err)
 	}
 
+	// The password was replaced, so sessions started with the old one end,
+	// and so do API keys created with it.
+	if err := ser.Sessions.RevokeUser(user.Id); err != nil {
+		return models.UserResponse{}, err
+	}
+
+	if err := ser.APIKeys.RevokeAllForUser(user.Id); err != nil {
+		log.Printf("Error revoking API keys: %v", err)
+		return models.UserResponse{}, fmt.Errorf("failed to revoke API keys")
+	}
+
 	return user.ToResponse(), nil
 }
 
 func (ser *UserServiceImpl) Delete(id int, claims *middlewares.Claims) error {
-	if id != claims.UserId {
+	if id != claims.UserId && !claims.HasPermission(models.PermUsersDeleteAny) {
 		return fmt.Errorf("not authorized to delete this user")
 	}
 
@@ -125,13 +179,59 @@ func (ser *UserServiceImpl) Authenticate(username, password string) (models.User
 		return models.UserResponse{}, fmt.Errorf("user not found")
 	}
 
-	if !ser.Pass.CheckPasswordHash(password+user.Salt, user.Password) {
+	match, needsRehash := ser.Pass.CheckPassword(password, user.Password)
+	if !match {
 		return models.UserResponse{}, fmt.Errorf("invalid password")
 	}
 
+	if user.BannedAt != nil {
+		return models.UserResponse{}, fmt.Errorf("user is banned")
+	}
+
+	// Upgrade hashes made with an older algorithm or cost while the plain
+	// password is at hand. Failing to do so does not block the login.
+	if needsRehash {
+		if hashedPassword, err := ser.Pass.HashPassword(password); err == nil {
+			if err := ser.Repo.UpdatePassword(user.Id, hashedPassword); err != nil {
+				log.Printf("Error rehashing password: %v", err)
+			}
+		}
+	}
+
 	return models.UserResponse{Id: user.Id, Username: user.Username}, nil
 }
 
+// Ban blocks the user from logging in and revokes all of their tokens.
+func (ser *UserServiceImpl) Ban(id int, claims *middlewares.Claims) error {
+	if id == claims.UserId {
+		return fmt.Errorf("you cannot ban yourself")
+	}
+
+	if _, err := ser.Repo.Get(id); err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	if err := ser.Repo.SetBanned(id, true); err != nil {
+		log.Printf("Error banning user: %v", err)
+		return fmt.Errorf("failed to ban user")
+	}
+
+	return ser.Sessions.RevokeUser(id)
+}
+
+func (ser *UserServiceImpl) Unban(id int) error {
+	if _, err := ser.Repo.Get(id); err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	if err := ser.Repo.SetBanned(id, false); err != nil {
+		log.Printf("Error unbanning user: %v", err)
+		return fmt.Errorf("failed to unban user")
+	}
+
+	return nil
+}
+
 func fixUserName(username string) string {
 	username = strings.ReplaceAll(username, "  ", " ")
 	username = strings.ReplaceAll(username, "\t", "")
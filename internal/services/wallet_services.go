@@ -0,0 +1,202 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"market/internal/database"
+	"market/internal/database/models"
+	"market/internal/database/repositories"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+type WalletService interface {
+	Get(userId int, currency string) (models.Wallet, error)
+	GetHistory(userId int, currency string, page database.PageInfo) (database.Page[models.LedgerEntry], error)
+	Deposit(userId int, amount models.Money) (models.Wallet, error)
+	Withdraw(userId int, amount models.Money) (models.Wallet, error)
+	Transfer(fromUserId, toUserId int, amount models.Money) (models.Wallet, error)
+}
+
+type WalletServiceImpl struct {
+	Repo repositories.WalletRepo
+	Tx   database.Transactor
+}
+
+func (ser *WalletServiceImpl) Get(userId int, currency string) (models.Wallet, error) {
+	if err := models.ValidateCurrency(currency); err != nil {
+		return models.Wallet{}, fmt.Errorf("unsupported currency")
+	}
+
+	wallet, err := ser.Repo.GetByUser(userId, currency)
+	if err != nil {
+		log.Printf("Error retrieving wallet: %v", err)
+		return models.Wallet{}, fmt.Errorf("failed to get wallet")
+	}
+
+	return wallet, nil
+}
+
+func (ser *WalletServiceImpl) GetHistory(userId int, currency string, page database.PageInfo) (database.Page[models.LedgerEntry], error) {
+	if page.PageNumber <= 0 || page.PageSize <= 0 {
+		return database.Page[models.LedgerEntry]{}, fmt.Errorf("invalid pagination")
+	}
+
+	wallet, err := ser.Get(userId, currency)
+	if err != nil {
+		return database.Page[models.LedgerEntry]{}, err
+	}
+
+	entries, err := ser.Repo.GetEntries(wallet.Id, page)
+	if err != nil {
+		log.Printf("Error retrieving ledger entries: %v", err)
+		return database.Page[models.LedgerEntry]{}, fmt.Errorf("failed to get wallet history")
+	}
+
+	total, err := ser.Repo.CountEntries(wallet.Id)
+	if err != nil {
+		log.Printf("Error counting ledger entries: %v", err)
+		return database.Page[models.LedgerEntry]{}, fmt.Errorf("failed to get wallet history")
+	}
+
+	return database.NewPage(entries, total, page), nil
+}
+
+func (ser *WalletServiceImpl) Deposit(userId int, amount models.Money) (models.Wallet, error) {
+	if err := validateAmount(amount); err != nil {
+		return models.Wallet{}, err
+	}
+
+	return ser.moveExternal(userId, amount, models.LedgerKindDeposit)
+}
+
+func (ser *WalletServiceImpl) Withdraw(userId int, amount models.Money) (models.Wallet, error) {
+	if err := validateAmount(amount); err != nil {
+		return models.Wallet{}, err
+	}
+
+	return ser.moveExternal(userId, amount.Neg(), models.LedgerKindWithdrawal)
+}
+
+func (ser *WalletServiceImpl) Transfer(fromUserId, toUserId int, amount models.Money) (models.Wallet, error) {
+	if err := validateAmount(amount); err != nil {
+		return models.Wallet{}, err
+	}
+
+	if fromUserId == toUserId {
+		return models.Wallet{}, fmt.Errorf("cannot transfer to yourself")
+	}
+
+	var wallet models.Wallet
+	err := ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		repo := ser.Repo.WithTx(tx)
+
+		err := postTransfer(repo, fromUserId, toUserId, amount, models.LedgerKindTransfer, nil)
+		if err != nil {
+			return err
+		}
+
+		wallet, err = repo.GetByUser(fromUserId, amount.Currency)
+		return err
+	})
+	if err != nil {
+		return models.Wallet{}, err
+	}
+
+	return wallet, nil
+}
+
+// moveExternal posts amount between the user's wallet and the external
+// wallet; a positive amount credits the user.
+func (ser *WalletServiceImpl) moveExternal(userId int, amount models.Money, kind string) (models.Wallet, error) {
+	var wallet models.Wallet
+	err := ser.Tx.WithTx(func(tx *sqlx.Tx) error {
+		repo := ser.Repo.WithTx(tx)
+
+		userWallet, err := repo.GetByUser(userId, amount.Currency)
+		if err != nil {
+			log.Printf("Error retrieving wallet: %v", err)
+			return fmt.Errorf("failed to get wallet")
+		}
+
+		external, err := repo.GetSystem(models.WalletKindExternal, amount.Currency)
+		if err != nil {
+			log.Printf("Error retrieving external wallet: %v", err)
+			return fmt.Errorf("failed to get wallet")
+		}
+
+		_, err = repo.Post([]models.NewLedgerEntry{
+			{WalletId: userWallet.Id, Amount: amount, Kind: kind},
+			{WalletId: external.Id, Amount: amount.Neg(), Kind: kind},
+		})
+		if err := ledgerError(err); err != nil {
+			return err
+		}
+
+		wallet, err = repo.GetByUser(userId, amount.Currency)
+		return err
+	})
+	if err != nil {
+		return models.Wallet{}, err
+	}
+
+	return wallet, nil
+}
+
+// postTransfer moves amount between two users' wallets through the ledger.
+// The repo must be bound to a transaction.
+func postTransfer(repo repositories.WalletRepo, fromUserId, toUserId int, amount models.Money, kind string, dealId *int) error {
+	from, err := repo.GetByUser(fromUserId, amount.Currency)
+	if err != nil {
+		log.Printf("Error retrieving wallet: %v", err)
+		return fmt.Errorf("failed to get wallet")
+	}
+
+	to, err := repo.GetByUser(toUserId, amount.Currency)
+	if err != nil {
+		log.Printf("Error retrieving wallet: %v", err)
+		return fmt.Errorf("failed to get wallet")
+	}
+
+	_, err = postBetween(repo, from, to, amount, kind, dealId)
+	return err
+}
+
+// postBetween moves amount from one wallet to another through the ledger and
+// returns the ledger transaction id. The repo must be bound to a transaction.
+func postBetween(repo repositories.WalletRepo, from, to models.Wallet, amount models.Money, kind string, dealId *int) (int64, error) {
+	transactionId, err := repo.Post([]models.NewLedgerEntry{
+		{WalletId: from.Id, Amount: amount.Neg(), Kind: kind, DealId: dealId},
+		{WalletId: to.Id, Amount: amount, Kind: kind, DealId: dealId},
+	})
+
+	return transactionId, ledgerError(err)
+}
+
+func validateAmount(amount models.Money) error {
+	if err := amount.Validate(); err != nil {
+		return fmt.Errorf("unsupported currency")
+	}
+
+	if !amount.IsPositive() {
+		return fmt.Errorf("amount must be positive")
+	}
+
+	return nil
+}
+
+func ledgerError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, repositories.ErrInsufficientFunds) {
+		return ErrInsufficientFunds
+	}
+
+	log.Printf("Error posting ledger entries: %v", err)
+	return fmt.Errorf("failed to transfer funds")
+}
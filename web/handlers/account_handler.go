@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"market/internal/database/models"
+	"market/internal/services"
+	"market/web/handlers/middlewares"
+
+	"github.com/labstack/echo/v4"
+)
+
+type AccountHandler struct {
+	Service services.AccountService
+}
+
+func (h *AccountHandler) ForgotPassword(c echo.Context) error {
+	var request models.ForgotPassword
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.Service.ForgotPassword(request); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusAccepted, "If the email is registered, a reset link has been sent")
+}
+
+func (h *AccountHandler) ResetPassword(c echo.Context) error {
+	var request models.ResetPassword
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.Service.ResetPassword(request); err != nil {
+		return c.JSON(accountErrorStatus(err), err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *AccountHandler) VerifyEmail(c echo.Context) error {
+	var request models.VerifyEmail
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.Service.VerifyEmail(request); err != nil {
+		return c.JSON(accountErrorStatus(err), err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *AccountHandler) ResendVerification(c echo.Context) error {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	if err := h.Service.SendVerification(claims.UserId); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func accountErrorStatus(err error) int {
+	if errors.Is(err, services.ErrInvalidAccountToken) || errors.Is(err, services.ErrEmptyPassword) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
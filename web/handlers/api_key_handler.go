@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"market/internal/database/models"
+	"market/internal/services"
+	"market/web/handlers/middlewares"
+
+	"github.com/labstack/echo/v4"
+)
+
+type APIKeyHandler struct {
+	Service services.APIKeyService
+}
+
+func (h *APIKeyHandler) CreateAPIKey(c echo.Context) error {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	var newKey models.NewAPIKey
+	if err := c.Bind(&newKey); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	key, err := h.Service.Create(newKey, claims)
+	if err != nil {
+		return c.JSON(apiKeyErrorStatus(err), err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, key)
+}
+
+func (h *APIKeyHandler) GetAPIKeys(c echo.Context) error {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	keys, err := h.Service.GetAll(claims)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid API key ID")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	if err := h.Service.Revoke(id, claims); err != nil {
+		return c.JSON(apiKeyErrorStatus(err), err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func apiKeyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrAPIKeyName), errors.Is(err, services.ErrAPIKeyScope),
+		errors.Is(err, services.ErrAPIKeyNoScopes), errors.Is(err, services.ErrAPIKeyExpiry):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"market/internal/database/models"
+	"market/internal/services"
+	"market/web/handlers/middlewares"
+
+	"github.com/labstack/echo/v4"
+)
+
+type AuctionHandler struct {
+	Service services.AuctionService
+}
+
+func (h *AuctionHandler) CreateAuction(c echo.Context) error {
+	var newAuction models.NewAuction
+	if err := c.Bind(&newAuction); err != nil {
+		log.Printf("Invalid input data: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid input data")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	auction, err := h.Service.Create(newAuction, claims.UserId)
+	if err != nil {
+		log.Printf("Error creating auction: %v", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, auction)
+}
+
+func (h *AuctionHandler) GetAuction(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("Invalid auction ID: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid auction ID")
+	}
+
+	auction, err := h.Service.Get(id)
+	if err != nil {
+		log.Printf("Auction not found: %v", err)
+		return echo.NewHTTPError(http.StatusNotFound, "Auction not found")
+	}
+
+	return c.JSON(http.StatusOK, auction)
+}
+
+func (h *AuctionHandler) GetAuctions(c echo.Context) error {
+	page, err := pageInfo(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	auctions, err := h.Service.GetOpen(page)
+	if err != nil {
+		log.Printf("Error retrieving auctions: %v", err)
+		return c.JSON(http.StatusInternalServerError, "Error retrieving auctions")
+	}
+
+	setPageLinks(c, auctions)
+	return c.JSON(http.StatusOK, auctions)
+}
+
+func (h *AuctionHandler) PlaceBid(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("Invalid auction ID: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid auction ID")
+	}
+
+	var request models.BidRequest
+	if err := c.Bind(&request); err != nil {
+		log.Printf("Invalid input data: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid input data")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	bid, err := h.Service.PlaceBid(id, request.Amount, claims.UserId)
+	if err != nil {
+		log.Printf("Error placing bid: %v", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, bid)
+}
+
+func (h *AuctionHandler) GetBids(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("Invalid auction ID: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid auction ID")
+	}
+
+	page, err := pageInfo(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	bids, err := h.Service.GetBids(id, page)
+	if err != nil {
+		log.Printf("Error retrieving bids: %v", err)
+		return c.JSON(http.StatusInternalServerError, "Error retrieving bids")
+	}
+
+	setPageLinks(c, bids)
+	return c.JSON(http.StatusOK, bids)
+}
@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"market/internal/database/models"
 	"market/internal/services"
@@ -11,7 +15,10 @@ import (
 )
 
 type AuthHandler struct {
-	Service services.UserService
+	Service  services.UserService
+	Sessions services.SessionService
+	MFA      services.MFAService
+	Guard    services.LoginGuard
 }
 
 func (h *AuthHandler) Login(c echo.Context) error {
@@ -21,25 +28,67 @@ func (h *AuthHandler) Login(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
 
+	ip := c.RealIP()
+	if err := h.Guard.Attempt(loginUser.Username, ip); err != nil {
+		return loginBlocked(c, err)
+	}
+
 	user, err := h.Service.Authenticate(loginUser.Username, loginUser.Password)
 	if err != nil {
+		if err := h.Guard.Failure(loginUser.Username, ip); err != nil {
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
 		return c.JSON(http.StatusUnauthorized, "Invalid username or password")
 	}
 
-	tokenString, err := middlewares.GenerateJWT(user.Id, user.Username, false)
+	// With two-factor authentication the login only succeeds with the code,
+	// so failures are kept until then.
+	challenge, required, err := h.MFA.Challenge(user)
+	if err != nil || required {
+		if err := h.Guard.Release(loginUser.Username, ip); err != nil {
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
+	if required {
+		return c.JSON(http.StatusOK, challenge)
+	}
+
+	if err := h.Guard.Success(loginUser.Username, ip); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
 
-	refreshToken, err := middlewares.GenerateJWT(user.Id, user.Username, true)
+	tokens, err := h.Sessions.Start(user, sessionMeta(c))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{
-		"token":         tokenString,
-		"refresh_token": refreshToken,
-	})
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// LoginMFA finishes a login that returned an MFA challenge.
+func (h *AuthHandler) LoginMFA(c echo.Context) error {
+	var login models.MFALogin
+	if err := c.Bind(&login); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	user, err := h.MFA.CompleteLogin(login, c.RealIP())
+	if errors.Is(err, services.ErrLoginBlocked) {
+		return loginBlocked(c, err)
+	}
+	if err != nil {
+		return c.JSON(mfaErrorStatus(err), err.Error())
+	}
+
+	tokens, err := h.Sessions.Start(user, sessionMeta(c))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, tokens)
 }
 
 func (h *AuthHandler) RefreshToken(c echo.Context) error {
@@ -51,21 +100,81 @@ func (h *AuthHandler) RefreshToken(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, "Invalid request")
 	}
 
-	claims, err := middlewares.GetValidatedClaims(tokenReq.RefreshToken)
+	tokens, err := h.Sessions.Refresh(tokenReq.RefreshToken, sessionMeta(c))
+	if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, "Invalid refresh token")
+		return c.JSON(http.StatusInternalServerError, "Could not generate new token")
 	}
 
-	if !claims.Refresh {
-		return c.JSON(http.StatusUnauthorized, "Invalid refresh token")
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func (h *AuthHandler) GetSessions(c echo.Context) error {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
 	}
 
-	newToken, err := middlewares.GenerateJWT(claims.UserId, claims.Username, false)
+	sessions, err := h.Sessions.GetActive(claims)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, "Could not generate new token")
+		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{
-		"token": newToken,
-	})
+	return c.JSON(http.StatusOK, sessions)
+}
+
+func (h *AuthHandler) Logout(c echo.Context) error {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	err = h.Sessions.Logout(claims)
+	if errors.Is(err, services.ErrNoSession) {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *AuthHandler) LogoutAll(c echo.Context) error {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	if err := h.Sessions.LogoutAll(claims); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func loginBlocked(c echo.Context, err error) error {
+	var blocked *services.LoginBlockedError
+	if !errors.As(err, &blocked) {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+	return c.JSON(http.StatusTooManyRequests, blocked.Error())
+}
+
+func sessionMeta(c echo.Context) models.SessionMeta {
+	return models.SessionMeta{
+		UserAgent: truncate(c.Request().UserAgent(), 255),
+		IpAddress: c.RealIP(),
+	}
+}
+
+func truncate(value string, max int) string {
+	if len(value) > max {
+		return strings.ToValidUTF8(value[:max], "")
+	}
+	return value
 }
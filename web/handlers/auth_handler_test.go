@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"market/internal/database/models"
+	"market/internal/services"
+	"market/web/handlers/middlewares"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// sessionsStub answers GetActive with one session of the current user, so a
+// 200 proves the handler saw the claims from the token.
+type sessionsStub struct {
+	services.SessionService
+}
+
+func (stub sessionsStub) GetActive(claims *middlewares.Claims) ([]models.Session, error) {
+	return []models.Session{{UserId: claims.UserId}}, nil
+}
+
+func newAuthTestServer(t *testing.T) *echo.Echo {
+	t.Helper()
+
+	keys, err := middlewares.GenerateKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	middlewares.SetKeySet(keys)
+	middlewares.SetTokenConfig(middlewares.TokenConfig{
+		Issuer:   "market-api",
+		Audience: []string{"market-api"},
+		Leeway:   middlewares.DefaultLeeway,
+	})
+	middlewares.SetRevocationStore(middlewares.NewMemoryRevocationStore())
+
+	handler := &AuthHandler{Sessions: sessionsStub{}}
+
+	e := echo.New()
+	auth := e.Group("/auth", middlewares.JWTMiddleware)
+	auth.GET("/sessions", handler.GetSessions)
+	return e
+}
+
+func getSessions(e *echo.Echo, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/auth/sessions", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func tokenErrorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var body middlewares.TokenError
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+	return body.Code
+}
+
+// expiredToken signs an access token that expired an hour ago, well past
+// the leeway.
+func expiredToken(t *testing.T, keys *middlewares.KeySet) string {
+	t.Helper()
+
+	key, err := keys.Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issued := time.Now().Add(-2 * time.Hour)
+	claims := middlewares.Claims{
+		UserId:   7,
+		Username: "alice",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "expired",
+			Issuer:    "market-api",
+			Audience:  jwt.ClaimStrings{"market-api"},
+			IssuedAt:  jwt.NewNumericDate(issued),
+			NotBefore: jwt.NewNumericDate(issued),
+			ExpiresAt: jwt.NewNumericDate(issued.Add(time.Hour)),
+		},
+	}
+
+	token := jwt.NewWithClaims(key.Method, &claims)
+	token.Header["kid"] = key.Id
+	signed, err := token.SignedString(key.Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestJWTMiddlewareValidToken(t *testing.T) {
+	e := newAuthTestServer(t)
+
+	token, err := middlewares.GenerateJWT(middlewares.Claims{UserId: 7, Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := getSessions(e, token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var sessions []models.Session
+	if err := json.Unmarshal(rec.Body.Bytes(), &sessions); err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].UserId != 7 {
+		t.Fatalf("sessions = %+v, want one of user 7", sessions)
+	}
+}
+
+func TestJWTMiddlewareRejectsTokens(t *testing.T) {
+	e := newAuthTestServer(t)
+
+	keys, err := middlewares.GenerateKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	middlewares.SetKeySet(keys)
+
+	challenge, err := middlewares.GenerateMFAChallenge(middlewares.Claims{UserId: 7, Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		code  string
+	}{
+		{"missing", "", middlewares.ErrTokenMissing.Code},
+		{"expired", expiredToken(t, keys), middlewares.ErrTokenExpired.Code},
+		{"wrong type", challenge, middlewares.ErrTokenWrongType.Code},
+		{"malformed", "not-a-token", middlewares.ErrTokenMalformed.Code},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := getSessions(e, test.token)
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+			}
+			if code := tokenErrorCode(t, rec); code != test.code {
+				t.Fatalf("code = %q, want %q", code, test.code)
+			}
+		})
+	}
+}
+
+func TestCurrentUserWithoutMiddleware(t *testing.T) {
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+	if _, err := middlewares.CurrentUser(c); err != middlewares.ErrNoCurrentUser {
+		t.Fatalf("err = %v, want %v", err, middlewares.ErrNoCurrentUser)
+	}
+}
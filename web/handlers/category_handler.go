@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"market/internal/database/models"
+	"market/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type CategoryHandler struct {
+	Service services.CategoryService
+}
+
+func (h *CategoryHandler) GetCategories(c echo.Context) error {
+	categories, err := h.Service.GetAll()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, categories)
+}
+
+func (h *CategoryHandler) GetCategory(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid category ID")
+	}
+
+	category, err := h.Service.Get(id)
+	if err != nil {
+		return c.JSON(categoryErrorStatus(err), err.Error())
+	}
+
+	return c.JSON(http.StatusOK, category)
+}
+
+func (h *CategoryHandler) CreateCategory(c echo.Context) error {
+	var category models.NewCategory
+	if err := c.Bind(&category); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	created, err := h.Service.Create(category)
+	if err != nil {
+		return c.JSON(categoryErrorStatus(err), err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, created)
+}
+
+func (h *CategoryHandler) UpdateCategory(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid category ID")
+	}
+
+	var category models.NewCategory
+	if err := c.Bind(&category); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	category.Id = id
+
+	updated, err := h.Service.Update(category)
+	if err != nil {
+		return c.JSON(categoryErrorStatus(err), err.Error())
+	}
+
+	return c.JSON(http.StatusOK, updated)
+}
+
+func (h *CategoryHandler) DeleteCategory(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid category ID")
+	}
+
+	if err := h.Service.Delete(id); err != nil {
+		return c.JSON(categoryErrorStatus(err), err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func categoryErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrCategoryNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidCategory):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrCategoryHasChildren):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
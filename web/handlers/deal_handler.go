@@ -1,12 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 
 	"market/internal/database"
 	"market/internal/database/models"
+	"market/internal/database/query"
 	"market/internal/services"
 	"market/web/handlers/middlewares"
 
@@ -24,12 +26,12 @@ func (h *DealHandler) CreateDeal(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, "Invalid input data")
 	}
 
-	userId, ok := c.Get("userId").(int)
-	if !ok {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
 		return c.JSON(http.StatusUnauthorized, "Unauthorized")
 	}
 
-	createdDeal, err := h.Service.Create(newDeal, userId)
+	createdDeal, err := h.Service.Create(newDeal, claims.UserId)
 	if err != nil {
 		log.Printf("Error creating deal: %v", err)
 		return c.JSON(http.StatusBadRequest, "Error creating deal")
@@ -55,27 +57,35 @@ func (h *DealHandler) GetDeal(c echo.Context) error {
 }
 
 func (h *DealHandler) GetDeals(c echo.Context) error {
-	pageNum, err := strconv.Atoi(c.QueryParam("page"))
-	if err != nil || pageNum <= 0 {
-		pageNum = 1
+	page, err := pageInfo(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
 	}
 
-	pageSize, err := strconv.Atoi(c.QueryParam("size"))
-	if err != nil || pageSize <= 0 {
-		pageSize = 10
+	if cursor, ok := cursorParam(c); ok {
+		deals, err := h.Service.GetAllByCursor(cursor, page.PageSize, queryParams(c))
+		if errors.Is(err, query.ErrInvalidQuery) || errors.Is(err, database.ErrInvalidCursor) {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		if err != nil {
+			log.Printf("Error retrieving deals: %v", err)
+			return c.JSON(http.StatusInternalServerError, "Error retrieving deals")
+		}
+
+		setCursorLinks(c, deals)
+		return c.JSON(http.StatusOK, deals)
 	}
 
-	page := database.PageInfo{
-		PageNumber: pageNum,
-		PageSize:   pageSize,
+	deals, err := h.Service.GetAll(page, queryParams(c))
+	if errors.Is(err, query.ErrInvalidQuery) {
+		return c.JSON(http.StatusBadRequest, err.Error())
 	}
-
-	deals, err := h.Service.GetAll(page)
 	if err != nil {
 		log.Printf("Error retrieving deals: %v", err)
 		return c.JSON(http.StatusInternalServerError, "Error retrieving deals")
 	}
 
+	setPageLinks(c, deals)
 	return c.JSON(http.StatusOK, deals)
 }
 
@@ -86,8 +96,8 @@ func (h *DealHandler) UpdateDeal(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, "Invalid input data")
 	}
 
-	claims, ok := c.Get("userClaims").(*middlewares.Claims)
-	if !ok {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
 		return c.JSON(http.StatusUnauthorized, "You don't have rights")
 	}
 
@@ -107,8 +117,8 @@ func (h *DealHandler) DeleteDeal(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, "Invalid deal ID")
 	}
 
-	claims, ok := c.Get("userClaims").(*middlewares.Claims)
-	if !ok {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
 		return c.JSON(http.StatusUnauthorized, "You don't have rights")
 	}
 
@@ -119,3 +129,99 @@ func (h *DealHandler) DeleteDeal(c echo.Context) error {
 
 	return c.NoContent(http.StatusOK)
 }
+
+func (h *DealHandler) GetDealEvents(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("Invalid deal ID: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid deal ID")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "You don't have rights")
+	}
+
+	events, err := h.Service.GetEvents(id, claims)
+	if errors.Is(err, services.ErrDealForbidden) {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+	if err != nil {
+		log.Printf("Error retrieving deal events: %v", err)
+		return c.JSON(http.StatusNotFound, "Deal not found")
+	}
+
+	return c.JSON(http.StatusOK, events)
+}
+
+func (h *DealHandler) AcceptDeal(c echo.Context) error {
+	return h.transition(c, models.DealStatusAccepted)
+}
+
+func (h *DealHandler) PayDeal(c echo.Context) error {
+	return h.transition(c, models.DealStatusPaid)
+}
+
+func (h *DealHandler) CancelDeal(c echo.Context) error {
+	return h.transition(c, models.DealStatusCancelled)
+}
+
+func (h *DealHandler) CompleteDeal(c echo.Context) error {
+	return h.transition(c, models.DealStatusCompleted)
+}
+
+func (h *DealHandler) DisputeDeal(c echo.Context) error {
+	return h.transition(c, models.DealStatusDisputed)
+}
+
+func (h *DealHandler) ResolveDeal(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("Invalid deal ID: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid deal ID")
+	}
+
+	var resolution models.DealResolution
+	if err := c.Bind(&resolution); err != nil {
+		log.Printf("Invalid input data: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid input data")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "You don't have rights")
+	}
+
+	deal, err := h.Service.Resolve(id, resolution.Status, claims)
+	return h.transitionResult(c, deal, err)
+}
+
+func (h *DealHandler) transition(c echo.Context, status string) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("Invalid deal ID: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid deal ID")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "You don't have rights")
+	}
+
+	deal, err := h.Service.Transition(id, status, claims)
+	return h.transitionResult(c, deal, err)
+}
+
+func (h *DealHandler) transitionResult(c echo.Context, deal models.Deal, err error) error {
+	switch {
+	case errors.Is(err, services.ErrDealForbidden):
+		return c.JSON(http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrInvalidDealTransition):
+		return c.JSON(http.StatusConflict, err.Error())
+	case err != nil:
+		log.Printf("Error changing deal status: %v", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, deal)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"market/internal/services"
+	"market/web/handlers/middlewares"
+
+	"github.com/labstack/echo/v4"
+)
+
+type EscrowHandler struct {
+	Service services.EscrowService
+}
+
+func (h *EscrowHandler) GetDealEscrow(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("Invalid deal ID: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid deal ID")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	hold, err := h.Service.GetByDeal(id, claims)
+	switch {
+	case errors.Is(err, services.ErrDealForbidden):
+		return c.JSON(http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrNoEscrowHold):
+		return echo.NewHTTPError(http.StatusNotFound, "Escrow hold not found")
+	case err != nil:
+		log.Printf("Error retrieving escrow hold: %v", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, hold)
+}
@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"market/internal/database"
 	"market/internal/database/models"
+	"market/internal/database/query"
 	"market/internal/services"
 	"market/web/handlers/middlewares"
 
@@ -24,9 +27,15 @@ func (h *ItemHandler) CreateItem(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, "Invalid input data")
 	}
 
-	userId := c.Get("userId").(int)
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
 
-	createdItem, err := h.Service.Create(newItem, userId)
+	createdItem, err := h.Service.Create(newItem, claims.UserId)
+	if errors.Is(err, services.ErrInvalidAttributes) {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
 	if err != nil {
 		log.Printf("Error creating item: %v", err)
 		return c.JSON(http.StatusBadRequest, "Error creating item")
@@ -52,30 +61,67 @@ func (h *ItemHandler) GetItem(c echo.Context) error {
 }
 
 func (h *ItemHandler) GetItems(c echo.Context) error {
-	pageNum, err := strconv.Atoi(c.QueryParam("page"))
-	if err != nil || pageNum <= 0 {
-		pageNum = 1
+	page, err := pageInfo(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
 	}
 
-	pageSize, err := strconv.Atoi(c.QueryParam("size"))
-	if err != nil || pageSize <= 0 {
-		pageSize = 10
+	filter, err := itemFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
 	}
 
-	page := database.PageInfo{
-		PageNumber: pageNum,
-		PageSize:   pageSize,
+	if cursor, ok := cursorParam(c); ok {
+		items, err := h.Service.GetAllByCursor(cursor, page.PageSize, filter, queryParams(c))
+		if errors.Is(err, query.ErrInvalidQuery) || errors.Is(err, database.ErrInvalidCursor) {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		if err != nil {
+			log.Printf("Error retrieving items: %v", err)
+			return c.JSON(http.StatusInternalServerError, "Error retrieving items")
+		}
+
+		setCursorLinks(c, items)
+		return c.JSON(http.StatusOK, items)
 	}
 
-	items, err := h.Service.GetAll(page)
+	items, err := h.Service.GetAll(page, filter, queryParams(c))
+	if errors.Is(err, query.ErrInvalidQuery) {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
 	if err != nil {
 		log.Printf("Error retrieving items: %v", err)
 		return c.JSON(http.StatusInternalServerError, "Error retrieving items")
 	}
 
+	setPageLinks(c, items)
 	return c.JSON(http.StatusOK, items)
 }
 
+func (h *ItemHandler) SearchItems(c echo.Context) error {
+	page, err := pageInfo(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	filter, err := itemFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	results, err := h.Service.Search(c.QueryParam("q"), page, filter)
+	if errors.Is(err, services.ErrEmptySearch) {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		log.Printf("Error searching items: %v", err)
+		return c.JSON(http.StatusInternalServerError, "Error searching items")
+	}
+
+	setPageLinks(c, results)
+	return c.JSON(http.StatusOK, results)
+}
+
 func (h *ItemHandler) UpdateItem(c echo.Context) error {
 	var item models.Item
 	if err := c.Bind(&item); err != nil {
@@ -83,12 +129,15 @@ func (h *ItemHandler) UpdateItem(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, "Invalid input data")
 	}
 
-	claims, ok := c.Get("userClaims").(*middlewares.Claims)
-	if !ok {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
 		return c.JSON(http.StatusUnauthorized, "You don't have rights")
 	}
 
 	updatedItem, err := h.Service.Update(item, claims)
+	if errors.Is(err, services.ErrInvalidAttributes) {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
 	if err != nil {
 		log.Printf("Error updating item: %v", err)
 		return c.JSON(http.StatusBadRequest, "Error updating item")
@@ -104,15 +153,50 @@ func (h *ItemHandler) DeleteItem(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, "Invalid item ID")
 	}
 
-	claims, ok := c.Get("userClaims").(*middlewares.Claims)
-	if !ok {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
 		return c.JSON(http.StatusUnauthorized, "You don't have rights")
 	}
 
-	if err := h.Service.Delete(id, claims); err != nil {
-		log.Printf("Item not found: %v", err)
+	err = h.Service.Delete(id, claims)
+	switch {
+	case errors.Is(err, services.ErrItemNotFound):
 		return c.JSON(http.StatusNotFound, "Item not found")
+	case errors.Is(err, services.ErrItemForbidden):
+		return c.JSON(http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrItemInUse):
+		return c.JSON(http.StatusConflict, err.Error())
+	case err != nil:
+		log.Printf("Error deleting item: %v", err)
+		return c.JSON(http.StatusInternalServerError, "Error deleting item")
 	}
 
 	return c.NoContent(http.StatusOK)
 }
+
+// itemFilter reads ?category=<id> and attribute filters such as
+// ?attr.color=red.
+func itemFilter(c echo.Context) (models.ItemFilter, error) {
+	filter := models.ItemFilter{Attributes: map[string]string{}}
+
+	if value := c.QueryParam("category"); value != "" {
+		categoryId, err := strconv.Atoi(value)
+		if err != nil || categoryId <= 0 {
+			return models.ItemFilter{}, errors.New("invalid category ID")
+		}
+		filter.CategoryId = &categoryId
+	}
+
+	for key, values := range c.QueryParams() {
+		name, ok := strings.CutPrefix(key, "attr.")
+		if !ok {
+			continue
+		}
+		if name == "" || len(values) != 1 {
+			return models.ItemFilter{}, errors.New("invalid attribute filter " + key)
+		}
+		filter.Attributes[name] = values[0]
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"market/web/handlers/middlewares"
+
+	"github.com/labstack/echo/v4"
+)
+
+type KeysHandler struct {
+	Keys *middlewares.KeySet
+}
+
+func (h *KeysHandler) GetJWKS(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, h.Keys.JWKS())
+}
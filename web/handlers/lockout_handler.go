@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"market/internal/services"
+	"market/web/handlers/middlewares"
+
+	"github.com/labstack/echo/v4"
+)
+
+type LockoutHandler struct {
+	Guard services.LoginGuard
+}
+
+func (h *LockoutHandler) GetLockouts(c echo.Context) error {
+	page, err := pageInfo(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	lockouts, err := h.Guard.GetLockouts(page)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	setPageLinks(c, lockouts)
+	return c.JSON(http.StatusOK, lockouts)
+}
+
+func (h *LockoutHandler) UnlockUser(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	if err := h.Guard.UnlockUser(id, claims.UserId); err != nil {
+		return c.JSON(http.StatusNotFound, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
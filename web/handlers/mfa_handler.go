@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"market/internal/database/models"
+	"market/internal/services"
+	"market/web/handlers/middlewares"
+
+	"github.com/labstack/echo/v4"
+)
+
+type MFAHandler struct {
+	Service services.MFAService
+}
+
+func (h *MFAHandler) Enroll(c echo.Context) error {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	enrollment, err := h.Service.Enroll(claims)
+	if err != nil {
+		return c.JSON(mfaErrorStatus(err), err.Error())
+	}
+
+	return c.JSON(http.StatusOK, enrollment)
+}
+
+func (h *MFAHandler) Activate(c echo.Context) error {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	var code models.MFACode
+	if err := c.Bind(&code); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	codes, err := h.Service.Activate(code, claims)
+	if err != nil {
+		return c.JSON(mfaErrorStatus(err), err.Error())
+	}
+
+	return c.JSON(http.StatusOK, codes)
+}
+
+func (h *MFAHandler) Disable(c echo.Context) error {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	var code models.MFACode
+	if err := c.Bind(&code); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.Service.Disable(code, claims); err != nil {
+		return c.JSON(mfaErrorStatus(err), err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c echo.Context) error {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	var code models.MFACode
+	if err := c.Bind(&code); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	codes, err := h.Service.RegenerateRecoveryCodes(code, claims)
+	if err != nil {
+		return c.JSON(mfaErrorStatus(err), err.Error())
+	}
+
+	return c.JSON(http.StatusOK, codes)
+}
+
+func mfaErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidMFACode), errors.Is(err, services.ErrInvalidMFAToken):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrMFAAlreadyEnabled), errors.Is(err, services.ErrMFANotEnabled), errors.Is(err, services.ErrMFANotEnrolled):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+)
+
+const claimsContextKey = "claims"
+
+var ErrNoCurrentUser = errors.New("no authenticated user in context")
+
+// CurrentUser returns the claims JWTMiddleware stored for the request. It is
+// the only supported way for handlers to read the authenticated user.
+func CurrentUser(c echo.Context) (*Claims, error) {
+	claims, ok := c.Get(claimsContextKey).(*Claims)
+	if !ok || claims == nil {
+		return nil, ErrNoCurrentUser
+	}
+
+	return claims, nil
+}
+
+func setCurrentUser(c echo.Context, claims *Claims) {
+	c.Set(claimsContextKey, claims)
+}
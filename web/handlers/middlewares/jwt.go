@@ -1,8 +1,9 @@
 package middlewares
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
@@ -10,50 +11,136 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-var JwtKey = []byte(os.Getenv("JWT_KEY"))
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	MFAChallengeTTL = 5 * time.Minute
+	DefaultLeeway   = 30 * time.Second
+)
+
+// TokenConfig holds the registered claims put into and required from every
+// token. An empty Issuer or Audience is neither set nor checked.
+type TokenConfig struct {
+	Issuer string
+	// Audience is written into issued tokens; a token is accepted when it
+	// names the first entry.
+	Audience []string
+	// Algorithms pins the accepted signing algorithms. When empty, the
+	// algorithms of the loaded keys are accepted.
+	Algorithms []string
+	// Leeway tolerates clock skew between this API and other issuers.
+	Leeway time.Duration
+}
+
+var tokenConfig = TokenConfig{Leeway: DefaultLeeway}
+
+func SetTokenConfig(config TokenConfig) {
+	tokenConfig = config
+}
 
 type Claims struct {
 	UserId   int    `json:"userId"`
 	Username string `json:"username"`
-	Refresh  bool   `json:"refresh"`
+	// Refresh marks the refresh JWTs issued before sessions existed. They
+	// are never accepted as access tokens.
+	Refresh bool `json:"refresh"`
+	// MFAPending marks a login challenge that still needs a second factor.
+	// It only proves the password and is never accepted as access token.
+	MFAPending bool `json:"mfa_pending,omitempty"`
+	// SessionId is the refresh token family the access token was issued
+	// from, used to log out the current session.
+	SessionId string `json:"sid,omitempty"`
+	// Roles and Permissions are loaded when the token is issued, so role
+	// changes take effect on the next refresh.
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	// APIKeyId and Scopes are set when the request was authenticated with
+	// an API key instead of a token. They are never part of a JWT.
+	APIKeyId int      `json:"-"`
+	Scopes   []string `json:"-"`
 	jwt.RegisteredClaims
 }
 
+// IsRevoked reports whether the token was revoked through the configured
+// RevocationStore.
+func IsRevoked(claims *Claims) (bool, error) {
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+
+	return revocations.IsRevoked(claims.ID, claims.UserId, issuedAt)
+}
+
 func JWTMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		authHeader := c.Request().Header.Get("Authorization")
 		if authHeader == "" {
-			return echo.NewHTTPError(http.StatusUnauthorized, "missing authorization header")
+			return echo.NewHTTPError(http.StatusUnauthorized, ErrTokenMissing)
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		claims, err := GetValidatedClaims(tokenString)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			return echo.NewHTTPError(http.StatusUnauthorized, err)
 		}
 
-		c.Set("claims", claims)
+		if claims.Refresh || claims.MFAPending {
+			return echo.NewHTTPError(http.StatusUnauthorized, ErrTokenWrongType)
+		}
+
+		revoked, err := IsRevoked(claims)
+		if err != nil {
+			c.Logger().Errorf("checking token revocation: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to check token")
+		}
+		if revoked {
+			return echo.NewHTTPError(http.StatusUnauthorized, ErrTokenRevoked)
+		}
+
+		setCurrentUser(c, claims)
 		return next(c)
 	}
 }
 
-func GenerateJWT(userId int, username string, refresh bool) (string, error) {
-	expirationTime := time.Now().Add(15 * time.Minute)
-	if refresh {
-		expirationTime = time.Now().Add(7 * 24 * time.Hour)
+// GenerateJWT signs a short-lived access token for the given claims.
+// Refresh tokens are opaque and kept server-side by the session service.
+// A jti already set in claims is kept, so callers can record it.
+func GenerateJWT(claims Claims) (string, error) {
+	claims.MFAPending = false
+	return signToken(claims, AccessTokenTTL)
+}
+
+// GenerateMFAChallenge signs the short-lived token login returns to users
+// with two-factor authentication, to be exchanged together with a code.
+func GenerateMFAChallenge(claims Claims) (string, error) {
+	claims.MFAPending = true
+	return signToken(claims, MFAChallengeTTL)
+}
+
+func signToken(claims Claims, ttl time.Duration) (string, error) {
+	if claims.ID == "" {
+		jti, err := newTokenId()
+		if err != nil {
+			return "", err
+		}
+		claims.ID = jti
 	}
 
-	claims := &Claims{
-		UserId:   userId,
-		Username: username,
-		Refresh:  refresh,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-		},
+	now := time.Now()
+	claims.Issuer = tokenConfig.Issuer
+	claims.Audience = tokenConfig.Audience
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+
+	key, err := keySet.Signer()
+	if err != nil {
+		return "", err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(JwtKey)
+	token := jwt.NewWithClaims(key.Method, &claims)
+	token.Header["kid"] = key.Id
+	tokenString, err := token.SignedString(key.Private)
 	if err != nil {
 		return "", err
 	}
@@ -61,15 +148,44 @@ func GenerateJWT(userId int, username string, refresh bool) (string, error) {
 	return tokenString, nil
 }
 
+// GetValidatedClaims parses and verifies a token. Errors are *TokenError
+// values describing why the token was rejected.
 func GetValidatedClaims(tokenString string) (*Claims, error) {
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return JwtKey, nil
-	})
+	algorithms := tokenConfig.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = keySet.Algorithms()
+	}
 
-	if err != nil || !token.Valid {
-		return nil, http.ErrAbortHandler
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(algorithms),
+		jwt.WithLeeway(tokenConfig.Leeway),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	}
+	if tokenConfig.Issuer != "" {
+		options = append(options, jwt.WithIssuer(tokenConfig.Issuer))
+	}
+	if len(tokenConfig.Audience) > 0 {
+		options = append(options, jwt.WithAudience(tokenConfig.Audience[0]))
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, keySet.Verifier, options...)
+	if err != nil {
+		return nil, tokenError(err)
+	}
+	if !token.Valid {
+		return nil, ErrTokenInvalid
 	}
 
 	return claims, nil
 }
+
+func newTokenId() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
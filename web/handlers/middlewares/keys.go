@@ -0,0 +1,235 @@
+package middlewares
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKey   = errors.New("unknown signing key")
+	ErrNoSigningKey = errors.New("no signing key configured")
+)
+
+// SigningKey is one key of the key set. Retired keys only have a public
+// half: they still verify tokens issued before a rotation but sign nothing.
+type SigningKey struct {
+	Id      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet holds the keys tokens are signed and verified with. Exactly one key
+// is active for signing; every key verifies tokens carrying its kid. A key
+// set is immutable, rotation loads a new one.
+type KeySet struct {
+	active string
+	keys   map[string]*SigningKey
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+var keySet = &KeySet{keys: map[string]*SigningKey{}}
+
+// SetKeySet replaces the key set used by GenerateJWT and JWTMiddleware.
+func SetKeySet(keys *KeySet) {
+	keySet = keys
+}
+
+// LoadKeySet reads every <kid>.pem file in dir. A file may hold a PKCS#8 or
+// PKCS#1 private key (RSA or Ed25519) or a PKIX public key of a retired key.
+// activeKid selects the signing key; when empty the last private key by file
+// name is used, so dated names like 2026-10-18.pem rotate naturally.
+func LoadKeySet(dir string, activeKid string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	keys := &KeySet{keys: map[string]*SigningKey{}}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := parseSigningKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		keys.keys[kid] = key
+		if key.Private != nil && activeKid == "" {
+			keys.active = kid
+		}
+	}
+
+	if activeKid != "" {
+		keys.active = activeKid
+	}
+
+	active, ok := keys.keys[keys.active]
+	if !ok || active.Private == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	return keys, nil
+}
+
+// GenerateKeySet creates a key set with a single in-memory Ed25519 key. Tokens
+// signed with it do not survive a restart, so it is meant for development.
+func GenerateKeySet() (*KeySet, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	kid := base64.RawURLEncoding.EncodeToString(public[:8])
+	return &KeySet{
+		active: kid,
+		keys: map[string]*SigningKey{
+			kid: {Id: kid, Method: jwt.SigningMethodEdDSA, Private: private, Public: public},
+		},
+	}, nil
+}
+
+// Signer returns the active key.
+func (keys *KeySet) Signer() (*SigningKey, error) {
+	key, ok := keys.keys[keys.active]
+	if !ok || key.Private == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	return key, nil
+}
+
+// Verifier returns the public key for kid as expected by jwt.Keyfunc,
+// refusing tokens whose algorithm does not match the key.
+func (keys *KeySet) Verifier(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := keys.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+
+	return key.Public, nil
+}
+
+// Algorithms lists the algorithms of all keys in the set.
+func (keys *KeySet) Algorithms() []string {
+	seen := map[string]bool{}
+	var algorithms []string
+	for _, key := range keys.keys {
+		if !seen[key.Method.Alg()] {
+			seen[key.Method.Alg()] = true
+			algorithms = append(algorithms, key.Method.Alg())
+		}
+	}
+	sort.Strings(algorithms)
+
+	return algorithms
+}
+
+// JWKS publishes the public half of every key so other services can verify
+// our tokens.
+func (keys *KeySet) JWKS() JWKSet {
+	kids := make([]string, 0, len(keys.keys))
+	for kid := range keys.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, kid := range kids {
+		key := keys.keys[kid]
+		jwk := JWK{Kid: kid, Alg: key.Method.Alg(), Use: "sig"}
+
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+func parseSigningKey(kid string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{Id: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HasPermission reports whether the token grants the permission, e.g.
+// "items:delete:any".
+func (claims *Claims) HasPermission(permission string) bool {
+	return slices.Contains(claims.Permissions, permission)
+}
+
+// RequirePermission only lets requests through whose token grants the
+// permission. It must run after JWTMiddleware.
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, err := CurrentUser(c)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+
+			if !claims.HasPermission(permission) {
+				return echo.NewHTTPError(http.StatusForbidden, "missing permission "+permission)
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"sync"
+	"time"
+)
+
+// RevocationStore remembers access tokens that must be rejected before they
+// expire: single tokens by jti, and every token of a user issued before a
+// cutoff (logout everywhere, password change, ban).
+type RevocationStore interface {
+	Revoke(jti string, expiresAt time.Time) error
+	RevokeUser(userId int, before time.Time) error
+	IsRevoked(jti string, userId int, issuedAt time.Time) (bool, error)
+}
+
+var revocations RevocationStore = NewMemoryRevocationStore()
+
+// SetRevocationStore replaces the store JWTMiddleware checks tokens against.
+func SetRevocationStore(store RevocationStore) {
+	revocations = store
+}
+
+// MemoryRevocationStore keeps revocations in process memory. It only works
+// for a single instance and forgets everything on restart.
+type MemoryRevocationStore struct {
+	mu      sync.Mutex
+	tokens  map[string]time.Time
+	cutoffs map[int]time.Time
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		tokens:  map[string]time.Time{},
+		cutoffs: map[int]time.Time{},
+	}
+}
+
+func (store *MemoryRevocationStore) Revoke(jti string, expiresAt time.Time) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := time.Now()
+	for id, expiry := range store.tokens {
+		if expiry.Before(now) {
+			delete(store.tokens, id)
+		}
+	}
+
+	store.tokens[jti] = expiresAt
+	return nil
+}
+
+func (store *MemoryRevocationStore) RevokeUser(userId int, before time.Time) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.cutoffs[userId] = before
+	return nil
+}
+
+func (store *MemoryRevocationStore) IsRevoked(jti string, userId int, issuedAt time.Time) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, ok := store.tokens[jti]; ok && jti != "" {
+		return true, nil
+	}
+
+	cutoff, ok := store.cutoffs[userId]
+	return ok && issuedAt.Before(cutoff.Truncate(time.Second)), nil
+}
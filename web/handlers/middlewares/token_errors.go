@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenError is a token validation failure. Code tells clients why a 401 was
+// returned, e.g. whether refreshing the token can help.
+type TokenError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err *TokenError) Error() string {
+	return err.Message
+}
+
+// MarshalJSON keeps the code in the body of the 401. Without it echo would
+// render the error as its message only.
+func (err *TokenError) MarshalJSON() ([]byte, error) {
+	type tokenError TokenError
+	return json.Marshal((*tokenError)(err))
+}
+
+var (
+	ErrTokenMissing      = &TokenError{Code: "token_missing", Message: "missing authorization header"}
+	ErrTokenMalformed    = &TokenError{Code: "token_malformed", Message: "token is malformed"}
+	ErrTokenExpired      = &TokenError{Code: "token_expired", Message: "token has expired"}
+	ErrTokenNotYetValid  = &TokenError{Code: "token_not_yet_valid", Message: "token is not valid yet"}
+	ErrTokenBadSignature = &TokenError{Code: "token_bad_signature", Message: "token signature is invalid"}
+	ErrTokenAudience     = &TokenError{Code: "token_wrong_audience", Message: "token is not meant for this service"}
+	ErrTokenIssuer       = &TokenError{Code: "token_wrong_issuer", Message: "token was issued by an unknown issuer"}
+	ErrTokenWrongType    = &TokenError{Code: "token_wrong_type", Message: "refresh tokens cannot be used as access tokens"}
+	ErrTokenRevoked      = &TokenError{Code: "token_revoked", Message: "token has been revoked"}
+	ErrTokenInvalid      = &TokenError{Code: "token_invalid", Message: "token is invalid"}
+)
+
+// tokenError maps a jwt parsing error to one of the TokenError values.
+func tokenError(err error) *TokenError {
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return ErrTokenMalformed
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return ErrTokenNotYetValid
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable), errors.Is(err, ErrUnknownKey):
+		return ErrTokenBadSignature
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return ErrTokenAudience
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return ErrTokenIssuer
+	default:
+		return ErrTokenInvalid
+	}
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"market/internal/database/models"
+	"market/internal/services"
+	"market/web/handlers/middlewares"
+
+	"github.com/labstack/echo/v4"
+)
+
+type OfferHandler struct {
+	Service services.OfferService
+}
+
+func (h *OfferHandler) CreateOffer(c echo.Context) error {
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("Invalid item ID: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid item ID")
+	}
+
+	var request models.OfferRequest
+	if err := c.Bind(&request); err != nil {
+		log.Printf("Invalid input data: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid input data")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	offer, err := h.Service.Create(itemId, request.Price, claims.UserId)
+	if err != nil {
+		log.Printf("Error creating offer: %v", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, offer)
+}
+
+func (h *OfferHandler) GetOffer(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("Invalid offer ID: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid offer ID")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	offer, err := h.Service.Get(id, claims)
+	if err != nil {
+		log.Printf("Offer not found: %v", err)
+		return echo.NewHTTPError(http.StatusNotFound, "Offer not found")
+	}
+
+	return c.JSON(http.StatusOK, offer)
+}
+
+func (h *OfferHandler) GetItemOffers(c echo.Context) error {
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("Invalid item ID: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid item ID")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	page, err := pageInfo(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	offers, err := h.Service.GetByItem(itemId, claims, page)
+	if err != nil {
+		log.Printf("Error retrieving offers: %v", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	setPageLinks(c, offers)
+	return c.JSON(http.StatusOK, offers)
+}
+
+func (h *OfferHandler) GetOffers(c echo.Context) error {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	page, err := pageInfo(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	offers, err := h.Service.GetByUser(claims.UserId, page)
+	if err != nil {
+		log.Printf("Error retrieving offers: %v", err)
+		return c.JSON(http.StatusInternalServerError, "Error retrieving offers")
+	}
+
+	setPageLinks(c, offers)
+	return c.JSON(http.StatusOK, offers)
+}
+
+func (h *OfferHandler) AcceptOffer(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("Invalid offer ID: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid offer ID")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	deal, err := h.Service.Accept(id, claims)
+	if err != nil {
+		return offerError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, deal)
+}
+
+func (h *OfferHandler) RejectOffer(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("Invalid offer ID: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid offer ID")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	offer, err := h.Service.Reject(id, claims)
+	if err != nil {
+		return offerError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, offer)
+}
+
+func (h *OfferHandler) CounterOffer(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("Invalid offer ID: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid offer ID")
+	}
+
+	var request models.OfferRequest
+	if err := c.Bind(&request); err != nil {
+		log.Printf("Invalid input data: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid input data")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	offer, err := h.Service.Counter(id, request.Price, claims)
+	if err != nil {
+		return offerError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, offer)
+}
+
+func offerError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, services.ErrOfferForbidden):
+		return c.JSON(http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrOfferNotPending):
+		return c.JSON(http.StatusConflict, err.Error())
+	default:
+		log.Printf("Error responding to offer: %v", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"market/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type OIDCHandler struct {
+	Service  services.OIDCService
+	Sessions services.SessionService
+	MFA      services.MFAService
+}
+
+func (h *OIDCHandler) GetProviders(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.Service.Providers())
+}
+
+// Login redirects to the provider's sign-in page.
+func (h *OIDCHandler) Login(c echo.Context) error {
+	authURL, err := h.Service.Begin(c.Param("provider"))
+	if err != nil {
+		return c.JSON(oidcErrorStatus(err), err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback is where the provider sends the user back to. It answers like
+// /login: with tokens, or with an MFA challenge when the user enabled
+// two-factor authentication here.
+func (h *OIDCHandler) Callback(c echo.Context) error {
+	if providerErr := c.QueryParam("error"); providerErr != "" {
+		return c.JSON(http.StatusUnauthorized, providerErr+": "+c.QueryParam("error_description"))
+	}
+
+	user, err := h.Service.Callback(c.Param("provider"), c.QueryParam("code"), c.QueryParam("state"))
+	if err != nil {
+		return c.JSON(oidcErrorStatus(err), err.Error())
+	}
+
+	challenge, required, err := h.MFA.Challenge(user)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	if required {
+		return c.JSON(http.StatusOK, challenge)
+	}
+
+	tokens, err := h.Sessions.Start(user, sessionMeta(c))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func oidcErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUnknownOIDCProvider):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidOIDCState), errors.Is(err, services.ErrOIDCLoginFailed),
+		errors.Is(err, services.ErrOIDCUserBanned):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrOIDCEmailUnverified), errors.Is(err, services.ErrOIDCEmailConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"market/internal/database/models"
+	"market/internal/services"
+	"market/web/handlers/middlewares"
+
+	"github.com/labstack/echo/v4"
+)
+
+type OrderHandler struct {
+	Service services.OrderService
+}
+
+func (h *OrderHandler) CreateOrder(c echo.Context) error {
+	var newOrder models.NewOrder
+	if err := c.Bind(&newOrder); err != nil {
+		log.Printf("Invalid input data: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid input data")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	order, err := h.Service.Submit(newOrder, claims.UserId)
+	if err != nil {
+		log.Printf("Error creating order: %v", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, order)
+}
+
+func (h *OrderHandler) GetOrder(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("Invalid order ID: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid order ID")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	order, err := h.Service.Get(id, claims)
+	if err != nil {
+		log.Printf("Order not found: %v", err)
+		return echo.NewHTTPError(http.StatusNotFound, "Order not found")
+	}
+
+	return c.JSON(http.StatusOK, order)
+}
+
+func (h *OrderHandler) GetOrders(c echo.Context) error {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	page, err := pageInfo(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	orders, err := h.Service.GetByUser(claims.UserId, page)
+	if err != nil {
+		log.Printf("Error retrieving orders: %v", err)
+		return c.JSON(http.StatusInternalServerError, "Error retrieving orders")
+	}
+
+	setPageLinks(c, orders)
+	return c.JSON(http.StatusOK, orders)
+}
+
+func (h *OrderHandler) CancelOrder(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("Invalid order ID: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid order ID")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	order, err := h.Service.Cancel(id, claims)
+	if err != nil {
+		log.Printf("Error cancelling order: %v", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, order)
+}
+
+func (h *OrderHandler) GetOrderBook(c echo.Context) error {
+	book, err := h.Service.GetBook(c.QueryParam("item_name"), currencyParam(c))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, book)
+}
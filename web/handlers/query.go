@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"market/internal/database"
+	"market/internal/database/query"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	DefaultPageSize    = 10
+	DefaultMaxPageSize = 100
+)
+
+var maxPageSize = DefaultMaxPageSize
+
+// SetMaxPageSize sets the largest ?size= list endpoints accept. Sizes
+// below one are ignored.
+func SetMaxPageSize(size int) {
+	if size > 0 {
+		maxPageSize = size
+	}
+}
+
+// pageInfo reads ?page= and ?size=, defaulting to the first page of
+// DefaultPageSize, and rejects sizes above the maximum.
+func pageInfo(c echo.Context) (database.PageInfo, error) {
+	pageNum, err := strconv.Atoi(c.QueryParam("page"))
+	if err != nil || pageNum <= 0 {
+		pageNum = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.QueryParam("size"))
+	if err != nil || pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	if pageSize > maxPageSize {
+		return database.PageInfo{}, fmt.Errorf("size must be at most %d", maxPageSize)
+	}
+
+	return database.PageInfo{
+		PageNumber: pageNum,
+		PageSize:   pageSize,
+	}, nil
+}
+
+// queryParams reads the ?filter= and ?sort= parameters of list endpoints.
+func queryParams(c echo.Context) query.Params {
+	return query.Params{
+		Filter: c.QueryParam("filter"),
+		Sort:   c.QueryParam("sort"),
+	}
+}
+
+// cursorParam reads ?cursor=. Its presence, even empty to start at the
+// beginning, switches a list to cursor pagination.
+func cursorParam(c echo.Context) (string, bool) {
+	return c.QueryParam("cursor"), c.QueryParams().Has("cursor")
+}
+
+// setPageLinks adds RFC 8288 first, prev, next and last links to the
+// current request with other page numbers.
+func setPageLinks[T any](c echo.Context, page database.Page[T]) {
+	lastPage := max(1, (page.Total+page.Size-1)/page.Size)
+
+	links := []string{
+		pageLink(c, "page", strconv.Itoa(1), "first"),
+	}
+	if page.Page > 1 {
+		links = append(links, pageLink(c, "page", strconv.Itoa(min(page.Page-1, lastPage)), "prev"))
+	}
+	if page.HasNext {
+		links = append(links, pageLink(c, "page", strconv.Itoa(page.Page+1), "next"))
+	}
+	links = append(links, pageLink(c, "page", strconv.Itoa(lastPage), "last"))
+
+	c.Response().Header().Set("Link", strings.Join(links, ", "))
+}
+
+// setCursorLinks adds RFC 8288 next and prev links for a cursor page.
+func setCursorLinks[T any](c echo.Context, page database.CursorPage[T]) {
+	var links []string
+	if page.PrevCursor != "" {
+		links = append(links, pageLink(c, "cursor", page.PrevCursor, "prev"))
+	}
+	if page.NextCursor != "" {
+		links = append(links, pageLink(c, "cursor", page.NextCursor, "next"))
+	}
+
+	if len(links) > 0 {
+		c.Response().Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+// pageLink is the current request's path and query with one parameter
+// replaced, formatted as a link with the relation.
+func pageLink(c echo.Context, param string, value string, rel string) string {
+	url := *c.Request().URL
+	values := url.Query()
+	values.Set(param, value)
+	url.RawQuery = values.Encode()
+
+	return fmt.Sprintf(`<%s>; rel="%s"`, url.RequestURI(), rel)
+}
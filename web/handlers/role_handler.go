@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"market/internal/database/models"
+	"market/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type RoleHandler struct {
+	Service services.RoleService
+}
+
+func (h *RoleHandler) GetRoles(c echo.Context) error {
+	roles, err := h.Service.GetAll()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, roles)
+}
+
+func (h *RoleHandler) GetUserRoles(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	roles, err := h.Service.GetUserRoles(id)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, roles)
+}
+
+func (h *RoleHandler) SetUserRoles(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	var roles models.UserRoles
+	if err := c.Bind(&roles); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	updated, err := h.Service.SetUserRoles(id, roles)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, updated)
+}
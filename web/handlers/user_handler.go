@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"market/internal/database"
 	"market/internal/database/models"
+	"market/internal/database/query"
 	"market/internal/services"
 	"market/web/handlers/middlewares"
 
@@ -45,26 +47,33 @@ func (h *UserHandler) GetUser(c echo.Context) error {
 }
 
 func (h *UserHandler) GetUsers(c echo.Context) error {
-	pageNum, err := strconv.Atoi(c.QueryParam("page"))
-	if err != nil || pageNum <= 0 {
-		pageNum = 1
+	page, err := pageInfo(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
 	}
 
-	pageSize, err := strconv.Atoi(c.QueryParam("size"))
-	if err != nil || pageSize <= 0 {
-		pageSize = 10
+	if cursor, ok := cursorParam(c); ok {
+		users, err := h.Service.GetAllByCursor(cursor, page.PageSize, queryParams(c))
+		if errors.Is(err, query.ErrInvalidQuery) || errors.Is(err, database.ErrInvalidCursor) {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+
+		setCursorLinks(c, users)
+		return c.JSON(http.StatusOK, users)
 	}
 
-	page := database.PageInfo{
-		PageNumber: pageNum,
-		PageSize:   pageSize,
+	users, err := h.Service.GetAll(page, queryParams(c))
+	if errors.Is(err, query.ErrInvalidQuery) {
+		return c.JSON(http.StatusBadRequest, err.Error())
 	}
-
-	users, err := h.Service.GetAll(page)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
 
+	setPageLinks(c, users)
 	return c.JSON(http.StatusOK, users)
 }
 
@@ -74,8 +83,8 @@ func (h *UserHandler) UpdateUser(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
 
-	claims, ok := c.Get("userClaims").(*middlewares.Claims)
-	if !ok {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
 		return c.JSON(http.StatusUnauthorized, "You don't have rights")
 	}
 
@@ -93,8 +102,8 @@ func (h *UserHandler) DeleteUser(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, "Invalid user ID")
 	}
 
-	claims, ok := c.Get("userClaims").(*middlewares.Claims)
-	if !ok {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
 		return c.JSON(http.StatusUnauthorized, "You don't have rights")
 	}
 
@@ -104,3 +113,34 @@ func (h *UserHandler) DeleteUser(c echo.Context) error {
 
 	return c.NoContent(http.StatusOK)
 }
+
+func (h *UserHandler) BanUser(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "You don't have rights")
+	}
+
+	if err := h.Service.Ban(id, claims); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *UserHandler) UnbanUser(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	if err := h.Service.Unban(id); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"market/internal/database/models"
+	"market/internal/services"
+	"market/web/handlers/middlewares"
+
+	"github.com/labstack/echo/v4"
+)
+
+type WalletHandler struct {
+	Service services.WalletService
+}
+
+func (h *WalletHandler) GetWallet(c echo.Context) error {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	wallet, err := h.Service.Get(claims.UserId, currencyParam(c))
+	if err != nil {
+		log.Printf("Error retrieving wallet: %v", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, wallet)
+}
+
+func (h *WalletHandler) GetHistory(c echo.Context) error {
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	page, err := pageInfo(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	entries, err := h.Service.GetHistory(claims.UserId, currencyParam(c), page)
+	if err != nil {
+		log.Printf("Error retrieving wallet history: %v", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	setPageLinks(c, entries)
+	return c.JSON(http.StatusOK, entries)
+}
+
+// Deposit credits a user's wallet with money received outside the platform.
+// It is restricted to admins, as nothing confirms the payment.
+func (h *WalletHandler) Deposit(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	var operation models.WalletOperation
+	if err := c.Bind(&operation); err != nil {
+		log.Printf("Invalid input data: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid input data")
+	}
+
+	wallet, err := h.Service.Deposit(id, operation.Amount)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, wallet)
+}
+
+func (h *WalletHandler) Withdraw(c echo.Context) error {
+	var operation models.WalletOperation
+	if err := c.Bind(&operation); err != nil {
+		log.Printf("Invalid input data: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid input data")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	wallet, err := h.Service.Withdraw(claims.UserId, operation.Amount)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, wallet)
+}
+
+func (h *WalletHandler) Transfer(c echo.Context) error {
+	var transfer models.WalletTransfer
+	if err := c.Bind(&transfer); err != nil {
+		log.Printf("Invalid input data: %v", err)
+		return c.JSON(http.StatusBadRequest, "Invalid input data")
+	}
+
+	claims, err := middlewares.CurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	wallet, err := h.Service.Transfer(claims.UserId, transfer.ToUserId, transfer.Amount)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, wallet)
+}
+
+func currencyParam(c echo.Context) string {
+	currency := strings.ToUpper(c.QueryParam("currency"))
+	if currency == "" {
+		return models.DefaultCurrency
+	}
+	return currency
+}
@@ -1,23 +1,129 @@
 package routes
 
 import (
+	"market/internal/database/models"
 	"market/web/handlers"
 	"market/web/handlers/middlewares"
 
 	"github.com/labstack/echo/v4"
 )
 
-func InitRoutes(e *echo.Echo, userHandler *handlers.UserHandler, authHandler *handlers.AuthHandler, itemHandler *handlers.ItemHandler, dealHandler *handlers.DealHandler) {
+func InitRoutes(e *echo.Echo, userHandler *handlers.UserHandler, authHandler *handlers.AuthHandler, itemHandler *handlers.ItemHandler, dealHandler *handlers.DealHandler, walletHandler *handlers.WalletHandler, offerHandler *handlers.OfferHandler, orderHandler *handlers.OrderHandler, auctionHandler *handlers.AuctionHandler, escrowHandler *handlers.EscrowHandler, roleHandler *handlers.RoleHandler, keysHandler *handlers.KeysHandler, accountHandler *handlers.AccountHandler, mfaHandler *handlers.MFAHandler, lockoutHandler *handlers.LockoutHandler, apiKeyHandler *handlers.APIKeyHandler, oidcHandler *handlers.OIDCHandler, categoryHandler *handlers.CategoryHandler) {
 	e.POST("/login", authHandler.Login)
+	e.POST("/login/mfa", authHandler.LoginMFA)
 	e.POST("/register", userHandler.CreateUser)
 	e.POST("/refresh", authHandler.RefreshToken)
+	e.GET("/.well-known/jwks.json", keysHandler.GetJWKS)
+	e.POST("/password/forgot", accountHandler.ForgotPassword)
+	e.POST("/password/reset", accountHandler.ResetPassword)
+	e.POST("/email/verify", accountHandler.VerifyEmail)
+	InitOIDCRoutes(e, oidcHandler)
 
 	authGroup := e.Group("/auth")
-	authGroup.Use(middlewares.JWTMiddleware)
+	authGroup.Use(middlewares.AuthMiddleware(apiKeyHandler.Service, APIKeyScopes))
 
+	InitSessionRoutes(authGroup, authHandler)
+	authGroup.POST("/email/verification", accountHandler.ResendVerification)
+	InitMFARoutes(authGroup, mfaHandler)
+	InitAPIKeyRoutes(authGroup, apiKeyHandler)
+	InitLockoutRoutes(authGroup, lockoutHandler)
 	InitUserRoutes(authGroup, userHandler)
 	InitItemRoutes(authGroup, itemHandler)
+	InitCategoryRoutes(authGroup, categoryHandler)
 	InitDealRoutes(authGroup, dealHandler)
+	InitWalletRoutes(authGroup, walletHandler)
+	InitOfferRoutes(authGroup, offerHandler)
+	InitOrderRoutes(authGroup, orderHandler)
+	InitAuctionRoutes(authGroup, auctionHandler)
+	InitEscrowRoutes(authGroup, escrowHandler)
+	InitRoleRoutes(authGroup, roleHandler)
+}
+
+// APIKeyScopes lists the routes API keys may use and the scope each needs.
+// Account management and admin routes are left out on purpose.
+var APIKeyScopes = middlewares.RouteScopes{
+	"GET /auth/users/:id": "users:read",
+	"GET /auth/users":     "users:read",
+
+	"GET /auth/items/search": "items:read",
+	"GET /auth/items/:id":    "items:read",
+	"GET /auth/items":        "items:read",
+	"POST /auth/items":       "items:write",
+	"PUT /auth/items/:id":    "items:write",
+	"DELETE /auth/items/:id": "items:write",
+
+	"GET /auth/categories":     "categories:read",
+	"GET /auth/categories/:id": "categories:read",
+
+	"GET /auth/deals/:id":           "deals:read",
+	"GET /auth/deals":               "deals:read",
+	"GET /auth/deals/:id/events":    "deals:read",
+	"GET /auth/deals/:id/escrow":    "deals:read",
+	"POST /auth/deals":              "deals:write",
+	"PUT /auth/deals/:id":           "deals:write",
+	"DELETE /auth/deals/:id":        "deals:write",
+	"POST /auth/deals/:id/accept":   "deals:write",
+	"POST /auth/deals/:id/pay":      "deals:write",
+	"POST /auth/deals/:id/cancel":   "deals:write",
+	"POST /auth/deals/:id/complete": "deals:write",
+	"POST /auth/deals/:id/dispute":  "deals:write",
+
+	"GET /auth/wallet":           "wallet:read",
+	"GET /auth/wallet/history":   "wallet:read",
+	"POST /auth/wallet/withdraw": "wallet:write",
+	"POST /auth/wallet/transfer": "wallet:write",
+
+	"GET /auth/items/:id/offers":    "offers:read",
+	"GET /auth/offers":              "offers:read",
+	"GET /auth/offers/:id":          "offers:read",
+	"POST /auth/items/:id/offers":   "offers:write",
+	"POST /auth/offers/:id/accept":  "offers:write",
+	"POST /auth/offers/:id/reject":  "offers:write",
+	"POST /auth/offers/:id/counter": "offers:write",
+
+	"GET /auth/orders/book":   "orders:read",
+	"GET /auth/orders/:id":    "orders:read",
+	"GET /auth/orders":        "orders:read",
+	"POST /auth/orders":       "orders:write",
+	"DELETE /auth/orders/:id": "orders:write",
+
+	"GET /auth/auctions/:id":       "auctions:read",
+	"GET /auth/auctions":           "auctions:read",
+	"GET /auth/auctions/:id/bids":  "auctions:read",
+	"POST /auth/auctions":          "auctions:write",
+	"POST /auth/auctions/:id/bids": "auctions:write",
+}
+
+func InitOIDCRoutes(e *echo.Echo, handler *handlers.OIDCHandler) {
+	e.GET("/oidc/providers", handler.GetProviders)
+	e.GET("/oidc/:provider/login", handler.Login)
+	e.GET("/oidc/:provider/callback", handler.Callback)
+}
+
+func InitSessionRoutes(group *echo.Group, handler *handlers.AuthHandler) {
+	group.GET("/sessions", handler.GetSessions)
+	group.POST("/logout", handler.Logout)
+	group.POST("/logout-all", handler.LogoutAll)
+}
+
+func InitMFARoutes(group *echo.Group, handler *handlers.MFAHandler) {
+	group.POST("/mfa/enroll", handler.Enroll)
+	group.POST("/mfa/activate", handler.Activate)
+	group.POST("/mfa/disable", handler.Disable)
+	group.POST("/mfa/recovery-codes", handler.RegenerateRecoveryCodes)
+}
+
+func InitAPIKeyRoutes(group *echo.Group, handler *handlers.APIKeyHandler) {
+	group.GET("/api-keys", handler.GetAPIKeys)
+	group.POST("/api-keys", handler.CreateAPIKey)
+	group.DELETE("/api-keys/:id", handler.RevokeAPIKey)
+}
+
+func InitLockoutRoutes(group *echo.Group, handler *handlers.LockoutHandler) {
+	unlock := middlewares.RequirePermission(models.PermUsersUnlock)
+
+	group.GET("/lockouts", handler.GetLockouts, unlock)
+	group.POST("/users/:id/unlock", handler.UnlockUser, unlock)
 }
 
 func InitUserRoutes(group *echo.Group, handler *handlers.UserHandler) {
@@ -25,9 +131,12 @@ func InitUserRoutes(group *echo.Group, handler *handlers.UserHandler) {
 	group.GET("/users", handler.GetUsers)
 	group.PUT("/users/:id", handler.UpdateUser)
 	group.DELETE("/users/:id", handler.DeleteUser)
+	group.POST("/users/:id/ban", handler.BanUser, middlewares.RequirePermission(models.PermUsersBan))
+	group.DELETE("/users/:id/ban", handler.UnbanUser, middlewares.RequirePermission(models.PermUsersBan))
 }
 
 func InitItemRoutes(group *echo.Group, handler *handlers.ItemHandler) {
+	group.GET("/items/search", handler.SearchItems)
 	group.GET("/items/:id", handler.GetItem)
 	group.GET("/items", handler.GetItems)
 	group.POST("/items", handler.CreateItem)
@@ -35,10 +144,73 @@ func InitItemRoutes(group *echo.Group, handler *handlers.ItemHandler) {
 	group.DELETE("/items/:id", handler.DeleteItem)
 }
 
+func InitCategoryRoutes(group *echo.Group, handler *handlers.CategoryHandler) {
+	manage := middlewares.RequirePermission(models.PermCategoriesManage)
+
+	group.GET("/categories", handler.GetCategories)
+	group.GET("/categories/:id", handler.GetCategory)
+	group.POST("/categories", handler.CreateCategory, manage)
+	group.PUT("/categories/:id", handler.UpdateCategory, manage)
+	group.DELETE("/categories/:id", handler.DeleteCategory, manage)
+}
+
 func InitDealRoutes(group *echo.Group, handler *handlers.DealHandler) {
 	group.GET("/deals/:id", handler.GetDeal)
 	group.GET("/deals", handler.GetDeals)
 	group.POST("/deals", handler.CreateDeal)
 	group.PUT("/deals/:id", handler.UpdateDeal)
 	group.DELETE("/deals/:id", handler.DeleteDeal)
+	group.GET("/deals/:id/events", handler.GetDealEvents)
+	group.POST("/deals/:id/accept", handler.AcceptDeal)
+	group.POST("/deals/:id/pay", handler.PayDeal)
+	group.POST("/deals/:id/cancel", handler.CancelDeal)
+	group.POST("/deals/:id/complete", handler.CompleteDeal)
+	group.POST("/deals/:id/dispute", handler.DisputeDeal)
+	group.POST("/deals/:id/resolve", handler.ResolveDeal, middlewares.RequirePermission(models.PermDealsResolve))
+}
+
+func InitWalletRoutes(group *echo.Group, handler *handlers.WalletHandler) {
+	group.GET("/wallet", handler.GetWallet)
+	group.GET("/wallet/history", handler.GetHistory)
+	group.POST("/wallet/withdraw", handler.Withdraw)
+	group.POST("/wallet/transfer", handler.Transfer)
+	group.POST("/users/:id/wallet/deposit", handler.Deposit, middlewares.RequirePermission(models.PermWalletsDeposit))
+}
+
+func InitOfferRoutes(group *echo.Group, handler *handlers.OfferHandler) {
+	group.POST("/items/:id/offers", handler.CreateOffer)
+	group.GET("/items/:id/offers", handler.GetItemOffers)
+	group.GET("/offers", handler.GetOffers)
+	group.GET("/offers/:id", handler.GetOffer)
+	group.POST("/offers/:id/accept", handler.AcceptOffer)
+	group.POST("/offers/:id/reject", handler.RejectOffer)
+	group.POST("/offers/:id/counter", handler.CounterOffer)
+}
+
+func InitOrderRoutes(group *echo.Group, handler *handlers.OrderHandler) {
+	group.GET("/orders/book", handler.GetOrderBook)
+	group.GET("/orders/:id", handler.GetOrder)
+	group.GET("/orders", handler.GetOrders)
+	group.POST("/orders", handler.CreateOrder)
+	group.DELETE("/orders/:id", handler.CancelOrder)
+}
+
+func InitAuctionRoutes(group *echo.Group, handler *handlers.AuctionHandler) {
+	group.GET("/auctions/:id", handler.GetAuction)
+	group.GET("/auctions", handler.GetAuctions)
+	group.POST("/auctions", handler.CreateAuction)
+	group.GET("/auctions/:id/bids", handler.GetBids)
+	group.POST("/auctions/:id/bids", handler.PlaceBid)
+}
+
+func InitEscrowRoutes(group *echo.Group, handler *handlers.EscrowHandler) {
+	group.GET("/deals/:id/escrow", handler.GetDealEscrow)
+}
+
+func InitRoleRoutes(group *echo.Group, handler *handlers.RoleHandler) {
+	manage := middlewares.RequirePermission(models.PermRolesManage)
+
+	group.GET("/roles", handler.GetRoles, manage)
+	group.GET("/users/:id/roles", handler.GetUserRoles, manage)
+	group.PUT("/users/:id/roles", handler.SetUserRoles, manage)
 }
@@ -0,0 +1,62 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"market/web/handlers/middlewares"
+
+	"github.com/labstack/echo/v4"
+)
+
+// scopedKeys authenticates each key as an API key holding the scopes listed
+// for it.
+type scopedKeys map[string][]string
+
+func (keys scopedKeys) AuthenticateAPIKey(key string) (*middlewares.Claims, error) {
+	return &middlewares.Claims{UserId: 1, APIKeyId: 1, Scopes: keys[key]}, nil
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	keys := scopedKeys{
+		"mk_items":  {"items:read", "items:write"},
+		"mk_offers": {"offers:write"},
+	}
+
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+
+	e := echo.New()
+	auth := e.Group("/auth", middlewares.AuthMiddleware(keys, APIKeyScopes))
+	auth.POST("/items/:id/offers", ok)
+	auth.GET("/items/:id", ok)
+	auth.GET("/sessions", ok)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		want   int
+	}{
+		{"offer needs offers scope", http.MethodPost, "/auth/items/7/offers", "mk_items", http.StatusForbidden},
+		{"offer with offers scope", http.MethodPost, "/auth/items/7/offers", "mk_offers", http.StatusOK},
+		{"item read", http.MethodGet, "/auth/items/7", "mk_items", http.StatusOK},
+		{"item read without scope", http.MethodGet, "/auth/items/7", "mk_offers", http.StatusForbidden},
+		{"unlisted route", http.MethodGet, "/auth/sessions", "mk_items", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("X-API-Key", tt.key)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}